@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	accountsMu      sync.Mutex
+	knownAccounts   = make(map[string]string) // Bot ID -> Last known username
+	missingAccounts = make(map[string]bool)   // Bot IDs whose account has disappeared
+)
+
+// isUnknownUser reports whether a user lookup failed because Discord has no
+// user with that ID.
+func isUnknownUser(err error) bool {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) {
+		return false
+	}
+	if restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownUser {
+		return true
+	}
+	return restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
+
+// trackAccount raises a critical alert when a bot that resolved before is
+// no longer a Discord user, which happens when its account is deleted or
+// banned, and an info alert when it resolves again. It returns the bot's
+// last known username for reports while the account is missing.
+func trackAccount(botID string, user *discordgo.User, err error) string {
+	accountsMu.Lock()
+	name, known := knownAccounts[botID]
+	wasMissing := missingAccounts[botID]
+	switch {
+	case err == nil:
+		knownAccounts[botID] = user.Username
+		delete(missingAccounts, botID)
+	case known && isUnknownUser(err):
+		missingAccounts[botID] = true
+	}
+	accountsMu.Unlock()
+
+	switch {
+	case err == nil && wasMissing:
+		raiseAlert(Alert{
+			Severity: SeverityInfo,
+			BotID:    botID,
+			BotName:  user.Username,
+			Message:  "botアカウントを再び確認できるようになりました",
+			Rule:     RuleAccount,
+		})
+	case err != nil && known && !wasMissing && isUnknownUser(err):
+		raiseAlert(Alert{
+			Severity: SeverityCritical,
+			BotID:    botID,
+			BotName:  name,
+			Message:  "botアカウントが見つかりません。削除されたかBANされた可能性があります",
+			Rule:     RuleAccount,
+		})
+	}
+	return name
+}
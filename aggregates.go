@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const aggregatePrefix = "AGGREGATE_"
+
+// Aggregate functions. unique counts the guilds of owned bots once, however
+// many of the bots are in them, since a plain sum counts shared audiences
+// once per bot.
+const (
+	aggregateSum    = "sum"
+	aggregateMax    = "max"
+	aggregateMin    = "min"
+	aggregateAvg    = "avg"
+	aggregateUnique = "unique"
+)
+
+var aggregateKinds = []string{aggregateSum, aggregateMax, aggregateMin, aggregateAvg, aggregateUnique}
+
+// aggregateSymbols prefix each aggregate in reports.
+var aggregateSymbols = map[string]string{
+	aggregateSum:    "Σ",
+	aggregateMax:    "⬆",
+	aggregateMin:    "⬇",
+	aggregateAvg:    "⌀",
+	aggregateUnique: "🌐",
+}
+
+// Aggregate is a summary field combining the server counts of a group of
+// bots, read from AGGREGATE_<NAME>=FUNCTION[:BOT_ID,BOT_ID]. Without bot IDs
+// it covers every target bot. Private bots are never included.
+type Aggregate struct {
+	Name     string
+	Function string
+	BotIDs   []string
+}
+
+// aggregateValue is an aggregate's value in one report. Included is how
+// many of the group's bots it was computed from.
+type aggregateValue struct {
+	Aggregate
+	Value    float64
+	Included int
+	Size     int
+}
+
+var (
+	uniqueMu         sync.RWMutex
+	uniqueAggregates = make(map[string]*NetworkStats) // Aggregate name -> Guilds of the latest run
+)
+
+// loadAggregates parses every AGGREGATE_<NAME> in name order. Invalid
+// aggregates are reported by validateEnv before this runs.
+func loadAggregates() []Aggregate {
+	var aggregates []Aggregate
+	for name, value := range getEnvPerBot(aggregatePrefix) {
+		function, bots, _ := strings.Cut(value, ":")
+		aggregate := Aggregate{Name: strings.ToLower(name), Function: strings.TrimSpace(function)}
+		for _, botID := range strings.Split(bots, ",") {
+			if botID = strings.TrimSpace(botID); botID != "" {
+				aggregate.BotIDs = append(aggregate.BotIDs, botID)
+			}
+		}
+		if len(aggregate.BotIDs) == 0 {
+			aggregate.BotIDs = config.TargetBotIDs
+		}
+		aggregates = append(aggregates, aggregate)
+	}
+	sort.Slice(aggregates, func(i, j int) bool { return aggregates[i].Name < aggregates[j].Name })
+	return aggregates
+}
+
+// validateAggregates checks the function and bots of every AGGREGATE_<NAME>.
+// unique needs a bot token for each bot to list its guilds.
+func validateAggregates(problems *configProblems, targetIDs map[string]bool) {
+	tokens := parseBotPairs(os.Getenv("BOT_TOKENS"))
+
+	var names []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, aggregatePrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		function, bots, _ := strings.Cut(strings.TrimSpace(os.Getenv(name)), ":")
+		if !contains(aggregateKinds, strings.TrimSpace(function)) {
+			problems.add(name, "unknown function %q (expected %s)", function, strings.Join(aggregateKinds, ", "))
+		}
+		for _, botID := range strings.Split(bots, ",") {
+			botID = strings.TrimSpace(botID)
+			switch {
+			case botID == "":
+			case !targetIDs[botID]:
+				problems.add(name, "bot %s is not in TARGET_BOT_IDS", botID)
+			case strings.TrimSpace(function) == aggregateUnique && tokens[botID] == "":
+				problems.add(name, "unique needs a BOT_TOKENS entry for bot %s to list its guilds", botID)
+			}
+		}
+	}
+}
+
+// computeUniqueAggregates combines the guilds of the bots of each unique
+// aggregate, from the guilds listed once per sample.
+func computeUniqueAggregates(guilds guildLists) {
+	results := make(map[string]*NetworkStats)
+	for _, aggregate := range config.Aggregates {
+		if aggregate.Function != aggregateUnique {
+			continue
+		}
+		reach, err := countUniqueGuilds(aggregate.BotIDs, guilds)
+		if err != nil {
+			log.Printf("Error computing aggregate %s: %v", aggregate.Name, err)
+			continue
+		}
+		results[aggregate.Name] = reach
+	}
+
+	uniqueMu.Lock()
+	uniqueAggregates = results
+	uniqueMu.Unlock()
+}
+
+// aggregateValues computes the aggregates a destination may show from the
+// bots fetched successfully. Public destinations only show the aggregates
+// listed in PUBLIC_REPORT_METRICS.
+func aggregateValues(allStats []BotStats, destination Destination) []aggregateValue {
+	counts := make(map[string]int)
+	for _, stats := range allStats {
+		if stats.Error == nil && !stats.Pending && !config.PrivateBots[stats.BotID] {
+			counts[stats.BotID] = stats.ServerCount
+		}
+	}
+
+	uniqueMu.RLock()
+	defer uniqueMu.RUnlock()
+
+	var values []aggregateValue
+	for _, aggregate := range config.Aggregates {
+		if destination.Public && !config.PublicMetrics[aggregate.Name] {
+			continue
+		}
+		value := aggregateValue{Aggregate: aggregate}
+		for _, botID := range aggregate.BotIDs {
+			if !config.PrivateBots[botID] {
+				value.Size++
+			}
+		}
+
+		if aggregate.Function == aggregateUnique {
+			guilds, known := uniqueAggregates[aggregate.Name]
+			if !known || guilds.BotCount == 0 {
+				continue
+			}
+			value.Value, value.Included = float64(guilds.UniqueGuilds), guilds.BotCount
+			values = append(values, value)
+			continue
+		}
+
+		for _, botID := range aggregate.BotIDs {
+			count, fetched := counts[botID]
+			if !fetched {
+				continue
+			}
+			switch {
+			case value.Included == 0:
+				value.Value = float64(count)
+			case aggregate.Function == aggregateMax:
+				value.Value = max(value.Value, float64(count))
+			case aggregate.Function == aggregateMin:
+				value.Value = min(value.Value, float64(count))
+			default:
+				value.Value += float64(count)
+			}
+			value.Included++
+		}
+		if value.Included == 0 {
+			continue
+		}
+		if aggregate.Function == aggregateAvg {
+			value.Value /= float64(value.Included)
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// String renders the aggregate as a report line, noting when some of the
+// group's bots couldn't be included.
+func (v aggregateValue) String() string {
+	line := fmt.Sprintf("%s %s: **%d**", aggregateSymbols[v.Function], v.Name, int(math.Round(v.Value)))
+	if v.Included < v.Size {
+		line += fmt.Sprintf(" (%d/%d)", v.Included, v.Size)
+	}
+	return line
+}
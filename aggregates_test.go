@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportTotal(t *testing.T) {
+	allStats := []BotStats{
+		{BotID: "1", BotName: "a", ServerCount: 100},
+		{BotID: "2", BotName: "b", ServerCount: 50},
+		{BotID: "3", BotName: "c", Error: errors.New("down")},
+	}
+	tests := []struct {
+		name        string
+		reportTotal bool
+		aggregates  []Aggregate
+		want        string
+		wantMissing string
+	}{
+		{"off by default", false, nil, "", "Σ 合計"},
+		{"plain sum", true, nil, "Σ 合計: **150**", ""},
+		{"replaced by aggregates", true, []Aggregate{{Name: "largest", Function: "max", BotIDs: []string{"1", "2"}}}, "largest", "Σ 合計"},
+	}
+
+	previousConfig := config
+	t.Cleanup(func() { config = previousConfig })
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.ReportTotal, config.Aggregates = test.reportTotal, test.aggregates
+			message := buildReportMessage(allStats, nil, Destination{Language: "ja", Location: time.UTC})
+			if test.want != "" && !strings.Contains(message, test.want) {
+				t.Errorf("report has no %q:\n%s", test.want, message)
+			}
+			if test.wantMissing != "" && strings.Contains(message, test.wantMissing) {
+				t.Errorf("report has %q:\n%s", test.wantMissing, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarn
+	SeverityCritical
+)
+
+var severities = []Severity{SeverityInfo, SeverityWarn, SeverityCritical}
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarn:
+		return "warn"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return "info"
+	}
+}
+
+func (s Severity) emoji() string {
+	switch s {
+	case SeverityWarn:
+		return "⚠️"
+	case SeverityCritical:
+		return "🚨"
+	default:
+		return "ℹ️"
+	}
+}
+
+func parseSeverity(value string) (Severity, error) {
+	for _, severity := range severities {
+		if strings.EqualFold(value, severity.String()) {
+			return severity, nil
+		}
+	}
+	return SeverityInfo, fmt.Errorf("unknown severity %q (expected info, warn or critical)", value)
+}
+
+type Alert struct {
+	Severity Severity
+	BotID    string
+	BotName  string
+	Message  string
+	Time     time.Time
+
+	Rule      string          // What raised the alert: fetch, anomaly, badge or a RULES_FILE rule name
+	Notifiers []notifierEntry // Optional: delivers to these instead of the severity's routes
+	AckID     string          // Set on escalating critical alerts; adds an Acknowledge button
+}
+
+// AlertRoute lists where alerts of one severity are delivered. Role mentions
+// are attached to the channel messages of the same route.
+type AlertRoute struct {
+	ChannelIDs []string
+	RoleIDs    []string
+	PagerDuty  bool
+	Email      bool
+	Ntfy       bool
+	Pushover   bool
+	Teams      bool
+}
+
+type EmailConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+var (
+	alertMu     sync.Mutex
+	failingBots = make(map[string]time.Time) // Bot ID -> when its fetch started failing
+	allFailing  bool
+)
+
+// loadAlertRoutes reads ALERT_ROUTES_<SEVERITY> for each severity.
+// Format: channel:ID,role:ID,pagerduty,email,ntfy,pushover,teams
+func loadAlertRoutes() map[Severity]AlertRoute {
+	routes := make(map[Severity]AlertRoute)
+
+	for _, severity := range severities {
+		envName := "ALERT_ROUTES_" + strings.ToUpper(severity.String())
+		value := os.Getenv(envName)
+		if value == "" {
+			continue
+		}
+
+		route, unknown := parseAlertRoute(strings.Split(value, ","))
+		for _, destination := range unknown {
+			log.Printf("Unknown destination in %s: %s", envName, destination)
+		}
+		routes[severity] = route
+	}
+
+	return routes
+}
+
+// parseAlertRoute reads route destinations (channel:ID, role:ID, pagerduty,
+// email, ntfy, pushover, teams) and returns the ones it didn't recognize.
+func parseAlertRoute(destinations []string) (AlertRoute, []string) {
+	var route AlertRoute
+	var unknown []string
+	for _, destination := range destinations {
+		kind, id, _ := strings.Cut(strings.TrimSpace(destination), ":")
+		switch kind {
+		case "channel":
+			route.ChannelIDs = append(route.ChannelIDs, id)
+		case "role":
+			route.RoleIDs = append(route.RoleIDs, id)
+		case "pagerduty":
+			route.PagerDuty = true
+		case "email":
+			route.Email = true
+		case "ntfy":
+			route.Ntfy = true
+		case "pushover":
+			route.Pushover = true
+		case "teams":
+			route.Teams = true
+		default:
+			unknown = append(unknown, destination)
+		}
+	}
+
+	// Role pings need a channel to be posted in
+	if len(route.RoleIDs) > 0 && len(route.ChannelIDs) == 0 {
+		route.ChannelIDs = []string{config.ChannelID}
+	}
+	return route, unknown
+}
+
+func loadEmailConfig() EmailConfig {
+	email := EmailConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("ALERT_EMAIL_FROM"),
+	}
+	if email.Port == "" {
+		email.Port = "587"
+	}
+	for _, to := range strings.Split(os.Getenv("ALERT_EMAIL_TO"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			email.To = append(email.To, to)
+		}
+	}
+	return email
+}
+
+// evaluateAlerts compares this run's results with the previous ones and
+// raises alerts when a bot starts or stops failing.
+func evaluateAlerts(allStats []BotStats) {
+	alertMu.Lock()
+	var alerts []Alert
+
+	failedCount := 0
+	for _, stats := range allStats {
+		if stats.Pending {
+			continue
+		}
+		if stats.Error != nil {
+			failedCount++
+			if _, failing := failingBots[stats.BotID]; !failing {
+				failingBots[stats.BotID] = time.Now()
+				fireHook(statusHookEvent(stats, StatusDown, 0))
+				scheduleRemediation(stats)
+				alerts = append(alerts, Alert{
+					Severity: SeverityWarn,
+					BotID:    stats.BotID,
+					BotName:  stats.BotName,
+					Message:  "サーバー数を取得できませんでした: " + errorMessage(defaultLanguage, stats.Error),
+					Rule:     RuleFetch,
+				})
+			}
+		} else if since, failing := failingBots[stats.BotID]; failing {
+			delete(failingBots, stats.BotID)
+			fireHook(statusHookEvent(stats, StatusUp, time.Since(since)))
+			cancelRemediation(stats.BotID)
+			alerts = append(alerts, Alert{
+				Severity: SeverityInfo,
+				BotID:    stats.BotID,
+				BotName:  stats.BotName,
+				Message:  "サーバー数の取得が回復しました",
+				Rule:     RuleFetch,
+			})
+		}
+	}
+
+	nowAllFailing := len(allStats) > 0 && failedCount == len(allStats)
+	if allFailing && !nowAllFailing {
+		resolveAlert(Alert{Rule: RuleFetch})
+	}
+	if nowAllFailing && !allFailing {
+		alerts = append(alerts, Alert{
+			Severity: SeverityCritical,
+			Message:  fmt.Sprintf("全%d botのサーバー数取得に失敗しました", len(allStats)),
+			Rule:     RuleFetch,
+		})
+	}
+	allFailing = nowAllFailing
+	alertMu.Unlock()
+
+	for _, alert := range alerts {
+		raiseAlert(alert)
+	}
+}
+
+// raiseAlert fires the on_alert hook and delivers the alert. With the
+// alerting module off or a matching silence, alerts are only logged.
+func raiseAlert(alert Alert) {
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+	log.Printf("Alert [%s] %s: %s", alert.Severity, alert.BotID, alert.Message)
+	recordIncident(alert)
+	if !moduleEnabled(ModuleAlerting) {
+		return
+	}
+	if alert.Severity == SeverityInfo {
+		resolveAlert(alert)
+	}
+	if isSilenced(alert) {
+		log.Printf("Alert for %s silenced", alert.BotID)
+		return
+	}
+	if alert.Severity == SeverityCritical {
+		alert = startEscalation(alert)
+	}
+
+	raiseAlertHook(alert)
+	postFeed(formatAlert(alert))
+
+	queueAlert(alert)
+}
+
+func raiseAlertHook(alert Alert) {
+	fireHook(HookEvent{
+		Event:    HookOnAlert,
+		Time:     alert.Time,
+		Severity: alert.Severity.String(),
+		BotID:    alert.BotID,
+		BotName:  alert.BotName,
+		Message:  alert.Message,
+	})
+}
+
+func formatAlert(alert Alert) string {
+	subject := "statbot"
+	if alert.BotName != "" {
+		subject = alert.BotName
+	} else if alert.BotID != "" {
+		subject = alert.BotID
+	}
+	return fmt.Sprintf("%s [%s] %s: %s", alert.Severity.emoji(), strings.ToUpper(alert.Severity.String()), subject, alert.Message)
+}
+
+// resolveAlert ends what the alert it recovers from started: its
+// escalation and its PagerDuty incident.
+func resolveAlert(alert Alert) {
+	resolveEscalation(alert)
+	queueAlertWork(func() {
+		if err := resolvePagerDuty(alert); err != nil {
+			log.Printf("Error resolving PagerDuty incident: %v", err)
+		}
+	})
+}
+
+var (
+	pagerDutyMu   sync.Mutex
+	pagerDutyOpen = make(map[string]bool) // Dedup keys of triggered incidents
+)
+
+func sendPagerDutyAlert(alert Alert, summary string) error {
+	if config.PagerDutyRoutingKey == "" {
+		return fmt.Errorf("PAGERDUTY_ROUTING_KEY is not set")
+	}
+	// Recoveries close the incident instead of opening an info one
+	if alert.Severity == SeverityInfo {
+		return resolvePagerDuty(alert)
+	}
+
+	pdSeverity := map[Severity]string{
+		SeverityInfo:     "info",
+		SeverityWarn:     "warning",
+		SeverityCritical: "critical",
+	}[alert.Severity]
+
+	event := map[string]any{
+		"routing_key":  config.PagerDutyRoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    pagerDutyDedupKey(alert),
+		"payload": map[string]any{
+			"summary":   summary,
+			"source":    "statbot",
+			"severity":  pdSeverity,
+			"timestamp": alert.Time.Format(time.RFC3339),
+		},
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post("https://events.pagerduty.com/v2/enqueue", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("PagerDuty returned status %d", resp.StatusCode)
+	}
+
+	pagerDutyMu.Lock()
+	pagerDutyOpen[pagerDutyDedupKey(alert)] = true
+	pagerDutyMu.Unlock()
+	return nil
+}
+
+// resolvePagerDuty resolves the incident with the alert's dedup key, if
+// statbot triggered one.
+func resolvePagerDuty(alert Alert) error {
+	key := pagerDutyDedupKey(alert)
+	pagerDutyMu.Lock()
+	open := pagerDutyOpen[key]
+	delete(pagerDutyOpen, key)
+	pagerDutyMu.Unlock()
+	if !open || config.PagerDutyRoutingKey == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"routing_key":  config.PagerDutyRoutingKey,
+		"event_action": "resolve",
+		"dedup_key":    key,
+	})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post("https://events.pagerduty.com/v2/enqueue", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("PagerDuty returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// pagerDutyDedupKey groups PagerDuty events by rule and bot, so a fetch
+// failure and an anomaly on the same bot stay separate incidents.
+func pagerDutyDedupKey(alert Alert) string {
+	rule, botID := alert.Rule, alert.BotID
+	if rule == "" {
+		rule = "alert"
+	}
+	if botID == "" {
+		botID = silenceAll
+	}
+	return "statbot-" + rule + "-" + botID
+}
+
+func sendEmailAlert(alert Alert, text string) error {
+	email := config.Email
+	if email.Host == "" || email.From == "" || len(email.To) == 0 {
+		return fmt.Errorf("SMTP_HOST, ALERT_EMAIL_FROM and ALERT_EMAIL_TO must be set")
+	}
+
+	subject := fmt.Sprintf("[statbot] %s alert", strings.ToUpper(alert.Severity.String()))
+	msg := "From: " + email.From + "\r\n" +
+		"To: " + strings.Join(email.To, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + text + "\r\n"
+
+	var auth smtp.Auth
+	if email.Username != "" {
+		auth = smtp.PlainAuth("", email.Username, email.Password, email.Host)
+	}
+
+	return smtp.SendMail(email.Host+":"+email.Port, auth, email.From, email.To, []byte(msg))
+}
+
+// testAlert builds a synthetic alert used to verify the routing setup.
+func testAlert(severity Severity) Alert {
+	return Alert{
+		Severity: severity,
+		Message:  "これはテストアラートです。このメッセージが届いていれば通知設定は正しく動作しています",
+		Time:     time.Now(),
+	}
+}
+
+// sendTestAlert pushes a synthetic alert through the full pipeline and
+// summarizes where it went.
+func sendTestAlert(severity Severity) string {
+	alert := testAlert(severity)
+	raiseAlertHook(alert)
+
+	route, exists := config.AlertRoutes[severity]
+	if !exists {
+		return fmt.Sprintf("重要度 %s の送信先が設定されていません (ALERT_ROUTES_%s)", severity, strings.ToUpper(severity.String()))
+	}
+
+	errs := deliverAlert(alert)
+	summary := fmt.Sprintf("テストアラート (%s) を送信しました: チャンネル %d件, ロール %d件, PagerDuty %v, メール %v, ntfy %v, Pushover %v, Teams %v",
+		severity, len(route.ChannelIDs), len(route.RoleIDs), route.PagerDuty, route.Email, route.Ntfy, route.Pushover, route.Teams)
+	for _, err := range errs {
+		summary += "\n❌ " + err.Error()
+	}
+	return summary
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultAnnounceTemplate = "🎉 {{.BotName}} が {{.Milestone}} サーバーを突破しました！"
+
+// MilestoneAnnouncement is the data available to ANNOUNCE_TEMPLATE.
+type MilestoneAnnouncement struct {
+	BotID     string
+	BotName   string
+	Milestone int
+	Previous  int
+	Count     int
+}
+
+func announcementsEnabled() bool {
+	return config.MastodonToken != "" || config.BlueskyHandle != ""
+}
+
+// loadAnnounceTemplate parses ANNOUNCE_TEMPLATE, falling back to the default.
+func loadAnnounceTemplate() *template.Template {
+	tmpl := getEnvDefault("ANNOUNCE_TEMPLATE", defaultAnnounceTemplate)
+	parsed, err := template.New("announce").Parse(tmpl)
+	if err != nil {
+		log.Fatalf("Invalid ANNOUNCE_TEMPLATE: %v", err)
+	}
+	return parsed
+}
+
+// announceMilestone posts a public milestone announcement to every
+// configured social account in the background.
+func announceMilestone(announcement MilestoneAnnouncement) {
+	if !announcementsEnabled() {
+		return
+	}
+
+	var text bytes.Buffer
+	if err := config.AnnounceTemplate.Execute(&text, announcement); err != nil {
+		log.Printf("Error rendering milestone announcement: %v", err)
+		return
+	}
+
+	hooksRunning.Add(1)
+	go func() {
+		defer hooksRunning.Done()
+
+		if config.MastodonToken != "" && dryRun("mastodon") {
+			logDryRun("mastodon", config.MastodonURL, text.String())
+		} else if config.MastodonToken != "" {
+			err := postToMastodon(text.String())
+			metrics.recordDelivery("mastodon", err)
+			if err != nil {
+				log.Printf("Error announcing milestone on Mastodon: %v", err)
+			}
+		}
+		if config.BlueskyHandle != "" && dryRun("bluesky") {
+			logDryRun("bluesky", config.BlueskyHandle, text.String())
+		} else if config.BlueskyHandle != "" {
+			err := postToBluesky(text.String())
+			metrics.recordDelivery("bluesky", err)
+			if err != nil {
+				log.Printf("Error announcing milestone on Bluesky: %v", err)
+			}
+		}
+	}()
+}
+
+func postToMastodon(text string) error {
+	endpoint := strings.TrimRight(config.MastodonURL, "/") + "/api/v1/statuses"
+	form := url.Values{"status": {text}, "visibility": {"public"}}
+
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+config.MastodonToken)
+	identifyRequest(req)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Mastodon returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// postToBluesky signs in with an app password and creates a post.
+func postToBluesky(text string) error {
+	var session struct {
+		AccessJwt string `json:"accessJwt"`
+		DID       string `json:"did"`
+	}
+	err := blueskyCall("com.atproto.server.createSession", "", map[string]any{
+		"identifier": config.BlueskyHandle,
+		"password":   config.BlueskyAppPassword,
+	}, &session)
+	if err != nil {
+		return fmt.Errorf("failed to sign in: %v", err)
+	}
+
+	return blueskyCall("com.atproto.repo.createRecord", session.AccessJwt, map[string]any{
+		"repo":       session.DID,
+		"collection": "app.bsky.feed.post",
+		"record": map[string]any{
+			"$type":     "app.bsky.feed.post",
+			"text":      text,
+			"createdAt": time.Now().UTC().Format(time.RFC3339),
+		},
+	}, nil)
+}
+
+func blueskyCall(method, accessJwt string, input, output any) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", "https://bsky.social/xrpc/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if accessJwt != "" {
+		req.Header.Set("Authorization", "Bearer "+accessJwt)
+	}
+	identifyRequest(req)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Bluesky %s returned status %d", method, resp.StatusCode)
+	}
+	if output != nil {
+		return json.NewDecoder(resp.Body).Decode(output)
+	}
+	return nil
+}
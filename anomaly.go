@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// anomalyAlpha is the weight of the newest change in the moving statistics.
+// Lower values adapt more slowly to a bot's changing growth pattern.
+const anomalyAlpha = 0.3
+
+// changeStats is an exponentially weighted mean and variance of a bot's
+// run-to-run server count change.
+type changeStats struct {
+	Mean     float64
+	Variance float64
+	Samples  int
+}
+
+var (
+	anomalyMu    sync.Mutex
+	botChangeEMA = make(map[string]*changeStats)
+)
+
+// evaluateAnomalies raises a warning when a bot's change since the previous
+// run is more than ANOMALY_THRESHOLD standard deviations away from its own
+// moving average, so the threshold adapts to each bot's normal variance.
+func evaluateAnomalies(allStats []BotStats) {
+	if !config.AnomalyDetection {
+		return
+	}
+
+	anomalyMu.Lock()
+	var alerts []Alert
+
+	for _, stats := range allStats {
+		if stats.Error != nil || stats.Pending || !stats.HasChange {
+			continue
+		}
+
+		history, exists := botChangeEMA[stats.BotID]
+		if !exists {
+			history = &changeStats{}
+			botChangeEMA[stats.BotID] = history
+		}
+
+		change := float64(stats.Change)
+
+		if history.Samples >= config.AnomalyMinSamples {
+			// A floor of one server keeps perfectly flat bots from alerting on +1
+			stddev := math.Max(math.Sqrt(history.Variance), 1)
+			z := (change - history.Mean) / stddev
+			if math.Abs(z) >= float64(config.AnomalyThreshold) {
+				alerts = append(alerts, Alert{
+					Severity: SeverityWarn,
+					BotID:    stats.BotID,
+					BotName:  stats.BotName,
+					Message:  fmt.Sprintf("サーバー数の変化が通常と大きく異なります: %+d (平均 %+.1f, z=%.1f)", stats.Change, history.Mean, z),
+					Rule:     RuleAnomaly,
+				})
+			}
+		}
+
+		history.add(change)
+	}
+	anomalyMu.Unlock()
+
+	for _, alert := range alerts {
+		raiseAlert(alert)
+	}
+}
+
+// add updates the moving statistics with a new change.
+func (c *changeStats) add(change float64) {
+	if c.Samples == 0 {
+		c.Mean = change
+	} else {
+		diff := change - c.Mean
+		increment := anomalyAlpha * diff
+		c.Mean += increment
+		c.Variance = (1 - anomalyAlpha) * (c.Variance + diff*increment)
+	}
+	c.Samples++
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	approveButtonPrefix = "approve:"
+	rejectButtonPrefix  = "reject:"
+)
+
+// pendingApproval is a public report waiting for a moderator's decision.
+type pendingApproval struct {
+	RunID        string
+	Destinations []Destination
+	Stats        []BotStats
+	Network      *NetworkStats
+	Message      *discordgo.Message
+}
+
+var (
+	approvalMu     sync.Mutex
+	approvals      = make(map[string]*pendingApproval) // Approval ID -> Pending report
+	nextApprovalID int
+)
+
+// requiresApproval reports whether a destination's report is held for a
+// moderator: public destinations always, internal ones too when a timeout
+// skips the whole report.
+func requiresApproval(destination Destination) bool {
+	if config.ApprovalChannelID == "" {
+		return false
+	}
+	return destination.Public || config.ApprovalTimeoutAction == "skip"
+}
+
+// requestApproval posts the held report, with the report script already
+// applied, to the approval channel with Approve and Reject buttons.
+// Undecided reports are dropped after APPROVAL_MINUTES. Once the request is
+// posted the held destinations count as handled by the run, so a restart
+// doesn't take the run for an interrupted one and ask again; approving
+// records the messages sent.
+func requestApproval(runID string, destinations []Destination, allStats []BotStats, network *NetworkStats) {
+	// Late results keep patching the caller's slice, so hold a snapshot
+	stats := make([]BotStats, len(allStats))
+	copy(stats, allStats)
+
+	approvalMu.Lock()
+	nextApprovalID++
+	id := strconv.Itoa(nextApprovalID)
+	approvalMu.Unlock()
+
+	destination := destinations[0]
+	for _, d := range destinations {
+		if d.Public {
+			destination = d
+			break
+		}
+	}
+
+	window := time.Duration(config.ApprovalMinutes) * time.Minute
+	message := fmt.Sprintf("📝 **承認待ち**: 以下のレポートを%d件の送信先に送信します。%d分以内に承認してください\n",
+		len(destinations), config.ApprovalMinutes) +
+		buildReportMessage(stats, network, destination)
+
+	sent, err := sendWithComponents(config.ApprovalChannelID, message, approvalButtons(id, ""))
+	if err != nil {
+		log.Printf("Error requesting report approval, the report for %d destinations was not sent: %v", len(destinations), err)
+		return
+	}
+
+	for _, destination := range destinations {
+		recordDelivered(runID, destination.ChannelID, nil)
+	}
+
+	approvalMu.Lock()
+	approvals[id] = &pendingApproval{RunID: runID, Destinations: destinations, Stats: stats, Network: network, Message: sent}
+	approvalMu.Unlock()
+
+	time.AfterFunc(window, func() {
+		approval := takeApproval(id)
+		if approval == nil {
+			return
+		}
+		log.Printf("Report approval %s expired, skipping %d destinations", id, len(approval.Destinations))
+		closeApproval(approval, "⌛ 期限切れ")
+	})
+}
+
+func approvalButtons(id, decision string) []discordgo.MessageComponent {
+	if decision != "" {
+		return []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: decision, Style: discordgo.SecondaryButton, CustomID: approveButtonPrefix + id, Disabled: true},
+			}},
+		}
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "承認", Style: discordgo.SuccessButton, CustomID: approveButtonPrefix + id},
+			discordgo.Button{Label: "却下", Style: discordgo.DangerButton, CustomID: rejectButtonPrefix + id},
+		}},
+	}
+}
+
+func takeApproval(id string) *pendingApproval {
+	approvalMu.Lock()
+	defer approvalMu.Unlock()
+
+	approval := approvals[id]
+	delete(approvals, id)
+	return approval
+}
+
+// closeApproval replaces the buttons of an approval request with its outcome.
+func closeApproval(approval *pendingApproval, decision string) {
+	if approval.Message.ID == dryRunMessageID {
+		return
+	}
+	_, err := session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         approval.Message.ID,
+		Channel:    approval.Message.ChannelID,
+		Components: approvalButtons("closed", decision),
+	})
+	if err != nil {
+		log.Printf("Error closing approval request: %v", err)
+	}
+}
+
+// isApprovalButton returns the approval ID of an Approve or Reject button and
+// whether it approves.
+func isApprovalButton(customID string) (id string, approve bool, ok bool) {
+	if id, ok := strings.CutPrefix(customID, approveButtonPrefix); ok {
+		return id, true, true
+	}
+	if id, ok := strings.CutPrefix(customID, rejectButtonPrefix); ok {
+		return id, false, true
+	}
+	return "", false, false
+}
+
+func handleApprovalButton(s *discordgo.Session, i *discordgo.InteractionCreate, id string, approve bool) {
+	if i.Member == nil || i.Member.Permissions&discordgo.PermissionManageServer == 0 {
+		respondEphemeral(s, i, "レポートの承認にはサーバー管理権限が必要です")
+		return
+	}
+
+	approval := takeApproval(id)
+	if approval == nil {
+		respondEphemeral(s, i, "この承認リクエストは既に処理済みか期限切れです")
+		return
+	}
+
+	decision := "❌ " + i.Member.User.Username + " が却下"
+	if approve {
+		decision = "✅ " + i.Member.User.Username + " が承認"
+	}
+
+	components := approvalButtons(id, decision)
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    i.Message.Content,
+			Components: components,
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating approval request: %v", err)
+	}
+
+	log.Printf("Report approval %s: %s", id, decision)
+	action := "reject report"
+	if approve {
+		action = "approve report"
+	}
+	recordAudit(interactionUser(i), action, approval.RunID)
+	if approve {
+		for _, destination := range approval.Destinations {
+			if messages := sendServerCountNotification(approval.Stats, approval.Network, destination); len(messages) > 0 {
+				recordDelivered(approval.RunID, destination.ChannelID, messages)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// AuditEntry records an action a Discord user took through statbot, or one
+// statbot took on its own (without a user).
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	UserID   string    `json:"user_id"`
+	Username string    `json:"username"`
+	Action   string    `json:"action"`
+	Detail   string    `json:"detail,omitempty"`
+}
+
+var auditMu sync.Mutex
+
+// recordAudit logs who did what, and appends it to AUDIT_LOG as a JSON
+// line when that is set. user is nil for statbot's own actions.
+func recordAudit(user *discordgo.User, action, detail string) {
+	entry := AuditEntry{Time: time.Now(), Action: action, Detail: detail}
+	if user != nil {
+		entry.UserID = user.ID
+		entry.Username = user.Username
+	}
+	log.Printf("Audit: %s (%s) %s: %s", entry.Username, entry.UserID, action, detail)
+
+	if config.AuditLog == "" {
+		return
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Error encoding audit entry: %v", err)
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	file, err := os.OpenFile(config.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Printf("Error opening AUDIT_LOG: %v", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		log.Printf("Error writing AUDIT_LOG: %v", err)
+	}
+}
+
+// interactionUser returns the user behind an interaction in a guild or DM.
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	return i.User
+}
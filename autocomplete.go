@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxChoices is the most autocomplete choices Discord accepts.
+const maxChoices = 25
+
+// handleAutocomplete suggests target bots for the focused bot option of
+// /bot, /watch silence and /compare, matching the typed text against names
+// and IDs.
+func handleAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	if len(data.Options) == 0 {
+		return
+	}
+
+	// /compare has its options directly, the others in a subcommand
+	options := data.Options
+	if options[0].Type == discordgo.ApplicationCommandOptionSubCommand {
+		options = options[0].Options
+	}
+
+	var typed string
+	for _, option := range options {
+		if option.Focused {
+			typed = strings.ToLower(strings.TrimSpace(option.StringValue()))
+		}
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	if data.Name == "watch" && strings.HasPrefix(silenceAll, typed) {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: "すべてのbot (all)", Value: silenceAll})
+	}
+	for _, botID := range config.TargetBotIDs {
+		if len(choices) == maxChoices {
+			break
+		}
+		name := botDisplayName(botID)
+		if typed != "" && !strings.Contains(strings.ToLower(name), typed) && !strings.HasPrefix(botID, typed) {
+			continue
+		}
+		label := botID
+		if name != "" {
+			label = name + " (" + botID + ")"
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: label, Value: botID})
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+	if err != nil {
+		log.Printf("Error responding to autocomplete: %v", err)
+	}
+}
+
+// botDisplayName is the bot's latest known username, from the last fetch or
+// the username cache, or "" before it was ever resolved.
+func botDisplayName(botID string) string {
+	if sample, known := latestSampleOf(botID); known && sample.BotName != "" && sample.BotName != "Unknown" {
+		return sample.BotName
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return delivered.Users[botID].Username
+}
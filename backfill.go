@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bot lists only expose a bot's current count, so history from before
+// statbot started watching a bot comes from an export (e.g. downloaded from
+// a stats site or another tracker) in BACKFILL_FILE: a CSV file with a
+// header naming the bot_id, time and server_count columns, in any order.
+// Other columns are ignored.
+
+// backfillSample is one past count read from the export.
+type backfillSample struct {
+	Time  time.Time
+	Count int
+}
+
+// backfillTimeLayouts are the time formats accepted in the time column,
+// besides Unix seconds.
+var backfillTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// backfillHistory seeds the trends of target bots from BACKFILL_FILE so the
+// /bot info chart, anomaly detection and smoothing don't start out empty.
+// The latest exported count is not used as the previous count: the export
+// may be days old, and the first change would look like a jump.
+func backfillHistory() {
+	if config.BackfillFile == "" {
+		return
+	}
+
+	samples, err := readBackfill(config.BackfillFile)
+	if err != nil {
+		log.Printf("Error reading BACKFILL_FILE, starting without history: %v", err)
+		return
+	}
+
+	backfilled := 0
+	for _, botID := range config.TargetBotIDs {
+		history := samples[botID]
+		if len(history) < 2 {
+			continue
+		}
+		sort.Slice(history, func(i, j int) bool { return history[i].Time.Before(history[j].Time) })
+		backfillBot(botID, history)
+		backfilled++
+	}
+	log.Printf("Backfilled the history of %d bots from %s", backfilled, config.BackfillFile)
+}
+
+// backfillBot adds a bot's exported counts, oldest first, to every trend
+// that is still empty.
+func backfillBot(botID string, samples []backfillSample) {
+	counts := make([]int, len(samples))
+	for i, sample := range samples {
+		counts[i] = sample.Count
+	}
+
+	historyMu.Lock()
+	if history := historyOf(botID); len(history.Counts) == 0 {
+		history.Counts = counts[max(len(counts)-historySamples, 0):]
+	}
+	historyMu.Unlock()
+
+	anomalyMu.Lock()
+	if _, exists := botChangeEMA[botID]; !exists {
+		history := &changeStats{}
+		for i := 1; i < len(counts); i++ {
+			history.add(float64(counts[i] - counts[i-1]))
+		}
+		botChangeEMA[botID] = history
+	}
+	anomalyMu.Unlock()
+
+	if smoothing, exists := config.Smoothing["server_count"]; exists {
+		smoothingMu.Lock()
+		if smoothingSamples[botID] == nil {
+			smoothingSamples[botID] = make(map[string][]float64)
+		}
+		if len(smoothingSamples[botID]["server_count"]) == 0 {
+			var window []float64
+			for _, count := range counts[max(len(counts)-smoothing.Window, 0):] {
+				window = append(window, float64(count))
+			}
+			smoothingSamples[botID]["server_count"] = window
+		}
+		smoothingMu.Unlock()
+	}
+}
+
+// readBackfill reads the exported counts by bot. Rows that can't be parsed
+// are skipped and counted in the log.
+func readBackfill(path string) (map[string][]backfillSample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	for _, name := range []string{"bot_id", "time", "server_count"} {
+		if _, exists := columns[name]; !exists {
+			return nil, fmt.Errorf("the header has no %s column", name)
+		}
+	}
+
+	samples := make(map[string][]backfillSample)
+	skipped := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sample, botID, ok := parseBackfillRow(record, columns)
+		if !ok {
+			skipped++
+			continue
+		}
+		samples[botID] = append(samples[botID], sample)
+	}
+
+	if skipped > 0 {
+		log.Printf("Skipped %d rows of %s that could not be parsed", skipped, path)
+	}
+	return samples, nil
+}
+
+func parseBackfillRow(record []string, columns map[string]int) (backfillSample, string, bool) {
+	field := func(name string) string {
+		if i := columns[name]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	count, err := strconv.Atoi(field("server_count"))
+	if err != nil || count < 0 {
+		return backfillSample{}, "", false
+	}
+	at, ok := parseBackfillTime(field("time"))
+	if !ok || field("bot_id") == "" {
+		return backfillSample{}, "", false
+	}
+	return backfillSample{Time: at, Count: count}, field("bot_id"), true
+}
+
+func parseBackfillTime(value string) (time.Time, bool) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), true
+	}
+	for _, layout := range backfillTimeLayouts {
+		if at, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// BotBadges records a bot's verification status on Discord and its
+// certification on top.gg. Nil means the status couldn't be determined.
+type BotBadges struct {
+	Verified  *bool
+	Certified *bool
+}
+
+// TopGGBot is the subset of the top.gg bot listing the watcher tracks.
+type TopGGBot struct {
+	CertifiedBot  bool     `json:"certifiedBot"`
+	ShortDesc     string   `json:"shortdesc"`
+	Tags          []string `json:"tags"`
+	Points        int      `json:"points"`        // Votes of all time
+	MonthlyPoints int      `json:"monthlyPoints"` // Votes this month
+}
+
+var (
+	badgesMu   sync.Mutex
+	lastBadges = make(map[string]BotBadges)
+)
+
+// checkBadges compares the bot's current badges with the last known ones
+// and raises an alert for each change. The top.gg listing is nil when it
+// couldn't be fetched.
+func checkBadges(user *discordgo.User, topgg *TopGGBot) {
+	verified := user.PublicFlags&discordgo.UserFlagVerifiedBot != 0
+	current := BotBadges{Verified: &verified}
+
+	if topgg != nil {
+		current.Certified = &topgg.CertifiedBot
+	}
+
+	badgesMu.Lock()
+	previous, known := lastBadges[user.ID]
+	lastBadges[user.ID] = current
+	badgesMu.Unlock()
+
+	if !known {
+		return
+	}
+
+	if changed, now := badgeChanged(previous.Verified, current.Verified); changed {
+		raiseBadgeAlert(user, "Discord認証済みbot", now)
+	}
+	if changed, now := badgeChanged(previous.Certified, current.Certified); changed {
+		raiseBadgeAlert(user, "top.gg認定bot", now)
+	}
+}
+
+func badgeChanged(previous, current *bool) (bool, bool) {
+	if previous == nil || current == nil {
+		return false, false
+	}
+	return *previous != *current, *current
+}
+
+func raiseBadgeAlert(user *discordgo.User, badge string, granted bool) {
+	alert := Alert{
+		Severity: SeverityInfo,
+		BotID:    user.ID,
+		BotName:  user.Username,
+		Message:  fmt.Sprintf("%sになりました", badge),
+		Rule:     RuleBadge,
+	}
+	if !granted {
+		alert.Severity = SeverityWarn
+		alert.Message = fmt.Sprintf("%sではなくなりました", badge)
+	}
+	raiseAlert(alert)
+}
+
+func getTopGGBot(botID string) (*TopGGBot, error) {
+	url := fmt.Sprintf("https://top.gg/api/bots/%s", botID)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", config.TopGGToken)
+	identifyRequest(req)
+	req = tagBot(req, botID)
+
+	waitForHost(url)
+	client := &http.Client{Timeout: fetchTimeout(botID, "topgg")}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("top.gg API returned status %d", resp.StatusCode)
+	}
+
+	var bot TopGGBot
+	if err := json.NewDecoder(resp.Body).Decode(&bot); err != nil {
+		return nil, err
+	}
+	return &bot, nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	historySamples   = 30 // Counts kept per bot for the /bot info chart
+	historyIncidents = 5  // Alerts kept per bot for /bot info
+)
+
+// sparkBlocks draw the /bot info chart, lowest to highest.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// botHistory is what /bot info shows about a bot beyond its latest count.
+// It is kept in memory since the process started.
+type botHistory struct {
+	Latest    BotStats  // Most recent successful fetch
+	LatestAt  time.Time // When it was fetched
+	Counts    []int     // Recent server counts, oldest first
+	Fetches   int
+	Failures  int
+	Incidents []Alert // Recent alerts about the bot, newest last
+}
+
+var (
+	historyMu    sync.Mutex
+	botHistories = make(map[string]*botHistory)
+)
+
+func historyOf(botID string) *botHistory {
+	history, exists := botHistories[botID]
+	if !exists {
+		history = &botHistory{}
+		botHistories[botID] = history
+	}
+	return history
+}
+
+// recordHistory adds the fetched bots to their history. Pending bots are
+// recorded when their result arrives.
+func recordHistory(allStats []BotStats) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	for _, stats := range allStats {
+		if stats.Pending {
+			continue
+		}
+		history := historyOf(stats.BotID)
+		history.Fetches++
+		if stats.Error != nil {
+			history.Failures++
+			continue
+		}
+		history.Latest = stats
+		history.LatestAt = time.Now()
+		history.Counts = append(history.Counts, stats.ServerCount)
+		if len(history.Counts) > historySamples {
+			history.Counts = history.Counts[len(history.Counts)-historySamples:]
+		}
+	}
+}
+
+// recordIncident remembers an alert about a single bot for /bot info.
+func recordIncident(alert Alert) {
+	if alert.BotID == "" {
+		return
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	history := historyOf(alert.BotID)
+	history.Incidents = append(history.Incidents, alert)
+	if len(history.Incidents) > historyIncidents {
+		history.Incidents = history.Incidents[len(history.Incidents)-historyIncidents:]
+	}
+}
+
+// handleBotInfo shows everything known about one bot in an embed.
+func handleBotInfo(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	var botRef string
+	for _, option := range subcommand.Options {
+		if option.Name == "name" {
+			botRef = option.StringValue()
+		}
+	}
+	botID, ok := resolveBot(botRef)
+	if !ok {
+		respondEphemeral(s, i, fmt.Sprintf("bot %q は監視対象ではありません", botRef))
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{botInfoEmbed(botID)},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to /bot info: %v", err)
+	}
+}
+
+func botInfoEmbed(botID string) *discordgo.MessageEmbed {
+	historyMu.Lock()
+	history := *historyOf(botID)
+	history.Counts = append([]int(nil), history.Counts...)
+	history.Incidents = append([]Alert(nil), history.Incidents...)
+	historyMu.Unlock()
+
+	alertMu.Lock()
+	downSince, failing := failingBots[botID]
+	alertMu.Unlock()
+
+	embed := &discordgo.MessageEmbed{
+		Title: botLabel(botID),
+		Color: 0x57F287,
+	}
+	if failing {
+		embed.Color = 0xED4245
+	}
+	add := func(name, value string, inline bool) {
+		// Discord rejects field values over 1024 characters
+		if runes := []rune(value); len(runes) > 1024 {
+			value = string(runes[:1023]) + "…"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: name, Value: value, Inline: inline})
+	}
+
+	stats := history.Latest
+	if history.LatestAt.IsZero() {
+		add("サーバー数", "まだ取得できていません", false)
+	} else {
+		count := fmt.Sprintf("**%d**", stats.ServerCount)
+		if stats.HasChange {
+			count += fmt.Sprintf(" (%+d)", stats.Change)
+		}
+		if stats.Estimated {
+			count += " " + translate(defaultLanguage, "estimated", stats.Source)
+		}
+		add("サーバー数", count, true)
+		add("取得元", fmt.Sprintf("%s\n<t:%d:R>", stats.Source, history.LatestAt.Unix()), true)
+
+		var others []string
+		if stats.HasUserInstalls {
+			others = append(others, fmt.Sprintf("ユーザーインストール: %d", stats.UserInstalls))
+		}
+		if stats.HasPatrons {
+			others = append(others, fmt.Sprintf("パトロン: %d", stats.Patrons))
+		}
+		if len(stats.Votes) > 0 {
+			others = append(others, fmt.Sprintf("投票: %d (%s)", totalVotes(stats.Votes), formatVotes(defaultLanguage, stats.Votes)))
+		}
+		if stats.Repo != nil {
+			others = append(others, fmt.Sprintf("GitHub: ⭐%d / Issue %d", stats.Repo.Stars, stats.Repo.OpenIssues))
+		}
+		if computed := formatComputed(stats.Computed, false); computed != "" {
+			others = append(others, strings.ReplaceAll(strings.TrimPrefix(computed, " | "), " | ", "\n"))
+		}
+		if len(others) > 0 {
+			add("その他の指標", strings.Join(others, "\n"), false)
+		}
+	}
+
+	status := "✅ 正常"
+	if failing {
+		status = fmt.Sprintf("❌ 取得失敗中（<t:%d:R>から）", downSince.Unix())
+	}
+	if history.Fetches > 0 {
+		status += fmt.Sprintf("\n稼働率: %.1f%%（%d回中%d回成功）", float64(history.Fetches-history.Failures)*100/float64(history.Fetches), history.Fetches, history.Fetches-history.Failures)
+	}
+	add("状態", status, false)
+
+	if len(history.Incidents) > 0 {
+		var lines []string
+		for j := len(history.Incidents) - 1; j >= 0; j-- {
+			incident := history.Incidents[j]
+			lines = append(lines, fmt.Sprintf("%s <t:%d:R> %s", incident.Severity.emoji(), incident.Time.Unix(), incident.Message))
+		}
+		add("直近のインシデント", strings.Join(lines, "\n"), false)
+	}
+
+	if step := config.FeedMilestoneStep; step > 0 && !history.LatestAt.IsZero() {
+		reached := stats.ServerCount / step * step
+		next := reached + step
+		add("マイルストーン", fmt.Sprintf("達成: %d\n次: %d（あと%d）", reached, next, next-stats.ServerCount), true)
+	}
+
+	if note, exists := config.BotNotes[botID]; exists {
+		add("メモ", note, true)
+	}
+
+	if len(history.Counts) > 1 {
+		low, high := countRange(history.Counts)
+		add(fmt.Sprintf("推移（直近%d回）", len(history.Counts)), fmt.Sprintf("`%s`\n%d〜%d", sparkline(history.Counts), low, high), false)
+	}
+
+	return embed
+}
+
+// sparkline draws counts as block characters scaled between their minimum
+// and maximum.
+func sparkline(counts []int) string {
+	low, high := countRange(counts)
+	var line strings.Builder
+	for _, count := range counts {
+		level := len(sparkBlocks) / 2
+		if high > low {
+			level = (count - low) * (len(sparkBlocks) - 1) / (high - low)
+		}
+		line.WriteRune(sparkBlocks[level])
+	}
+	return line.String()
+}
+
+func countRange(counts []int) (low, high int) {
+	low, high = counts[0], counts[0]
+	for _, count := range counts {
+		low, high = min(low, count), max(high, count)
+	}
+	return low, high
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var (
+	hostMu       sync.Mutex
+	hostNextSlot = make(map[string]time.Time)
+)
+
+// waitForHost blocks until the next request to rawURL's host is allowed by
+// HOST_RATE_LIMIT. Requests to different hosts don't wait on each other.
+func waitForHost(rawURL string) {
+	if config.HostRateLimit <= 0 {
+		return
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+
+	interval := time.Second / time.Duration(config.HostRateLimit)
+
+	hostMu.Lock()
+	now := time.Now()
+	slot := hostNextSlot[parsed.Host]
+	if slot.Before(now) {
+		slot = now
+	}
+	hostNextSlot[parsed.Host] = slot.Add(interval)
+	hostMu.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
+
+// fetchResult is the stats of the bot at index in the configured bot list.
+type fetchResult struct {
+	index int
+	stats BotStats
+}
+
+// fetchAllStats collects stats for every bot with up to FETCH_CONCURRENCY
+// fetches in flight. When FETCH_DEADLINE passes, bots that haven't finished
+// are reported as timed out and the rest of the results are used as-is.
+// With PARTIAL_REPORTS they are marked pending instead, and their results
+// are delivered on the returned channel as they arrive; it is nil when
+// every bot finished in time.
+func fetchAllStats(botIDs []string) ([]BotStats, <-chan fetchResult) {
+	concurrency := config.FetchConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// Buffered so fetches that finish after the deadline don't block
+	results := make(chan fetchResult, len(botIDs))
+	stop := make(chan struct{})
+
+	go func() {
+		slots := make(chan struct{}, concurrency)
+		for i, botID := range botIDs {
+			select {
+			case slots <- struct{}{}:
+			case <-stop:
+				return
+			}
+			go func(i int, botID string) {
+				defer func() { <-slots }()
+				results <- fetchResult{index: i, stats: collectBotStats(botID)}
+			}(i, botID)
+		}
+	}()
+
+	var deadline <-chan time.Time
+	if config.FetchDeadline > 0 {
+		timer := time.NewTimer(config.FetchDeadline)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	allStats := make([]BotStats, len(botIDs))
+	done := make([]bool, len(botIDs))
+
+	for received := 0; received < len(botIDs); received++ {
+		select {
+		case r := <-results:
+			allStats[r.index] = r.stats
+			done[r.index] = true
+		case <-deadline:
+			log.Printf("Fetch deadline of %v passed with %d of %d bots done", config.FetchDeadline, received, len(botIDs))
+
+			if config.PartialReports {
+				for i, botID := range botIDs {
+					if !done[i] {
+						allStats[i] = BotStats{BotID: botID, BotName: "Unknown", Pending: true}
+					}
+				}
+
+				// Buffered so the forwarder finishes even if nobody reads
+				late := make(chan fetchResult, len(botIDs)-received)
+				go func(remaining int) {
+					defer close(late)
+					for ; remaining > 0; remaining-- {
+						late <- <-results
+					}
+				}(len(botIDs) - received)
+				return allStats, late
+			}
+
+			close(stop)
+			for i, botID := range botIDs {
+				if !done[i] {
+					allStats[i] = BotStats{
+						BotID:   botID,
+						BotName: "Unknown",
+						Error:   wrapKind(ErrTimeout, fmt.Errorf("fetch deadline of %v passed", config.FetchDeadline)),
+					}
+				}
+			}
+			return allStats, nil
+		}
+	}
+
+	return allStats, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// BenchmarkFetchAllStats runs the fetch pipeline against a local custom
+// webhook for every bot, so it measures statbot's own overhead rather than
+// the bot lists.
+func BenchmarkFetchAllStats(b *testing.B) {
+	for _, bots := range []int{100, 500} {
+		b.Run(fmt.Sprintf("%d bots", bots), func(b *testing.B) {
+			fakeDiscord(b, 0)
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"server_count": 1234}`))
+			}))
+			b.Cleanup(webhook.Close)
+
+			config.TargetBotIDs = nil
+			config.CustomWebhooks = make(map[string]string)
+			for i := 0; i < bots; i++ {
+				botID := fmt.Sprintf("1000000000000%05d", i)
+				config.TargetBotIDs = append(config.TargetBotIDs, botID)
+				config.CustomWebhooks[botID] = webhook.URL
+			}
+			config.FetchConcurrency = 16
+
+			output := log.Writer()
+			log.SetOutput(io.Discard)
+			b.Cleanup(func() { log.SetOutput(output) })
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				allStats, _ := fetchAllStats(config.TargetBotIDs)
+				if allStats[0].ServerCount != 1234 {
+					b.Fatalf("fetched %+v", allStats[0])
+				}
+			}
+		})
+	}
+}
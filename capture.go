@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Captured bodies are cut off after this many bytes.
+const maxCaptureSize = 16 * 1024
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// captureResponse writes the raw response of a failed source fetch to
+// DEBUG_CAPTURE_DIR so "could not parse" style errors can be diagnosed.
+// Configured tokens are redacted and old captures are pruned.
+func captureResponse(source, botID, url string, status int, body []byte, fetchErr error) {
+	if config.CaptureDir == "" {
+		return
+	}
+
+	if err := os.MkdirAll(config.CaptureDir, 0o700); err != nil {
+		log.Printf("Error creating capture directory: %v", err)
+		return
+	}
+
+	truncated := false
+	if len(body) > maxCaptureSize {
+		body = body[:maxCaptureSize]
+		truncated = true
+	}
+
+	now := time.Now()
+	var content strings.Builder
+	fmt.Fprintf(&content, "Time: %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&content, "Source: %s\n", source)
+	fmt.Fprintf(&content, "Bot ID: %s\n", botID)
+	fmt.Fprintf(&content, "URL: %s\n", url)
+	fmt.Fprintf(&content, "Status: %d\n", status)
+	fmt.Fprintf(&content, "Error: %v\n", fetchErr)
+	if truncated {
+		fmt.Fprintf(&content, "Body truncated to %d bytes\n", maxCaptureSize)
+	}
+	content.WriteString("\n")
+	content.Write(body)
+
+	name := fmt.Sprintf("%s_%s_%s.txt", now.Format("20060102-150405"), unsafeFileChars.ReplaceAllString(botID, ""), source)
+	path := filepath.Join(config.CaptureDir, name)
+	if err := os.WriteFile(path, []byte(redactSecrets(content.String())), 0o600); err != nil {
+		log.Printf("Error writing response capture: %v", err)
+		return
+	}
+	log.Printf("Captured failed %s response for bot %s to %s", source, botID, path)
+
+	pruneCaptures()
+}
+
+// redactSecrets replaces every configured token in the text.
+func redactSecrets(text string) string {
+	secrets := []string{config.DiscordToken, config.TopGGToken, config.PatreonToken, config.GitHubToken}
+	for _, token := range config.BotTokens {
+		secrets = append(secrets, token)
+	}
+	for _, source := range config.GraphQLSources {
+		secrets = append(secrets, source.Token)
+	}
+
+	for _, secret := range secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, "[REDACTED]")
+		}
+	}
+	return text
+}
+
+func pruneCaptures() {
+	entries, err := os.ReadDir(config.CaptureDir)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -config.CaptureDays)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".txt") {
+			continue
+		}
+		if info.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(config.CaptureDir, entry.Name()))
+		}
+	}
+}
+
+// captureInfo describes a saved capture in the /api/debug listing.
+type captureInfo struct {
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+}
+
+// handleDebugCaptures lists the captured responses at GET /api/debug,
+// newest first, and serves one at /api/debug/{name}. Callers authenticate
+// with "Authorization: Bearer DEBUG_TOKEN", like the top.gg proxy.
+func handleDebugCaptures(w http.ResponseWriter, r *http.Request) {
+	if !bearerAuthorized(r, config.DebugToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/debug"), "/")
+	if name != "" {
+		// Only capture files, never paths outside the directory
+		if filepath.Base(name) != name || !strings.HasSuffix(name, ".txt") {
+			http.NotFound(w, r)
+			return
+		}
+		content, err := os.ReadFile(filepath.Join(config.CaptureDir, name))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(content)
+		return
+	}
+
+	entries, err := os.ReadDir(config.CaptureDir)
+	if err != nil && !os.IsNotExist(err) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	captures := []captureInfo{}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".txt") {
+			continue
+		}
+		captures = append(captures, captureInfo{Name: entry.Name(), Time: info.ModTime(), Size: info.Size()})
+	}
+	sort.Slice(captures, func(i, j int) bool { return captures[i].Time.After(captures[j].Time) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"captures": captures})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleDebugCaptures(t *testing.T) {
+	previousConfig := config
+	t.Cleanup(func() { config = previousConfig })
+	config.CaptureDir, config.DebugToken = t.TempDir(), "secret"
+
+	older, newer := "20260101-090000_1_topgg.txt", "20260102-090000_1_dbl.txt"
+	for i, name := range []string{older, newer} {
+		path := filepath.Join(config.CaptureDir, name)
+		if err := os.WriteFile(path, []byte("Source: "+name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		at := time.Date(2026, 1, 1+i, 9, 0, 0, 0, time.UTC)
+		os.Chtimes(path, at, at)
+	}
+	os.WriteFile(filepath.Join(filepath.Dir(config.CaptureDir), "outside.txt"), []byte("private"), 0o600)
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"no token", "/api/debug", "", http.StatusUnauthorized, ""},
+		{"wrong token", "/api/debug", "nope", http.StatusUnauthorized, ""},
+		{"listing", "/api/debug", "secret", http.StatusOK, newer},
+		{"capture", "/api/debug/" + older, "secret", http.StatusOK, "Source: " + older},
+		{"missing capture", "/api/debug/20260103-090000_1_dbl.txt", "secret", http.StatusNotFound, ""},
+		{"outside the directory", "/api/debug/..%2Foutside.txt", "secret", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.token != "" {
+				request.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			recorder := httptest.NewRecorder()
+			handleDebugCaptures(recorder, request)
+
+			if recorder.Code != test.wantStatus {
+				t.Fatalf("status %d, want %d: %s", recorder.Code, test.wantStatus, recorder.Body)
+			}
+			if !strings.Contains(recorder.Body.String(), test.wantBody) {
+				t.Errorf("body has no %q: %s", test.wantBody, recorder.Body)
+			}
+		})
+	}
+
+	// The listing is newest first
+	request := httptest.NewRequest(http.MethodGet, "/api/debug", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	recorder := httptest.NewRecorder()
+	handleDebugCaptures(recorder, request)
+	var listing struct {
+		Captures []captureInfo `json:"captures"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&listing); err != nil {
+		t.Fatal(err)
+	}
+	if len(listing.Captures) != 2 || listing.Captures[0].Name != newer {
+		t.Errorf("listing = %+v, want %s first", listing.Captures, newer)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Channel settings accept either a channel ID or a name reference:
+// "#name" (looked up in CHANNEL_GUILD_ID) or "GUILD_ID/#name". Name
+// references are resolved on first use and re-resolved when the channel is
+// deleted, so a recreated channel is picked up automatically.
+var (
+	channelMu        sync.Mutex
+	resolvedChannels = make(map[string]string) // Reference -> Channel ID
+)
+
+func isChannelName(ref string) bool {
+	return strings.Contains(ref, "#")
+}
+
+func resolveChannel(ref string) (string, error) {
+	if !isChannelName(ref) {
+		return ref, nil
+	}
+
+	channelMu.Lock()
+	defer channelMu.Unlock()
+
+	if channelID, exists := resolvedChannels[ref]; exists {
+		return channelID, nil
+	}
+
+	guildID, name, found := strings.Cut(ref, "/#")
+	if !found {
+		guildID = config.ChannelGuildID
+		name = strings.TrimPrefix(ref, "#")
+	}
+	if guildID == "" {
+		return "", fmt.Errorf("channel %q needs a guild: set CHANNEL_GUILD_ID or use GUILD_ID/#name", ref)
+	}
+
+	channels, err := session.GuildChannels(guildID)
+	if err != nil {
+		return "", fmt.Errorf("failed to list channels of guild %s: %v", guildID, err)
+	}
+
+	for _, channel := range channels {
+		if channel.Name == name && (channel.Type == discordgo.ChannelTypeGuildText || channel.Type == discordgo.ChannelTypeGuildNews) {
+			resolvedChannels[ref] = channel.ID
+			log.Printf("Resolved channel %s to %s", ref, channel.ID)
+			return channel.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("no text channel named %q in guild %s", name, guildID)
+}
+
+// forgetChannel drops cached resolutions pointing at a channel ID.
+func forgetChannel(channelID string) {
+	channelMu.Lock()
+	defer channelMu.Unlock()
+
+	for ref, resolvedID := range resolvedChannels {
+		if resolvedID == channelID {
+			delete(resolvedChannels, ref)
+			log.Printf("Channel %s (%s) was removed, it will be resolved again on next use", ref, channelID)
+		}
+	}
+}
+
+func channelDelete(s *discordgo.Session, event *discordgo.ChannelDelete) {
+	forgetChannel(event.ID)
+}
+
+func isUnknownChannel(err error) bool {
+	var restErr *discordgo.RESTError
+	return errors.As(err, &restErr) && restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownChannel
+}
+
+// sendChannelMessage sends a message to a channel reference after checking
+// the watcher may post there. If a named channel has disappeared it is
+// resolved again and the send retried once.
+func sendChannelMessage(ref, content string) (*discordgo.Message, error) {
+	return sendChannelMessageComplex(ref, &discordgo.MessageSend{Content: content})
+}
+
+// sendChannelMessageComplex is sendChannelMessage for messages with more
+// than content, such as components.
+func sendChannelMessageComplex(ref string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	if dryRun("discord") {
+		return dryRunMessage(ref, data.Content), nil
+	}
+
+	channelID, err := resolveChannel(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSendPermissions(channelID); err != nil {
+		return nil, err
+	}
+
+	message, err := session.ChannelMessageSendComplex(channelID, data)
+	if err != nil && isChannelName(ref) && isUnknownChannel(err) {
+		forgetChannel(channelID)
+		if channelID, err = resolveChannel(ref); err != nil {
+			return nil, err
+		}
+		message, err = session.ChannelMessageSendComplex(channelID, data)
+	}
+	return message, err
+}
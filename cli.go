@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+const cliUsage = `Usage: statbot [command]
+
+Without a command the bot runs and sends scheduled notifications.
+
+Commands:
+  init                    Interactively create a .env file, validating each value against Discord
+  migrate-config [file]   Print the statbot.yaml equivalent of a .env file (default: .env)
+  test-alert [severity]   Send a test alert (info, warn or critical) through the alert routes
+  merge-bots DUPLICATE_ID CANONICAL_ID [file]
+                          Print the configuration with a duplicate bot merged into its canonical
+                          ID, and move its history in REQUEST_LOG, SNAPSHOT_FILE and HISTORY_DB
+                          (default file: CONFIG_FILE or .env)
+  compare BOT DATE1 DATE2 Show how every metric of a bot changed between its SNAPSHOT_FILE snapshots
+                          on two dates (YYYY-MM-DD)
+  status                  Show the report schedules with their next run and the recent runs
+                          recorded in STATE_FILE`
+
+// runCLI executes a one-off command using the loaded configuration.
+func runCLI(args []string) {
+	switch args[0] {
+	case "test-alert":
+		severity := SeverityInfo
+		if len(args) > 1 {
+			parsed, err := parseSeverity(args[1])
+			if err != nil {
+				log.Fatal(err)
+			}
+			severity = parsed
+		}
+		fmt.Println(sendTestAlert(severity))
+		hooksRunning.Wait()
+	case "merge-bots":
+		runMergeBots(args[1:])
+	case "compare":
+		runCompare(args[1:])
+	case "status":
+		fmt.Print(statusSummary(true))
+	case "help", "-h", "--help":
+		fmt.Println(cliUsage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n%s\n", args[0], cliUsage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// collection is one fetch of every bot. Report runs due at the same time
+// share a collection, so each tick fetches the bots and runs changes,
+// history, alerts and the feed over them once, however many schedules
+// report it.
+type collection struct {
+	ready   chan struct{} // Closed once the stats are collected
+	stats   []BotStats
+	network *NetworkStats
+
+	mu          sync.Mutex
+	subscribers []chan fetchResult
+	finished    bool // Every late result has arrived
+}
+
+var (
+	collectionsMu sync.Mutex
+	collections   = make(map[int64]*collection) // Tick (Unix minute) -> Its collection
+)
+
+// collectTick returns the stats for a run due at the tick, fetching them
+// unless another run due then already has. A zero tick always fetches.
+// The stats are the caller's own copy, and bots still pending arrive
+// later on the returned channel, processed already.
+func collectTick(tick time.Time) ([]BotStats, *NetworkStats, <-chan fetchResult) {
+	if tick.IsZero() {
+		return newCollection().subscribe()
+	}
+
+	key := tick.Truncate(time.Minute).Unix()
+	collectionsMu.Lock()
+	shared, exists := collections[key]
+	if !exists {
+		shared = &collection{ready: make(chan struct{})}
+		collections[key] = shared
+		for other := range collections {
+			if key-other > int64(time.Hour/time.Second) {
+				delete(collections, other)
+			}
+		}
+	}
+	collectionsMu.Unlock()
+
+	if !exists {
+		shared.collect()
+	}
+	<-shared.ready
+	return shared.subscribe()
+}
+
+func newCollection() *collection {
+	c := &collection{ready: make(chan struct{})}
+	c.collect()
+	return c
+}
+
+func (c *collection) collect() {
+	stats, network, late := collectStats()
+	c.stats, c.network = stats, network
+	if late == nil {
+		c.finished = true
+	} else {
+		go c.processLate(late)
+	}
+	close(c.ready)
+}
+
+// processLate runs the per-run processing over each late result once and
+// passes it on to every run using the collection.
+func (c *collection) processLate(late <-chan fetchResult) {
+	for result := range late {
+		c.mu.Lock()
+		processLateResult(c.stats, result)
+		result.stats = c.stats[result.index]
+		for _, subscriber := range c.subscribers {
+			subscriber <- result
+		}
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finished = true
+	for _, subscriber := range c.subscribers {
+		close(subscriber)
+	}
+}
+
+// subscribe returns a copy of the stats so far and, unless every result
+// is in, a channel of the results still to come.
+func (c *collection) subscribe() ([]BotStats, *NetworkStats, <-chan fetchResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make([]BotStats, len(c.stats))
+	copy(stats, c.stats)
+	if c.finished {
+		return stats, c.network, nil
+	}
+
+	// Buffered for every bot so a run that stops reading never holds up
+	// the others
+	late := make(chan fetchResult, len(c.stats))
+	c.subscribers = append(c.subscribers, late)
+	return stats, c.network, late
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Slash commands are restricted to members who can manage the server.
+var manageGuildPermission int64 = discordgo.PermissionManageServer
+
+var commands = []*discordgo.ApplicationCommand{
+	{
+		Name:                     "watch",
+		Description:              "statbotの管理コマンド",
+		DefaultMemberPermissions: &manageGuildPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "test-alert",
+				Description: "テストアラートを送信して通知設定を確認します",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "severity",
+						Description: "アラートの重要度（デフォルト: info）",
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "info", Value: "info"},
+							{Name: "warn", Value: "warn"},
+							{Name: "critical", Value: "critical"},
+						},
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "metrics",
+				Description: "statbot自身の動作状況（実行時間、送信の成功率、スケジュールのずれ）を表示します",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "status",
+				Description: "スケジュールと次回の実行時刻、最近の実行結果を表示します",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "requests",
+				Description: "直近の外部リクエストを送信先ホストごとに集計して表示します",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "silence",
+				Description: "botのアラートを一定時間ミュートします",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:         discordgo.ApplicationCommandOptionString,
+						Name:         "bot",
+						Description:  "botのIDまたは名前（すべてのbotは all）",
+						Required:     true,
+						Autocomplete: true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "duration",
+						Description: "ミュートする時間（例: 30m, 2h, 1d）",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "rule",
+						Description: "ミュートするルール（fetch, anomaly, badge またはアラートルール名、省略時はすべて）",
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:                     "compare",
+		Description:              "botの2つの日付のスナップショットを比較します",
+		DefaultMemberPermissions: &manageGuildPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         "bot",
+				Description:  "botのIDまたは名前",
+				Required:     true,
+				Autocomplete: true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "date1",
+				Description: "比較元の日付（YYYY-MM-DD）",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "date2",
+				Description: "比較先の日付（YYYY-MM-DD）",
+				Required:    true,
+			},
+		},
+	},
+}
+
+func registerCommands(s *discordgo.Session) {
+	registered := append(commands[:len(commands):len(commands)], botCommand())
+	if _, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, config.CommandGuildID, registered); err != nil {
+		log.Printf("Error registering slash commands: %v", err)
+		return
+	}
+	log.Printf("Registered %d slash commands", len(registered))
+}
+
+func interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type == discordgo.InteractionMessageComponent {
+		customID := i.MessageComponentData().CustomID
+		if group, ok := isPublishButton(customID); ok {
+			handlePublishButton(s, i, group)
+		} else if id, approve, ok := isApprovalButton(customID); ok {
+			handleApprovalButton(s, i, id, approve)
+		} else if id, ok := isAckButton(customID); ok {
+			handleAckButton(s, i, id)
+		} else if id, confirm, ok := isOpsButton(customID); ok {
+			handleOpsButton(s, i, id, confirm)
+		}
+		return
+	}
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		handleAutocomplete(s, i)
+		return
+	}
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	if len(data.Options) == 0 {
+		return
+	}
+	if data.Name == "bot" {
+		handleBotCommand(s, i, data.Options[0])
+		return
+	}
+	if data.Name == "compare" {
+		handleCompareCommand(s, i, data.Options)
+		return
+	}
+	if data.Name != "watch" {
+		return
+	}
+
+	subcommand := data.Options[0]
+	switch subcommand.Name {
+	case "test-alert":
+		handleTestAlertCommand(s, i, subcommand.Options)
+	case "metrics":
+		respondEphemeral(s, i, metrics.summary())
+	case "status":
+		respondEphemeral(s, i, "📋 **statbotの状態**\n"+statusSummary(false))
+	case "requests":
+		respondEphemeral(s, i, requestSummary())
+	case "silence":
+		handleSilenceCommand(s, i, subcommand.Options)
+	}
+}
+
+func handleTestAlertCommand(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	severity := SeverityInfo
+	for _, option := range options {
+		if option.Name == "severity" {
+			parsed, err := parseSeverity(option.StringValue())
+			if err != nil {
+				respondEphemeral(s, i, err.Error())
+				return
+			}
+			severity = parsed
+		}
+	}
+
+	// Delivery can take longer than the interaction deadline
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	})
+	if err != nil {
+		log.Printf("Error deferring interaction response: %v", err)
+		return
+	}
+
+	summary := sendTestAlert(severity)
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &summary}); err != nil {
+		log.Printf("Error editing interaction response: %v", err)
+	}
+}
+
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// countCommandPrefix names the per-bot variables holding count commands,
+// e.g. COUNT_COMMAND_<BOT_ID>="ssh bot-host sqlite3 /srv/bot/data.db 'SELECT COUNT(*) FROM guilds'".
+const countCommandPrefix = "COUNT_COMMAND_"
+
+// getServerCountFromCommand runs the bot's count command with sh and parses
+// its output: either a bare number or a JSON object with one of the field
+// names custom webhooks use. Remote hosts are reached by making the command
+// an ssh invocation.
+func getServerCountFromCommand(botID, command string, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(cmd.Environ(), "BOT_ID="+botID)
+	cmd.WaitDelay = time.Second // Don't wait on children (e.g. ssh) still holding stdout
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return 0, wrapKind(ErrTimeout, fmt.Errorf("count command timed out after %v", timeout))
+		}
+		err = fmt.Errorf("count command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+		captureResponse("command", botID, "", 0, stdout.Bytes(), err)
+		return 0, err
+	}
+
+	output := bytes.TrimSpace(stdout.Bytes())
+	if count, err := strconv.Atoi(string(output)); err == nil {
+		return count, nil
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(output, &result); err == nil {
+		if count, found := countFromFields(result); found {
+			return count, nil
+		}
+	}
+
+	err := fmt.Errorf("could not parse server count from command output")
+	captureResponse("command", botID, "", 0, output, err)
+	return 0, err
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const computedPrefix = "COMPUTED_"
+
+// Computed metrics are read from COMPUTED_<NAME>=expression, e.g.
+// COMPUTED_INSTALLS_PER_SERVER="user_installs / server_count". Expressions
+// use the rule metrics of a bot, numbers, + - * / and parentheses, and the
+// aggregates sum, avg, min, max and count over every bot fetched in the run.
+// A metric that only uses aggregates is a network-wide total.
+
+// ComputedMetric is a parsed COMPUTED_<NAME> expression.
+type ComputedMetric struct {
+	Name       string
+	Expression string
+	PerBot     bool // Evaluated for every bot, rather than once for the network
+
+	root exprNode
+}
+
+var (
+	computedMu     sync.RWMutex
+	computedTotals = make(map[string]float64) // Metric name -> Latest network-wide value
+)
+
+// loadComputedMetrics parses every COMPUTED_<NAME> in name order. Invalid
+// expressions are reported by validateEnv before this runs.
+func loadComputedMetrics() []ComputedMetric {
+	var computed []ComputedMetric
+	for name, expression := range getEnvPerBot(computedPrefix) {
+		root, err := parseMetricExpr(expression)
+		if err != nil {
+			continue
+		}
+		computed = append(computed, ComputedMetric{
+			Name:       strings.ToLower(name),
+			Expression: expression,
+			PerBot:     root.perBot(),
+			root:       root,
+		})
+	}
+	sort.Slice(computed, func(i, j int) bool { return computed[i].Name < computed[j].Name })
+	return computed
+}
+
+// validateComputedMetrics checks that every COMPUTED_<NAME> parses and does
+// not shadow a built-in metric.
+func validateComputedMetrics(problems *configProblems) {
+	var names []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, computedPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		metric := strings.ToLower(strings.TrimPrefix(name, computedPrefix))
+		if _, builtin := ruleMetrics[metric]; builtin || metric == "" {
+			problems.add(name, "%q is not a usable metric name", metric)
+		}
+		if _, err := parseMetricExpr(os.Getenv(name)); err != nil {
+			problems.add(name, "%v", err)
+		}
+	}
+}
+
+func computedMetric(name string) (ComputedMetric, bool) {
+	for _, metric := range config.ComputedMetrics {
+		if metric.Name == name {
+			return metric, true
+		}
+	}
+	return ComputedMetric{}, false
+}
+
+// computeMetrics evaluates the computed metrics after a sample. Per-bot
+// metrics are stored on each bot fetched successfully; a metric whose value
+// is unknown (a missing input, or a division by zero) is left out. Private
+// bots get their own metrics but are not part of the aggregates.
+func computeMetrics(allStats []BotStats) {
+	if len(config.ComputedMetrics) == 0 {
+		return
+	}
+
+	var sample []BotStats
+	for _, stats := range allStats {
+		if stats.Error == nil && !stats.Pending && !config.PrivateBots[stats.BotID] {
+			sample = append(sample, stats)
+		}
+	}
+
+	totals := make(map[string]float64)
+	for _, metric := range config.ComputedMetrics {
+		if metric.PerBot {
+			continue
+		}
+		if value, known := metric.root.eval(exprScope{sample: sample}); known {
+			totals[metric.Name] = value
+		}
+	}
+
+	for i := range allStats {
+		stats := &allStats[i]
+		if stats.Error != nil || stats.Pending {
+			continue
+		}
+		stats.Computed = make(map[string]float64)
+		for _, metric := range config.ComputedMetrics {
+			if !metric.PerBot {
+				continue
+			}
+			if value, known := metric.root.eval(exprScope{bot: stats, sample: sample}); known {
+				stats.Computed[metric.Name] = value
+			}
+		}
+	}
+
+	computedMu.Lock()
+	computedTotals = totals
+	computedMu.Unlock()
+}
+
+// latestComputedTotals returns the network-wide metrics of the latest run.
+func latestComputedTotals() map[string]float64 {
+	computedMu.RLock()
+	defer computedMu.RUnlock()
+	return computedTotals
+}
+
+// formatComputed renders computed metrics in a stable order, leaving out
+// the ones a public destination may not show.
+func formatComputed(values map[string]float64, public bool) string {
+	fields := make(map[string]string)
+	for name, value := range values {
+		if !public || config.PublicMetrics[name] {
+			fields[name] = formatRuleValue(math.Round(value*100) / 100)
+		}
+	}
+	return formatFields(fields)
+}
+
+// exprScope is what an expression is evaluated against: the bot for per-bot
+// metrics, and the bots the aggregates run over.
+type exprScope struct {
+	bot    *BotStats
+	sample []BotStats
+}
+
+type exprNode interface {
+	eval(scope exprScope) (float64, bool)
+	perBot() bool // Whether the node reads a metric of the bot being evaluated
+}
+
+type numberNode float64
+
+func (n numberNode) eval(exprScope) (float64, bool) { return float64(n), true }
+func (n numberNode) perBot() bool                   { return false }
+
+type metricNode string
+
+func (n metricNode) eval(scope exprScope) (float64, bool) {
+	if scope.bot == nil {
+		return 0, false
+	}
+	return ruleMetrics[string(n)](*scope.bot)
+}
+
+func (n metricNode) perBot() bool { return true }
+
+type negateNode struct{ operand exprNode }
+
+func (n negateNode) eval(scope exprScope) (float64, bool) {
+	value, known := n.operand.eval(scope)
+	return -value, known
+}
+
+func (n negateNode) perBot() bool { return n.operand.perBot() }
+
+type binaryNode struct {
+	operator    byte
+	left, right exprNode
+}
+
+func (n binaryNode) eval(scope exprScope) (float64, bool) {
+	left, known := n.left.eval(scope)
+	if !known {
+		return 0, false
+	}
+	right, known := n.right.eval(scope)
+	if !known {
+		return 0, false
+	}
+	switch n.operator {
+	case '+':
+		return left + right, true
+	case '-':
+		return left - right, true
+	case '*':
+		return left * right, true
+	default:
+		if right == 0 {
+			return 0, false
+		}
+		return left / right, true
+	}
+}
+
+func (n binaryNode) perBot() bool { return n.left.perBot() || n.right.perBot() }
+
+// aggregateNode is sum, avg, min, max or count of a metric over the bots
+// that have it.
+type aggregateNode struct {
+	function string
+	metric   string
+}
+
+var aggregateFunctions = map[string]bool{"sum": true, "avg": true, "min": true, "max": true, "count": true}
+
+func (n aggregateNode) eval(scope exprScope) (float64, bool) {
+	var values []float64
+	for _, stats := range scope.sample {
+		if value, known := ruleMetrics[n.metric](stats); known {
+			values = append(values, value)
+		}
+	}
+	if n.function == "count" {
+		return float64(len(values)), true
+	}
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	result := values[0]
+	for _, value := range values[1:] {
+		switch n.function {
+		case "sum", "avg":
+			result += value
+		case "min":
+			result = min(result, value)
+		case "max":
+			result = max(result, value)
+		}
+	}
+	if n.function == "avg" {
+		result /= float64(len(values))
+	}
+	return result, true
+}
+
+func (n aggregateNode) perBot() bool { return false }
+
+// parseMetricExpr parses a computed metric expression:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = "-" unary | primary
+//	primary = number | metric | function "(" metric ")" | "(" expr ")"
+func parseMetricExpr(expression string) (exprNode, error) {
+	p := &exprParser{input: expression}
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos:], p.pos+1)
+	}
+	return node, nil
+}
+
+type exprParser struct {
+	input string
+	pos   int
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// accept consumes the next character if it is one of chars.
+func (p *exprParser) accept(chars string) (byte, bool) {
+	p.skipSpace()
+	if p.pos < len(p.input) && strings.IndexByte(chars, p.input[p.pos]) >= 0 {
+		p.pos++
+		return p.input[p.pos-1], true
+	}
+	return 0, false
+}
+
+func (p *exprParser) parseExpr() (exprNode, error) {
+	node, err := p.parseTerm()
+	for err == nil {
+		operator, ok := p.accept("+-")
+		if !ok {
+			break
+		}
+		var right exprNode
+		right, err = p.parseTerm()
+		node = binaryNode{operator: operator, left: node, right: right}
+	}
+	return node, err
+}
+
+func (p *exprParser) parseTerm() (exprNode, error) {
+	node, err := p.parseUnary()
+	for err == nil {
+		operator, ok := p.accept("*/")
+		if !ok {
+			break
+		}
+		var right exprNode
+		right, err = p.parseUnary()
+		node = binaryNode{operator: operator, left: node, right: right}
+	}
+	return node, err
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if _, ok := p.accept("-"); ok {
+		operand, err := p.parseUnary()
+		return negateNode{operand}, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	if _, ok := p.accept("("); ok {
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("missing ) at position %d", p.pos+1)
+		}
+		return node, nil
+	}
+
+	p.skipSpace()
+	start := p.pos
+	if p.pos < len(p.input) && (p.input[p.pos] == '.' || isDigit(p.input[p.pos])) {
+		for p.pos < len(p.input) && (p.input[p.pos] == '.' || isDigit(p.input[p.pos])) {
+			p.pos++
+		}
+		value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", p.input[start:p.pos])
+		}
+		return numberNode(value), nil
+	}
+
+	name := p.identifier()
+	if name == "" {
+		if p.pos >= len(p.input) {
+			return nil, fmt.Errorf("unexpected end of expression")
+		}
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos:p.pos+1], p.pos+1)
+	}
+
+	if _, ok := p.accept("("); ok {
+		if !aggregateFunctions[name] {
+			return nil, fmt.Errorf("unknown function %q (expected sum, avg, min, max or count)", name)
+		}
+		p.skipSpace()
+		metric := p.identifier()
+		if _, known := ruleMetrics[metric]; !known {
+			return nil, fmt.Errorf("%s() needs a metric, got %q", name, metric)
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("missing ) at position %d", p.pos+1)
+		}
+		return aggregateNode{function: name, metric: metric}, nil
+	}
+
+	if _, known := ruleMetrics[name]; !known {
+		return nil, fmt.Errorf("unknown metric %q (expected server_count, change, change_percent, user_installs, patrons, votes, stars or open_issues)", name)
+	}
+	return metricNode(name), nil
+}
+
+func (p *exprParser) identifier() string {
+	start := p.pos
+	for p.pos < len(p.input) && isIdentChar(p.input[p.pos], p.pos > start) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+func isIdentChar(c byte, inside bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (inside && isDigit(c))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
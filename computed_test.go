@@ -0,0 +1,133 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseMetricExpr(t *testing.T) {
+	bot := BotStats{BotID: "1", ServerCount: 200, Change: 50, HasChange: true, UserInstalls: 30, HasUserInstalls: true}
+	sample := []BotStats{
+		bot,
+		{BotID: "2", ServerCount: 100, Patrons: 4, HasPatrons: true},
+		{BotID: "3", ServerCount: 300},
+	}
+
+	tests := []struct {
+		expression string
+		bot        *BotStats
+		want       float64
+		known      bool
+		perBot     bool
+	}{
+		// Precedence and associativity
+		{"1 + 2 * 3", nil, 7, true, false},
+		{"(1 + 2) * 3", nil, 9, true, false},
+		{"10 - 4 - 3", nil, 3, true, false},
+		{"8 / 4 / 2", nil, 1, true, false},
+		{"-2 * 3", nil, -6, true, false},
+		{"2 - -3", nil, 5, true, false},
+		{"1.5*2", nil, 3, true, false},
+
+		// Per-bot metrics
+		{"server_count", &bot, 200, true, true},
+		{"user_installs / server_count", &bot, 0.15, true, true},
+		{"change_percent", &bot, 100 / 3.0, true, true},
+		{"patrons", &bot, 0, false, true},
+		{"server_count", nil, 0, false, true},
+
+		// Division by zero leaves the value unknown
+		{"1 / 0", nil, 0, false, false},
+		{"server_count / (change - 50)", &bot, 0, false, true},
+
+		// Aggregates run over the sample, whatever bot is evaluated
+		{"sum(server_count)", nil, 600, true, false},
+		{"avg(server_count)", &bot, 200, true, false},
+		{"min(server_count)", nil, 100, true, false},
+		{"max(server_count)", nil, 300, true, false},
+		{"count(patrons)", nil, 1, true, false},
+		{"sum(stars)", nil, 0, false, false},
+		{"count(stars)", nil, 0, true, false},
+		{"server_count / sum(server_count) * 100", &bot, 100 / 3.0, true, true},
+		{"sum(server_count) - max(server_count)", nil, 300, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			node, err := parseMetricExpr(test.expression)
+			if err != nil {
+				t.Fatalf("parseMetricExpr(%q): %v", test.expression, err)
+			}
+			if node.perBot() != test.perBot {
+				t.Errorf("perBot() = %v, want %v", node.perBot(), test.perBot)
+			}
+			got, known := node.eval(exprScope{bot: test.bot, sample: sample})
+			if known != test.known {
+				t.Fatalf("known = %v, want %v", known, test.known)
+			}
+			if known && math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseMetricExprErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"", "unexpected end of expression"},
+		{"guilds", `unknown metric "guilds"`},
+		{"server_count +", "unexpected end of expression"},
+		{"(1 + 2", "missing )"},
+		{"1 2", `unexpected "2"`},
+		{"median(server_count)", `unknown function "median"`},
+		{"sum(guilds)", `sum() needs a metric, got "guilds"`},
+		{"sum(server_count", "missing )"},
+		{"1..2", `"1..2" is not a number`},
+		{"server_count % 2", `unexpected "% 2"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := parseMetricExpr(test.expression)
+			if err == nil {
+				t.Fatalf("parseMetricExpr(%q) succeeded, want an error", test.expression)
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error %q does not mention %q", err, test.want)
+			}
+		})
+	}
+}
+
+func BenchmarkComputeMetrics(b *testing.B) {
+	previousConfig := config
+	b.Cleanup(func() { config = previousConfig })
+	config.ComputedMetrics = nil
+	for name, expression := range map[string]string{
+		"share":     "server_count / sum(server_count) * 100",
+		"growth":    "change_percent",
+		"average":   "avg(server_count)",
+		"installed": "user_installs / server_count",
+	} {
+		root, err := parseMetricExpr(expression)
+		if err != nil {
+			b.Fatal(err)
+		}
+		config.ComputedMetrics = append(config.ComputedMetrics, ComputedMetric{Name: name, Expression: expression, PerBot: root.perBot(), root: root})
+	}
+
+	allStats := make([]BotStats, 500)
+	for i := range allStats {
+		allStats[i] = BotStats{BotID: strconv.Itoa(i), ServerCount: 1000 + i, Change: i % 7, HasChange: true, UserInstalls: i, HasUserInstalls: true}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		computeMetrics(allStats)
+	}
+}
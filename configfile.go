@@ -0,0 +1,446 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// configVersion is the current statbot.yaml schema version. Files without a
+// version are the legacy flat format (the .env keys written as YAML) and are
+// migrated when loaded.
+const configVersion = 1
+
+const defaultConfigFile = "statbot.yaml"
+
+// ConfigFile is the versioned YAML configuration. Per-bot settings are
+// grouped under each bot instead of the BOT_ID:VALUE lists of the legacy
+// format; every other setting is kept under its environment variable name.
+type ConfigFile struct {
+	Version  int               `yaml:"version"`
+	Include  includeList       `yaml:"include,omitempty"`
+	Bots     []BotConfig       `yaml:"bots"`
+	Computed map[string]string `yaml:"computed,omitempty"` // Metric name -> Expression (COMPUTED_<NAME>)
+	Settings map[string]string `yaml:"settings,omitempty"`
+}
+
+// includeList is a single path or a list of paths.
+type includeList []string
+
+func (l *includeList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = includeList{node.Value}
+		return nil
+	}
+	var paths []string
+	if err := node.Decode(&paths); err != nil {
+		return err
+	}
+	*l = paths
+	return nil
+}
+
+var interpolationPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+type BotConfig struct {
+	ID              string `yaml:"id"`
+	Token           string `yaml:"token,omitempty"`
+	Webhook         string `yaml:"webhook,omitempty"`
+	CanonicalSource string `yaml:"canonical_source,omitempty"`
+	Note            string `yaml:"note,omitempty"`
+	Timeout         int    `yaml:"timeout,omitempty"`
+	PatreonCampaign string `yaml:"patreon_campaign,omitempty"`
+	GitHubRepo      string `yaml:"github_repo,omitempty"`
+	CountCommand    string `yaml:"count_command,omitempty"`
+	Private         bool   `yaml:"private,omitempty"`
+}
+
+// botListSettings are the legacy BOT_ID:VALUE lists and the BotConfig field
+// each one moves to.
+var botListSettings = []struct {
+	env   string
+	field func(*BotConfig) *string
+}{
+	{"BOT_TOKENS", func(b *BotConfig) *string { return &b.Token }},
+	{"CUSTOM_WEBHOOKS", func(b *BotConfig) *string { return &b.Webhook }},
+	{"CANONICAL_SOURCES", func(b *BotConfig) *string { return &b.CanonicalSource }},
+	{"PATREON_CAMPAIGNS", func(b *BotConfig) *string { return &b.PatreonCampaign }},
+	{"GITHUB_REPOS", func(b *BotConfig) *string { return &b.GitHubRepo }},
+}
+
+// loadConfigFile applies CONFIG_FILE (statbot.yaml by default) to the
+// environment. Variables that are already set win over the file, the same
+// way they win over .env. A missing default file is not an error.
+func loadConfigFile() {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		path = defaultConfigFile
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return
+		}
+	}
+
+	file, err := readConfigFile(path, true)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", path, err)
+	}
+
+	for key, value := range file.env() {
+		if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, value)
+		}
+	}
+	log.Printf("Loaded configuration from %s", path)
+}
+
+// readConfigFile parses a config file of any version and migrates it to the
+// current one. With resolve, ${VAR} and ${VAR:-default} references are
+// replaced from the environment and included files are merged in;
+// migrate-config leaves both as written so secrets stay out of its output.
+func readConfigFile(path string, resolve bool) (*ConfigFile, error) {
+	document, err := readConfigDocument(path, resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	var versioned struct {
+		Version int `yaml:"version"`
+	}
+	if err := document.Decode(&versioned); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case versioned.Version == 0:
+		var legacy map[string]string
+		if err := document.Decode(&legacy); err != nil {
+			return nil, fmt.Errorf("legacy format: %v", err)
+		}
+		log.Printf("%s has no version, migrating from the legacy format (run \"statbot migrate-config\" to update it)", path)
+		return migrateLegacyConfig(legacy), nil
+	case versioned.Version > configVersion:
+		return nil, fmt.Errorf("version %d is newer than this statbot supports (%d)", versioned.Version, configVersion)
+	}
+
+	var file ConfigFile
+	if err := document.Decode(&file); err != nil {
+		return nil, err
+	}
+	if resolve {
+		including := map[string]bool{absPath(path): true}
+		if err := resolveIncludes(&file, path, including); err != nil {
+			return nil, err
+		}
+	}
+	return &file, nil
+}
+
+func readConfigDocument(path string, interpolate bool) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if interpolate {
+		var missing []string
+		interpolateNode(&document, &missing)
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("environment variables referenced but not set: %s", strings.Join(missing, ", "))
+		}
+	}
+	return &document, nil
+}
+
+// interpolateNode replaces ${VAR} references in every scalar. Plain scalars
+// lose their tag so "timeout: ${TIMEOUT}" still decodes as a number.
+func interpolateNode(node *yaml.Node, missing *[]string) {
+	if node.Kind == yaml.ScalarNode {
+		expanded := interpolationPattern.ReplaceAllStringFunc(node.Value, func(reference string) string {
+			groups := interpolationPattern.FindStringSubmatch(reference)
+			if value, set := os.LookupEnv(groups[1]); set {
+				return value
+			}
+			if groups[2] == "" {
+				*missing = append(*missing, groups[1])
+			}
+			return groups[3]
+		})
+		if expanded != node.Value {
+			node.Value = expanded
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	}
+	for _, child := range node.Content {
+		interpolateNode(child, missing)
+	}
+}
+
+// resolveIncludes merges the files listed under include, relative to the
+// including file, into file. Included files are fragments of the current
+// version and may include others in turn. Settings of the including file
+// win; bots are appended and may only be defined once.
+func resolveIncludes(file *ConfigFile, path string, including map[string]bool) error {
+	includes := file.Include
+	file.Include = nil
+
+	for _, include := range includes {
+		includePath := include
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(path), include)
+		}
+		key := absPath(includePath)
+		if including[key] {
+			return fmt.Errorf("include %s: included recursively", include)
+		}
+
+		document, err := readConfigDocument(includePath, true)
+		if err != nil {
+			return fmt.Errorf("include %s: %v", include, err)
+		}
+		var fragment ConfigFile
+		if err := document.Decode(&fragment); err != nil {
+			return fmt.Errorf("include %s: %v", include, err)
+		}
+		if fragment.Version > configVersion {
+			return fmt.Errorf("include %s: version %d is newer than this statbot supports (%d)", include, fragment.Version, configVersion)
+		}
+
+		including[key] = true
+		err = resolveIncludes(&fragment, includePath, including)
+		delete(including, key)
+		if err != nil {
+			return err
+		}
+
+		defined := make(map[string]bool, len(file.Bots))
+		for _, bot := range file.Bots {
+			defined[bot.ID] = true
+		}
+		for _, bot := range fragment.Bots {
+			if defined[bot.ID] {
+				return fmt.Errorf("include %s: bot %s is already defined", include, bot.ID)
+			}
+			file.Bots = append(file.Bots, bot)
+		}
+		mergeMissing(&file.Settings, fragment.Settings)
+		mergeMissing(&file.Computed, fragment.Computed)
+	}
+	return nil
+}
+
+// mergeMissing copies the entries of src that dst does not set.
+func mergeMissing(dst *map[string]string, src map[string]string) {
+	for key, value := range src {
+		if _, set := (*dst)[key]; !set {
+			if *dst == nil {
+				*dst = make(map[string]string)
+			}
+			(*dst)[key] = value
+		}
+	}
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// migrateLegacyConfig converts the legacy environment variables into the
+// current schema: TARGET_BOT_ID(S) and the BOT_ID:VALUE lists become bot
+// entries, and everything else is carried over as-is.
+func migrateLegacyConfig(env map[string]string) *ConfigFile {
+	file := &ConfigFile{Version: configVersion, Settings: make(map[string]string)}
+
+	ids := env["TARGET_BOT_IDS"]
+	if ids == "" {
+		ids = env["TARGET_BOT_ID"]
+	}
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(ids, ",") {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			file.Bots = append(file.Bots, BotConfig{ID: id})
+		}
+	}
+	bots := make(map[string]*BotConfig)
+	for i := range file.Bots {
+		bots[file.Bots[i].ID] = &file.Bots[i]
+	}
+
+	handled := map[string]bool{"TARGET_BOT_IDS": true, "TARGET_BOT_ID": true, "BOT_TIMEOUTS": true, "PRIVATE_BOTS": true}
+	for _, setting := range botListSettings {
+		handled[setting.env] = true
+		for id, value := range parseBotPairs(env[setting.env]) {
+			if bot := bots[id]; bot != nil {
+				*setting.field(bot) = value
+			} else {
+				log.Printf("Dropping %s entry for %s, which is not in TARGET_BOT_IDS", setting.env, id)
+			}
+		}
+	}
+	for id, value := range parseBotPairs(env["BOT_TIMEOUTS"]) {
+		seconds, err := strconv.Atoi(value)
+		if bot := bots[id]; bot != nil && err == nil {
+			bot.Timeout = seconds
+		} else {
+			log.Printf("Dropping BOT_TIMEOUTS entry for %s", id)
+		}
+	}
+
+	for _, id := range strings.Split(env["PRIVATE_BOTS"], ",") {
+		id = strings.TrimSpace(id)
+		if bot := bots[id]; bot != nil {
+			bot.Private = true
+		} else if id != "" {
+			log.Printf("Dropping PRIVATE_BOTS entry for %s, which is not in TARGET_BOT_IDS", id)
+		}
+	}
+	for id, value := range parseBotPairs(env["BOT_NOTES"]) {
+		if bot := bots[id]; bot != nil {
+			bot.Note = value
+		} else {
+			log.Printf("Dropping BOT_NOTES entry for %s, which is not in TARGET_BOT_IDS", id)
+		}
+	}
+	handled["BOT_NOTES"] = true
+	for id, bot := range bots {
+		key := countCommandPrefix + id
+		bot.CountCommand = env[key]
+		handled[key] = true
+
+		key = botNotePrefix + id
+		if note := env[key]; note != "" {
+			bot.Note = note
+		}
+		handled[key] = true
+	}
+
+	for key, value := range env {
+		if name, found := strings.CutPrefix(key, computedPrefix); found && value != "" {
+			if file.Computed == nil {
+				file.Computed = make(map[string]string)
+			}
+			file.Computed[strings.ToLower(name)] = value
+		} else if !handled[key] && value != "" {
+			file.Settings[key] = value
+		}
+	}
+	return file
+}
+
+// env flattens the file back into the environment variables loadConfig reads.
+func (f *ConfigFile) env() map[string]string {
+	env := make(map[string]string, len(f.Settings)+len(botListSettings)+2)
+	for key, value := range f.Settings {
+		env[key] = value
+	}
+
+	var ids, timeouts, private []string
+	lists := make(map[string][]string)
+	for _, bot := range f.Bots {
+		ids = append(ids, bot.ID)
+		for _, setting := range botListSettings {
+			if value := *setting.field(&bot); value != "" {
+				lists[setting.env] = append(lists[setting.env], bot.ID+":"+value)
+			}
+		}
+		if bot.Timeout > 0 {
+			timeouts = append(timeouts, bot.ID+":"+strconv.Itoa(bot.Timeout))
+		}
+		if bot.CountCommand != "" {
+			env[countCommandPrefix+bot.ID] = bot.CountCommand
+		}
+		if bot.Note != "" {
+			env[botNotePrefix+bot.ID] = bot.Note
+		}
+		if bot.Private {
+			private = append(private, bot.ID)
+		}
+	}
+
+	if len(ids) > 0 {
+		env["TARGET_BOT_IDS"] = strings.Join(ids, ",")
+	}
+	for key, values := range lists {
+		env[key] = strings.Join(values, ",")
+	}
+	if len(timeouts) > 0 {
+		env["BOT_TIMEOUTS"] = strings.Join(timeouts, ",")
+	}
+	if len(private) > 0 {
+		env["PRIVATE_BOTS"] = strings.Join(private, ",")
+	}
+	for name, expression := range f.Computed {
+		env[computedPrefix+strings.ToUpper(name)] = expression
+	}
+	return env
+}
+
+// runMigrateConfig prints the statbot.yaml equivalent of a legacy .env file.
+func runMigrateConfig(args []string) {
+	path := ".env"
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	var file *ConfigFile
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		parsed, err := readConfigFile(path, false)
+		if err != nil {
+			log.Fatalf("Invalid %s: %v", path, err)
+		}
+		file = parsed
+	} else {
+		env, err := godotenv.Read(path)
+		if err != nil {
+			log.Fatalf("Error reading %s: %v", path, err)
+		}
+		file = migrateLegacyConfig(env)
+	}
+
+	fmt.Printf("# Generated by statbot migrate-config from %s\n", path)
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(file); err != nil {
+		log.Fatal(err)
+	}
+
+	if secrets := configSecrets(file); len(secrets) > 0 {
+		fmt.Fprintf(os.Stderr, "Note: the output contains secrets (%s); keep the file private\n", strings.Join(secrets, ", "))
+	}
+}
+
+// configSecrets lists the settings in the file that hold credentials.
+func configSecrets(file *ConfigFile) []string {
+	var secrets []string
+	for key := range file.Settings {
+		if strings.Contains(key, "TOKEN") || strings.Contains(key, "PASSWORD") || strings.HasSuffix(key, "_KEY") {
+			secrets = append(secrets, key)
+		}
+	}
+	for _, bot := range file.Bots {
+		if bot.Token != "" {
+			secrets = append(secrets, "bot tokens")
+			break
+		}
+	}
+	sort.Strings(secrets)
+	return secrets
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord rejects messages longer than this many characters.
+const maxMessageLength = 2000
+
+// splitMessage breaks a report into chunks that fit in a single Discord
+// message, splitting on line boundaries where possible.
+func splitMessage(message string) []string {
+	var parts []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(message, "\n") {
+		// Hard-wrap lines that are too long on their own
+		for utf8.RuneCountInString(line) > maxMessageLength {
+			runes := []rune(line)
+			if current.Len() > 0 {
+				parts = append(parts, current.String())
+				current.Reset()
+			}
+			parts = append(parts, string(runes[:maxMessageLength]))
+			line = string(runes[maxMessageLength:])
+		}
+
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+1+utf8.RuneCountInString(line) > maxMessageLength {
+			parts = append(parts, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(line)
+	}
+
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
+
+// sendMessages posts a multi-part report to a channel and returns the sent
+// messages. Between parts it checks the channel's rate limit bucket and
+// waits for the reset when the bucket is nearly exhausted, so long reports
+// don't burst into a 429 and leave room for alerts sent to the same channel.
+func sendMessages(channelRef string, parts []string) ([]*discordgo.Message, error) {
+	channelID, err := resolveChannel(channelRef)
+	if err != nil {
+		return nil, err
+	}
+	bucket := session.Ratelimiter.GetBucket(discordgo.EndpointChannelMessages(channelID))
+
+	var messages []*discordgo.Message
+	for i, part := range parts {
+		if i > 0 {
+			// The bucket is updated by every request to the channel, and
+			// discordgo only reads it under the bucket's lock
+			bucket.Lock()
+			wait := session.Ratelimiter.GetWaitTime(bucket, 2)
+			bucket.Unlock()
+			if wait > 0 {
+				log.Printf("Rate limit bucket for channel %s nearly exhausted, waiting %v before part %d/%d", channelID, wait, i+1, len(parts))
+				time.Sleep(wait)
+			}
+		}
+
+		message, err := sendChannelMessage(channelRef, part)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// publishMessages crossposts a report sent to an announcement channel so
+// servers following the channel receive it. Edits of a published message
+// reach the followers on their own.
+func publishMessages(destination Destination, messages []*discordgo.Message) {
+	if !destination.Publish {
+		return
+	}
+	for _, message := range messages {
+		if message.ID == dryRunMessageID {
+			continue
+		}
+		_, err := session.ChannelMessageCrosspost(message.ChannelID, message.ID)
+		metrics.recordDelivery("publish", err)
+		if err != nil {
+			log.Printf("Error publishing report in channel %s: %v", channelLabel(message.ChannelID), err)
+		}
+	}
+}
+
+// sendWithComponents posts a message that may be longer than one Discord
+// message, attaching the components to its last part so they stay below
+// the full text.
+func sendWithComponents(channelRef, message string, components []discordgo.MessageComponent) (*discordgo.Message, error) {
+	parts := splitMessage(message)
+	if len(parts) == 0 {
+		// Nothing to split, but the components still need a message
+		parts = []string{""}
+	}
+	if _, err := sendMessages(channelRef, parts[:len(parts)-1]); err != nil {
+		return nil, err
+	}
+	return sendChannelMessageComplex(channelRef, &discordgo.MessageSend{
+		Content:    parts[len(parts)-1],
+		Components: components,
+	})
+}
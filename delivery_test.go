@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	long := strings.Repeat("a", maxMessageLength)
+	tests := []struct {
+		name    string
+		message string
+		want    []string
+	}{
+		{"empty", "", nil},
+		{"short", "hello\nworld", []string{"hello\nworld"}},
+		{"exactly one message", long, []string{long}},
+		{"keeps blank lines", "a\n\nb", []string{"a\n\nb"}},
+		{"splits on lines", long + "\nb", []string{long, "b"}},
+		{"packs lines", strings.Repeat("a", 1500) + "\n" + strings.Repeat("b", 499) + "\n" + "c",
+			[]string{strings.Repeat("a", 1500) + "\n" + strings.Repeat("b", 499), "c"}},
+		{"hard-wraps long lines", "x\n" + long + "yz", []string{"x", long, "yz"}},
+		{"counts runes", strings.Repeat("あ", maxMessageLength+1), []string{strings.Repeat("あ", maxMessageLength), "あ"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := splitMessage(test.message)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d parts, want %d", len(got), len(test.want))
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("part %d = %.20q (%d runes), want %.20q (%d runes)",
+						i, got[i], utf8.RuneCountInString(got[i]), test.want[i], utf8.RuneCountInString(test.want[i]))
+				}
+				if n := utf8.RuneCountInString(got[i]); n > maxMessageLength {
+					t.Errorf("part %d has %d runes, more than %d", i, n, maxMessageLength)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSplitMessage(b *testing.B) {
+	// A report of 500 bots, a few times the message limit
+	var report strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&report, "ボット%d : **%d** (+%d)\n", i, 1000+i, i%7)
+	}
+	message := report.String()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		splitMessage(message)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Destination is a channel that receives the scheduled report, rendered in
+// its own language and sent at NOTIFICATION_TIME in its own timezone.
+// Public destinations get a redacted report (see PUBLIC_REPORT_METRICS).
+type Destination struct {
+	ChannelID string
+	Language  string
+	Location  *time.Location
+	Public    bool
+	Publish   bool   // Crosspost reports in an announcement channel to following servers
+	Schedule  string // Named report schedule the destination belongs to (see schedules.go)
+	Format    string // full (the default), compact or summary
+}
+
+const defaultLanguage = "ja"
+
+var translations = map[string]map[string]string{
+	"ja": {
+		"error":              "エラー: %v",
+		"error_unauthorized": "認証に失敗しました（トークンを確認してください）",
+		"error_rate_limited": "レート制限中です（次回の取得で再試行します）",
+		"error_timeout":      "取得元が応答しませんでした（タイムアウト）",
+		"error_not_listed":   "botリストに登録されていません（BOT_TOKENSまたはCUSTOM_WEBHOOKSを設定してください）",
+		"estimated":          "(推定値: %s)",
+		"change_day":         "前日比 %+d",
+		"change_week":        "前週比 %+d",
+		"pending":            "⏳ 取得中…",
+		"user_installs":      "(ユーザーインストール: %d)",
+		"patrons":            "(パトロン: %d)",
+		"votes":              "(投票: %d — %s)",
+		"votes_monthly":      "今月 %d",
+		"votes_total":        "🗳 投票合計: **%d** (%s)",
+		"github":             "(⭐ %d, Issue: %d)",
+		"network":            "🌐 ネットワーク: **%d** (重複除外, 単純合計: %d)",
+		"summary":            "📅 **%s からの変化**",
+		"summary_first":      "📅 **サマリー** (次回から前回との比較を表示します)",
+		"summary_total":      "合計: **%d**",
+		"total":              "Σ 合計: **%d**",
+	},
+	"en": {
+		"error":              "Error: %v",
+		"error_unauthorized": "authentication failed (check the tokens)",
+		"error_rate_limited": "rate limited (will retry on the next run)",
+		"error_timeout":      "the source did not respond (timeout)",
+		"error_not_listed":   "not listed on any bot list (set BOT_TOKENS or CUSTOM_WEBHOOKS)",
+		"estimated":          "(estimated from %s)",
+		"change_day":         "%+d today",
+		"change_week":        "%+d this week",
+		"pending":            "⏳ pending…",
+		"user_installs":      "(user installs: %d)",
+		"patrons":            "(patrons: %d)",
+		"votes":              "(votes: %d — %s)",
+		"votes_monthly":      "%d this month",
+		"votes_total":        "🗳 Total votes: **%d** (%s)",
+		"github":             "(⭐ %d, issues: %d)",
+		"network":            "🌐 Network: **%d** (deduplicated, naive sum: %d)",
+		"summary":            "📅 **Changes since %s**",
+		"summary_first":      "📅 **Summary** (changes are shown from the next run)",
+		"summary_total":      "Total: **%d**",
+		"total":              "Σ Total: **%d**",
+	},
+	"fr": {
+		"error":              "Erreur : %v",
+		"error_unauthorized": "échec de l'authentification (vérifiez les jetons)",
+		"error_rate_limited": "limite de débit atteinte (nouvel essai au prochain relevé)",
+		"error_timeout":      "la source n'a pas répondu (délai dépassé)",
+		"error_not_listed":   "absent des listes de bots (configurez BOT_TOKENS ou CUSTOM_WEBHOOKS)",
+		"estimated":          "(estimation via %s)",
+		"change_day":         "%+d sur 24 h",
+		"change_week":        "%+d sur 7 jours",
+		"pending":            "⏳ en attente…",
+		"user_installs":      "(installations utilisateur : %d)",
+		"patrons":            "(mécènes : %d)",
+		"votes":              "(votes : %d — %s)",
+		"votes_monthly":      "%d ce mois-ci",
+		"votes_total":        "🗳 Total des votes : **%d** (%s)",
+		"github":             "(⭐ %d, tickets : %d)",
+		"network":            "🌐 Réseau : **%d** (sans doublons, somme brute : %d)",
+		"summary":            "📅 **Évolution depuis le %s**",
+		"summary_first":      "📅 **Résumé** (l'évolution s'affichera au prochain relevé)",
+		"summary_total":      "Total : **%d**",
+		"total":              "Σ Total : **%d**",
+	},
+}
+
+// translate formats a report string in the given language, falling back to
+// the default language for unknown languages or keys.
+func translate(language, key string, args ...any) string {
+	format, ok := translations[language][key]
+	if !ok {
+		format = translations[defaultLanguage][key]
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// loadDestinations builds the report destinations. CHANNEL_ID is always a
+// destination (Japanese, local time) unless REPORT_DESTINATIONS overrides it.
+// Format: CHANNEL_ID:LANGUAGE:TIMEZONE[:public],CHANNEL_ID:LANGUAGE:TIMEZONE
+func loadDestinations() []Destination {
+	destinations := []Destination{{
+		ChannelID: config.ChannelID,
+		Language:  defaultLanguage,
+		Location:  time.Local,
+	}}
+
+	value := os.Getenv("REPORT_DESTINATIONS")
+	if value == "" {
+		return destinations
+	}
+
+	for _, destination := range parseDestinations(value) {
+		if destination.ChannelID == config.ChannelID {
+			destinations[0] = destination
+		} else {
+			destinations = append(destinations, destination)
+		}
+	}
+
+	log.Printf("Configured %d report destinations", len(destinations))
+	return destinations
+}
+
+// parseDestinations parses a list of destinations in the REPORT_DESTINATIONS
+// format, skipping empty entries.
+func parseDestinations(value string) []Destination {
+	var destinations []Destination
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if parts[0] == "" {
+			continue
+		}
+
+		destination := Destination{
+			ChannelID: parts[0],
+			Language:  defaultLanguage,
+			Location:  time.Local,
+		}
+
+		if len(parts) > 1 && parts[1] != "" {
+			language := strings.ToLower(parts[1])
+			if _, ok := translations[language]; !ok {
+				log.Printf("Unsupported language %q for channel %s, using %s", parts[1], destination.ChannelID, defaultLanguage)
+			} else {
+				destination.Language = language
+			}
+		}
+
+		if len(parts) > 2 && parts[2] != "" {
+			location, err := time.LoadLocation(parts[2])
+			if err != nil {
+				log.Fatalf("Invalid timezone %q for channel %s: %v", parts[2], destination.ChannelID, err)
+			}
+			destination.Location = location
+		}
+
+		for _, flag := range parts[min(len(parts), 3):] {
+			switch flag {
+			case "public":
+				destination.Public = true
+			case "publish":
+				destination.Publish = true
+			}
+		}
+
+		destinations = append(destinations, destination)
+	}
+	return destinations
+}
+
+// loadPublicMetrics reads the metrics allowed in public reports. "servers"
+// and "network" are the built-in metrics; any other name refers to a field
+// set by the report script.
+func loadPublicMetrics() map[string]bool {
+	value := os.Getenv("PUBLIC_REPORT_METRICS")
+	if value == "" {
+		value = "servers,network"
+	}
+
+	metrics := make(map[string]bool)
+	for _, metric := range strings.Split(value, ",") {
+		if metric = strings.TrimSpace(metric); metric != "" {
+			metrics[metric] = true
+		}
+	}
+	return metrics
+}
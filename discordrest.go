@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// discordUserAgent follows the format Discord requires for bot clients.
+const discordUserAgent = "DiscordBot (https://github.com/xemonodesign/bot-watcher, 1.0)"
+
+// discordAPIVersion returns the API version pinned for a feature with
+// DISCORD_API_VERSIONS, or "" when the feature goes through discordgo.
+func discordAPIVersion(feature string) string {
+	return config.DiscordAPIVersions[feature]
+}
+
+// discordREST calls a Discord REST endpoint directly at the given API
+// version and decodes the JSON response into out. It is used for endpoints
+// and fields discordgo doesn't support yet. A rate limited request is
+// retried once after the delay Discord asks for.
+func discordREST(token, version, path string, out any) error {
+	url := fmt.Sprintf("https://discord.com/api/v%s%s", version, path)
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bot "+token)
+		req.Header.Set("User-Agent", discordUserAgent)
+		identifyRequest(req)
+
+		client := &http.Client{Timeout: config.FetchTimeout}
+		resp, err := client.Do(req)
+		if err != nil {
+			return classifyError(err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt == 0 {
+			retryAfter, _ := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64)
+			time.Sleep(time.Duration(retryAfter * float64(time.Second)))
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := fmt.Errorf("Discord API v%s returned status %d for %s: %s", version, resp.StatusCode, path, string(body))
+			if kind := statusError(resp.StatusCode); kind != nil {
+				err = wrapKind(kind, err)
+			}
+			return err
+		}
+
+		return json.Unmarshal(body, out)
+	}
+}
+
+// getUserInstallCount returns the approximate number of users who installed
+// the bot's application to their account. discordgo doesn't expose this
+// field, so it is read from the raw application object.
+func getUserInstallCount(token string) (int, error) {
+	version := discordAPIVersion("application")
+	if version == "" {
+		version = "10"
+	}
+
+	var application struct {
+		ApproximateUserInstallCount *int `json:"approximate_user_install_count"`
+	}
+	if err := discordREST(token, version, "/applications/@me", &application); err != nil {
+		return 0, err
+	}
+	if application.ApproximateUserInstallCount == nil {
+		return 0, fmt.Errorf("Discord API v%s did not return a user install count", version)
+	}
+	return *application.ApproximateUserInstallCount, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// dryRunTargets are the notification targets NOTIFY_DRY_RUN can name.
+// "discord" covers every message statbot posts to Discord: reports, alert
+// channels, the feed, previews and approval requests.
+var dryRunTargets = []string{"discord", "pagerduty", "email", "ntfy", "pushover", "teams", "mastodon", "bluesky"}
+
+// dryRunMessageID marks the stand-in for a Discord message that was only
+// logged, so later edits and deletes of it are skipped.
+const dryRunMessageID = "dry-run"
+
+// loadDryRun reads NOTIFY_DRY_RUN: "true" for every target, or a list of
+// targets that are logged instead of sent while the others send normally.
+func loadDryRun() map[string]bool {
+	targets := make(map[string]bool)
+	if os.Getenv("NOTIFY_DRY_RUN") == "true" {
+		for _, target := range dryRunTargets {
+			targets[target] = true
+		}
+		return targets
+	}
+	for _, target := range getEnvList("NOTIFY_DRY_RUN") {
+		if target != "false" {
+			targets[strings.ToLower(target)] = true
+		}
+	}
+	if len(targets) > 0 {
+		log.Printf("Dry run: notifications to %s are logged instead of sent", strings.Join(getEnvList("NOTIFY_DRY_RUN"), ", "))
+	}
+	return targets
+}
+
+func validateDryRun(problems *configProblems) {
+	value := os.Getenv("NOTIFY_DRY_RUN")
+	if value == "" || value == "true" || value == "false" {
+		return
+	}
+	for _, target := range getEnvList("NOTIFY_DRY_RUN") {
+		if !contains(dryRunTargets, strings.ToLower(target)) {
+			problems.add("NOTIFY_DRY_RUN", "%q must be true, false or a list of %s", target, strings.Join(dryRunTargets, ", "))
+		}
+	}
+}
+
+func dryRun(target string) bool {
+	return config.DryRun[target]
+}
+
+// logDryRun logs a rendered notification in place of sending it.
+func logDryRun(target, destination, content string) {
+	log.Printf("[dry run] %s to %s:\n%s", target, destination, content)
+}
+
+// dryRunMessage logs a Discord message and returns a stand-in for it.
+func dryRunMessage(channelRef, content string) *discordgo.Message {
+	logDryRun("discord", channelRef, content)
+	return &discordgo.Message{ID: dryRunMessageID, ChannelID: channelRef, Content: content}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	duplicatesMu       sync.Mutex
+	reportedDuplicates = make(map[string]bool) // Sorted bot IDs of a group -> Already alerted
+)
+
+// detectDuplicateBots warns when two target bots answer with the same
+// username, which usually means a bot is configured under its old and new
+// ID. Each group is reported once per process.
+func detectDuplicateBots(allStats []BotStats) {
+	byName := make(map[string][]string)
+	names := make(map[string]string)
+	for _, stats := range allStats {
+		if stats.Error != nil || stats.Pending || stats.BotName == "" || stats.BotName == "Unknown" {
+			continue
+		}
+		key := strings.ToLower(stats.BotName)
+		byName[key] = append(byName[key], stats.BotID)
+		names[key] = stats.BotName
+	}
+
+	var alerts []Alert
+	duplicatesMu.Lock()
+	for key, ids := range byName {
+		if len(ids) < 2 {
+			continue
+		}
+		sort.Strings(ids)
+		group := strings.Join(ids, ",")
+		if reportedDuplicates[group] {
+			continue
+		}
+		reportedDuplicates[group] = true
+		alerts = append(alerts, Alert{
+			Severity: SeverityWarn,
+			BotName:  names[key],
+			Message:  fmt.Sprintf("%s は同じbotが重複して設定されている可能性があります。`statbot merge-bots 古いID 正しいID`で統合できます", group),
+			Rule:     RuleDuplicate,
+		})
+	}
+	duplicatesMu.Unlock()
+
+	for _, alert := range alerts {
+		raiseAlert(alert)
+	}
+}
+
+// runMergeBots consolidates a duplicate bot into its canonical ID: the
+// configuration is printed as statbot.yaml with the duplicate's settings
+// moved to the canonical bot (settings of the canonical bot win), and the
+// duplicate's history in REQUEST_LOG, SNAPSHOT_FILE and HISTORY_DB is
+// rewritten to the canonical ID.
+func runMergeBots(args []string) {
+	if len(args) < 2 {
+		log.Fatal("Usage: statbot merge-bots DUPLICATE_ID CANONICAL_ID [file]")
+	}
+	duplicate, canonical := args[0], args[1]
+	if duplicate == canonical {
+		log.Fatal("The duplicate and canonical IDs are the same")
+	}
+
+	path := os.Getenv("CONFIG_FILE")
+	if len(args) > 2 {
+		path = args[2]
+	} else if path == "" {
+		path = defaultConfigFile
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			path = ".env"
+		}
+	}
+
+	var file *ConfigFile
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		parsed, err := readConfigFile(path, false)
+		if err != nil {
+			log.Fatalf("Invalid %s: %v", path, err)
+		}
+		file = parsed
+	} else {
+		env, err := godotenv.Read(path)
+		if err != nil {
+			log.Fatalf("Error reading %s: %v", path, err)
+		}
+		file = migrateLegacyConfig(env)
+	}
+
+	if err := mergeBotConfig(file, duplicate, canonical); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("# Generated by statbot merge-bots from %s (%s merged into %s)\n", path, duplicate, canonical)
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(file); err != nil {
+		log.Fatal(err)
+	}
+
+	if config.RequestLog != "" {
+		rewritten, err := mergeRequestLog(config.RequestLog, duplicate, canonical)
+		if err != nil {
+			log.Fatalf("Error rewriting REQUEST_LOG: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Moved %d requests in %s to %s\n", rewritten, config.RequestLog, canonical)
+	}
+	if config.SnapshotFile != "" {
+		rewritten, err := mergeSnapshots(config.SnapshotFile, duplicate, canonical)
+		if err != nil {
+			log.Fatalf("Error rewriting SNAPSHOT_FILE: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Moved %d snapshots in %s to %s\n", rewritten, config.SnapshotFile, canonical)
+	}
+	if config.HistoryDB != "" {
+		moved, dropped, err := mergeHistorySamples(config.HistoryDB, duplicate, canonical)
+		if err != nil {
+			log.Fatalf("Error rewriting HISTORY_DB: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Moved %d samples in %s to %s (%d already recorded for it dropped)\n", moved, config.HistoryDB, canonical, dropped)
+	}
+	if secrets := configSecrets(file); len(secrets) > 0 {
+		fmt.Fprintf(os.Stderr, "Note: the output contains secrets (%s); keep the file private\n", strings.Join(secrets, ", "))
+	}
+}
+
+// mergeBotConfig removes the duplicate bot from the file. Its per-bot
+// settings fill in the ones the canonical bot doesn't have, and references
+// to it in settings (PUBLIC_API_BOTS, REMEDIATE_<BOT_ID>, ...) move to the
+// canonical ID.
+func mergeBotConfig(file *ConfigFile, duplicate, canonical string) error {
+	var from, into *BotConfig
+	for i := range file.Bots {
+		switch file.Bots[i].ID {
+		case duplicate:
+			from = &file.Bots[i]
+		case canonical:
+			into = &file.Bots[i]
+		}
+	}
+	if from == nil {
+		return fmt.Errorf("bot %s is not in the configuration", duplicate)
+	}
+	if into == nil {
+		return fmt.Errorf("bot %s is not in the configuration", canonical)
+	}
+
+	for _, setting := range botListSettings {
+		if *setting.field(into) == "" {
+			*setting.field(into) = *setting.field(from)
+		}
+	}
+	if into.Timeout == 0 {
+		into.Timeout = from.Timeout
+	}
+	if into.CountCommand == "" {
+		into.CountCommand = from.CountCommand
+	}
+	if into.Note == "" {
+		into.Note = from.Note
+	}
+	into.Private = into.Private || from.Private
+
+	bots := file.Bots[:0]
+	for _, bot := range file.Bots {
+		if bot.ID != duplicate {
+			bots = append(bots, bot)
+		}
+	}
+	file.Bots = bots
+
+	settings := make(map[string]string, len(file.Settings))
+	for key, value := range file.Settings {
+		if prefix, found := strings.CutSuffix(key, duplicate); found {
+			if _, set := file.Settings[prefix+canonical]; set {
+				continue
+			}
+			key = prefix + canonical
+		}
+		settings[key] = replaceBotID(value, duplicate, canonical)
+	}
+	file.Settings = settings
+	return nil
+}
+
+// replaceBotID moves the entries of an ID list or BOT_ID:VALUE list from the
+// duplicate to the canonical ID, dropping them where the canonical ID
+// already has an entry. Other values are returned unchanged.
+func replaceBotID(value, duplicate, canonical string) string {
+	entries := strings.Split(value, ",")
+	present := false
+	for _, entry := range entries {
+		if id, _, _ := strings.Cut(strings.TrimSpace(entry), ":"); id == canonical {
+			present = true
+		}
+	}
+
+	var merged []string
+	for _, entry := range entries {
+		id, rest, hasValue := strings.Cut(strings.TrimSpace(entry), ":")
+		if id != duplicate {
+			merged = append(merged, entry)
+		} else if !present {
+			if hasValue {
+				merged = append(merged, canonical+":"+rest)
+			} else {
+				merged = append(merged, canonical)
+			}
+		}
+	}
+	return strings.Join(merged, ",")
+}
+
+// mergeRequestLog rewrites the requests made for the duplicate bot to the
+// canonical ID and returns how many were changed.
+func mergeRequestLog(path, duplicate, canonical string) (int, error) {
+	return rewriteJSONLines(path, func(line []byte) ([]byte, bool, error) {
+		var record RequestRecord
+		if err := json.Unmarshal(line, &record); err != nil || record.BotID != duplicate {
+			return line, false, nil
+		}
+		record.BotID = canonical
+		line, err := json.Marshal(record)
+		return line, true, err
+	})
+}
+
+// mergeSnapshots rewrites the SNAPSHOT_FILE snapshots of the duplicate bot
+// to the canonical ID and returns how many were changed.
+func mergeSnapshots(path, duplicate, canonical string) (int, error) {
+	return rewriteJSONLines(path, func(line []byte) ([]byte, bool, error) {
+		var snapshot Snapshot
+		if err := json.Unmarshal(line, &snapshot); err != nil || snapshot.BotID != duplicate {
+			return line, false, nil
+		}
+		snapshot.BotID = canonical
+		line, err := json.Marshal(snapshot)
+		return line, true, err
+	})
+}
+
+// rewriteJSONLines passes every line of a JSON lines file through rewrite
+// and returns how many lines it changed. A missing file has nothing to
+// rewrite. The file is replaced atomically, so statbot should not be
+// running while it is rewritten.
+func rewriteJSONLines(path string, rewrite func(line []byte) ([]byte, bool, error)) (int, error) {
+	input, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer input.Close()
+
+	tmp := path + ".tmp"
+	output, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp)
+
+	rewritten := 0
+	scanner := bufio.NewScanner(input)
+	writer := bufio.NewWriter(output)
+	for scanner.Scan() {
+		line, changed, err := rewrite(scanner.Bytes())
+		if err != nil {
+			output.Close()
+			return 0, err
+		}
+		if changed {
+			rewritten++
+		}
+		writer.Write(line)
+		writer.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		output.Close()
+		return 0, err
+	}
+	if err := writer.Flush(); err != nil {
+		output.Close()
+		return 0, err
+	}
+	if err := output.Close(); err != nil {
+		return 0, err
+	}
+	return rewritten, os.Rename(tmp, path)
+}
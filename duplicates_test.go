@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots.jsonl")
+	lines := []string{
+		`{"time":"2026-01-01T09:00:00Z","bot_id":"old","metrics":{"server_count":90}}`,
+		`not json`,
+		`{"time":"2026-01-02T09:00:00Z","bot_id":"new","metrics":{"server_count":100}}`,
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rewritten, err := mergeSnapshots(path, "old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewritten != 1 {
+		t.Errorf("rewrote %d snapshots, want 1", rewritten)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(got) != 3 || !strings.Contains(got[0], `"bot_id":"new"`) || !strings.Contains(got[0], `"server_count":90`) || got[1] != lines[1] || got[2] != lines[2] {
+		t.Errorf("rewritten file:\n%s", data)
+	}
+
+	if rewritten, err := mergeSnapshots(filepath.Join(t.TempDir(), "missing.jsonl"), "old", "new"); err != nil || rewritten != 0 {
+		t.Errorf("missing file: rewrote %d, err %v", rewritten, err)
+	}
+}
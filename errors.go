@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Failure types for server count sources. Source errors are wrapped with
+// one of these when the cause can be recognized, so callers can test them
+// with errors.Is.
+var (
+	ErrRateLimited  = errors.New("rate limited")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotListed    = errors.New("not listed")
+	ErrTimeout      = errors.New("timed out")
+)
+
+// FetchError is returned when no source produced a server count. It wraps
+// the error from every source that was tried.
+type FetchError struct {
+	Errors []error
+}
+
+func (e *FetchError) Error() string {
+	return "could not fetch server count from any source"
+}
+
+func (e *FetchError) Unwrap() []error {
+	return e.Errors
+}
+
+// statusError maps an HTTP status code to a failure type, or nil.
+func statusError(status int) error {
+	switch status {
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrNotListed
+	}
+	return nil
+}
+
+// classifyError wraps err with its failure type when one can be recognized
+// from Discord REST errors, HTTP status errors or network timeouts.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	for _, kind := range []error{ErrRateLimited, ErrUnauthorized, ErrNotListed, ErrTimeout} {
+		if errors.Is(err, kind) {
+			return err
+		}
+	}
+
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil {
+		if kind := statusError(restErr.Response.StatusCode); kind != nil {
+			return wrapKind(kind, err)
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return wrapKind(ErrTimeout, err)
+	}
+
+	return err
+}
+
+func wrapKind(kind, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+// kindError tags an error with its failure type without changing its message.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// errorKind returns the failure type of err. For a FetchError the primary
+// source, tried first, decides; otherwise the most actionable type of the
+// other sources is used, leaving out not listed, since a 404 from a list the
+// bot isn't on is routine. It returns "" if none applies.
+func errorKind(err error) string {
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || len(fetchErr.Errors) == 0 {
+		return kindOf(err)
+	}
+
+	if kind := kindOf(fetchErr.Errors[0]); kind != "" {
+		return kind
+	}
+	for _, kind := range []string{"unauthorized", "rate_limited", "timeout"} {
+		for _, sourceErr := range fetchErr.Errors[1:] {
+			if kindOf(sourceErr) == kind {
+				return kind
+			}
+		}
+	}
+	return ""
+}
+
+// kindOf returns the most actionable failure type found in err, in the
+// order: unauthorized, rate limited, timeout, not listed.
+func kindOf(err error) string {
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrTimeout):
+		return "timeout"
+	case errors.Is(err, ErrNotListed):
+		return "not_listed"
+	}
+	return ""
+}
+
+// errorMessage explains a fetch error, using a specific, actionable message
+// when the failure type is known.
+func errorMessage(language string, err error) string {
+	if kind := errorKind(err); kind != "" {
+		return translate(language, "error_"+kind)
+	}
+	return err.Error()
+}
+
+// describeError renders a fetch error for a report line.
+func describeError(language string, err error) string {
+	return translate(language, "error", errorMessage(language, err))
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const ackButtonPrefix = "ack:"
+
+// EscalationStep re-sends an unacknowledged critical alert to a route once
+// After has passed since the alert was raised.
+type EscalationStep struct {
+	After time.Duration
+	Route AlertRoute
+}
+
+// escalation is a critical alert waiting to be acknowledged or resolved.
+type escalation struct {
+	ID    string
+	Alert Alert
+	Stop  chan struct{}
+}
+
+var (
+	escalationMu   sync.Mutex
+	escalations    = make(map[string]*escalation) // Rule/bot key -> escalation
+	escalationByID = make(map[string]*escalation)
+	escalationSeq  int
+)
+
+// loadEscalationSteps reads ESCALATION_STEP_1, ESCALATION_STEP_2, ... in the
+// format DELAY,DESTINATION,DESTINATION, e.g. "30m,role:ID,pagerduty". The
+// destinations are the same as in ALERT_ROUTES_*.
+func loadEscalationSteps() []EscalationStep {
+	var steps []EscalationStep
+	for n := 1; ; n++ {
+		envName := "ESCALATION_STEP_" + strconv.Itoa(n)
+		value := os.Getenv(envName)
+		if value == "" {
+			return steps
+		}
+
+		items := strings.Split(value, ",")
+		after, err := time.ParseDuration(strings.TrimSpace(items[0]))
+		if err != nil || after <= 0 {
+			log.Fatalf("Invalid %s: %q is not a delay like 15m", envName, items[0])
+		}
+		route, unknown := parseAlertRoute(items[1:])
+		for _, destination := range unknown {
+			log.Printf("Unknown destination in %s: %s", envName, destination)
+		}
+		steps = append(steps, EscalationStep{After: after, Route: route})
+	}
+}
+
+func escalationKey(alert Alert) string {
+	return alert.Rule + "/" + alert.BotID
+}
+
+// startEscalation schedules the escalation steps for a critical alert and
+// gives it an acknowledgement ID, which adds an Acknowledge button to its
+// channel messages.
+func startEscalation(alert Alert) Alert {
+	if len(config.EscalationSteps) == 0 {
+		return alert
+	}
+
+	escalationMu.Lock()
+	defer escalationMu.Unlock()
+
+	key := escalationKey(alert)
+	if existing, exists := escalations[key]; exists {
+		alert.AckID = existing.ID
+		return alert
+	}
+
+	escalationSeq++
+	alert.AckID = strconv.Itoa(escalationSeq)
+	e := &escalation{ID: alert.AckID, Alert: alert, Stop: make(chan struct{})}
+	escalations[key] = e
+	escalationByID[e.ID] = e
+
+	go runEscalation(e)
+	return alert
+}
+
+func runEscalation(e *escalation) {
+	start := e.Alert.Time
+	steps := config.EscalationSteps
+
+	for n := 0; ; n++ {
+		var step EscalationStep
+		var at time.Time
+		switch {
+		case n < len(steps):
+			step = steps[n]
+			at = start.Add(step.After)
+		case config.EscalationRepeat > 0:
+			// Keep reminding through the last step
+			step = steps[len(steps)-1]
+			at = start.Add(step.After + time.Duration(n-len(steps)+1)*config.EscalationRepeat)
+		default:
+			return
+		}
+
+		select {
+		case <-e.Stop:
+			return
+		case <-time.After(time.Until(at)):
+		}
+
+		reminder := e.Alert
+		reminder.Message = fmt.Sprintf("未確認のまま%s経過しました: %s", time.Since(start).Round(time.Minute), e.Alert.Message)
+		reminder.Notifiers = buildNotifiers(map[Severity]AlertRoute{SeverityCritical: step.Route})
+		log.Printf("Escalating alert %s (step %d)", e.ID, n+1)
+		for _, err := range deliverAlert(reminder) {
+			log.Printf("Error delivering escalation: %v", err)
+		}
+	}
+}
+
+// stopEscalation ends an escalation. The caller holds escalationMu.
+func stopEscalation(e *escalation) {
+	delete(escalations, escalationKey(e.Alert))
+	delete(escalationByID, e.ID)
+	close(e.Stop)
+}
+
+// resolveEscalation stops escalating the critical alert the info alert
+// resolves, if any.
+func resolveEscalation(alert Alert) {
+	escalationMu.Lock()
+	defer escalationMu.Unlock()
+
+	if e, exists := escalations[escalationKey(alert)]; exists {
+		log.Printf("Alert %s resolved, stopping escalation", e.ID)
+		stopEscalation(e)
+	}
+}
+
+func ackButton(id, label string, done bool) []discordgo.MessageComponent {
+	style := discordgo.DangerButton
+	if done {
+		style = discordgo.SecondaryButton
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: label, Style: style, CustomID: ackButtonPrefix + id, Disabled: done},
+		}},
+	}
+}
+
+func isAckButton(customID string) (string, bool) {
+	return strings.CutPrefix(customID, ackButtonPrefix)
+}
+
+func handleAckButton(s *discordgo.Session, i *discordgo.InteractionCreate, id string) {
+	if !canRunOps(i.Member) {
+		respondEphemeral(s, i, "このアラートを確認する権限がありません")
+		return
+	}
+
+	escalationMu.Lock()
+	e, exists := escalationByID[id]
+	if exists {
+		stopEscalation(e)
+	}
+	escalationMu.Unlock()
+
+	if !exists {
+		respondEphemeral(s, i, "このアラートは既に確認済みか解消しています")
+		return
+	}
+
+	user := interactionUser(i)
+	name := ""
+	if user != nil {
+		name = user.Username
+	}
+	recordAudit(user, "acknowledge alert", formatAlert(e.Alert))
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    i.Message.Content,
+			Components: ackButton(id, "✅ "+name+" が確認", true),
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating acknowledged alert: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	countsMu   sync.Mutex
+	lastCounts = make(map[string]int)
+)
+
+// recordChanges fills in each bot's change since the previous run and
+// remembers this run's counts for the next one.
+func recordChanges(allStats []BotStats) {
+	countsMu.Lock()
+	defer countsMu.Unlock()
+
+	for i := range allStats {
+		stats := &allStats[i]
+		if stats.Error != nil || stats.Pending {
+			continue
+		}
+
+		if previous, known := lastCounts[stats.BotID]; known {
+			stats.Change = stats.ServerCount - previous
+			stats.HasChange = true
+		}
+		lastCounts[stats.BotID] = stats.ServerCount
+	}
+}
+
+// postFeed sends a one-line change event to the feed channel, if configured.
+func postFeed(line string) {
+	if config.FeedChannelID == "" || !moduleEnabled(ModulePublishing) {
+		return
+	}
+
+	line = "`" + time.Now().Format("15:04") + "` " + line
+	_, err := sendChannelMessage(config.FeedChannelID, line)
+	metrics.recordDelivery("feed", err)
+	if err != nil {
+		log.Printf("Error posting to feed channel: %v", err)
+	}
+}
+
+// evaluateFeed posts significant changes since the previous run: milestone
+// crossings and large jumps. Milestones reached on the way up are also
+// announced publicly when an announcement account is configured.
+func evaluateFeed(allStats []BotStats) {
+	if !moduleEnabled(ModulePublishing) || (config.FeedChannelID == "" && !announcementsEnabled()) {
+		return
+	}
+
+	var lines []string
+	for _, stats := range allStats {
+		if stats.Error != nil || !stats.HasChange {
+			continue
+		}
+
+		previous := stats.ServerCount - stats.Change
+
+		name := stats.BotName
+		if name == "Unknown" || name == "" {
+			name = stats.BotID
+		}
+
+		if step := config.FeedMilestoneStep; step > 0 && previous/step != stats.ServerCount/step {
+			if stats.ServerCount > previous {
+				lines = append(lines, fmt.Sprintf("🎉 %s が %d サーバーを突破しました (%d → %d)", name, stats.ServerCount/step*step, previous, stats.ServerCount))
+				if !config.PrivateBots[stats.BotID] {
+					announceMilestone(MilestoneAnnouncement{
+						BotID:     stats.BotID,
+						BotName:   name,
+						Milestone: stats.ServerCount / step * step,
+						Previous:  previous,
+						Count:     stats.ServerCount,
+					})
+				}
+			} else {
+				lines = append(lines, fmt.Sprintf("📉 %s が %d サーバーを下回りました (%d → %d)", name, previous/step*step, previous, stats.ServerCount))
+			}
+			continue
+		}
+
+		delta := stats.Change
+		if config.FeedMinChange > 0 && (delta >= config.FeedMinChange || -delta >= config.FeedMinChange) {
+			emoji := "📈"
+			if delta < 0 {
+				emoji = "📉"
+			}
+			lines = append(lines, fmt.Sprintf("%s %s: %d → %d (%+d)", emoji, name, previous, stats.ServerCount, delta))
+		}
+	}
+
+	for _, line := range lines {
+		postFeed(line)
+	}
+}
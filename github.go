@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// GitHubRepo holds the community counters of a bot's repository. GitHub
+// counts open pull requests as open issues.
+type GitHubRepo struct {
+	Stars      int `json:"stargazers_count"`
+	OpenIssues int `json:"open_issues_count"`
+}
+
+func getGitHubRepo(botID, repo string) (*GitHubRepo, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s", repo)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if config.GitHubToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.GitHubToken)
+	}
+	identifyRequest(req)
+	req = tagBot(req, botID)
+
+	waitForHost(url)
+	client := &http.Client{Timeout: fetchTimeout(botID, "github")}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("GitHub API returned status %d for %s", resp.StatusCode, repo)
+		if kind := statusError(resp.StatusCode); kind != nil {
+			err = wrapKind(kind, err)
+		}
+		captureResponse("github", botID, url, resp.StatusCode, body, err)
+		return nil, err
+	}
+
+	var result GitHubRepo
+	if err := json.Unmarshal(body, &result); err != nil {
+		captureResponse("github", botID, url, resp.StatusCode, body, err)
+		return nil, err
+	}
+
+	return &result, nil
+}
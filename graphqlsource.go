@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Per-bot variables of the GraphQL source: GRAPHQL_URL_<BOT_ID> is the
+// endpoint, GRAPHQL_QUERY_<BOT_ID> the query, GRAPHQL_PATH_<BOT_ID> the dot
+// separated path of the count in the response (e.g. data.bot.guildCount)
+// and GRAPHQL_TOKEN_<BOT_ID> an optional bearer token.
+const (
+	graphqlURLPrefix   = "GRAPHQL_URL_"
+	graphqlQueryPrefix = "GRAPHQL_QUERY_"
+	graphqlPathPrefix  = "GRAPHQL_PATH_"
+	graphqlTokenPrefix = "GRAPHQL_TOKEN_"
+)
+
+// GraphQLSource is a GraphQL query and where its response holds the count.
+type GraphQLSource struct {
+	URL   string
+	Query string
+	Path  []string
+	Token string
+}
+
+func loadGraphQLSources() map[string]GraphQLSource {
+	sources := make(map[string]GraphQLSource)
+	queries := getEnvPerBot(graphqlQueryPrefix)
+	paths := getEnvPerBot(graphqlPathPrefix)
+	tokens := getEnvPerBot(graphqlTokenPrefix)
+	for botID, endpoint := range getEnvPerBot(graphqlURLPrefix) {
+		query, hasQuery := queries[botID]
+		path, hasPath := paths[botID]
+		if hasQuery && hasPath {
+			sources[botID] = GraphQLSource{URL: endpoint, Query: query, Path: strings.Split(path, "."), Token: tokens[botID]}
+		}
+	}
+	return sources
+}
+
+// validateGraphQLSources checks that every GraphQL source belongs to a
+// target bot and has a URL, a query and a response path.
+func validateGraphQLSources(problems *configProblems, targetIDs map[string]bool) {
+	settings := []map[string]string{
+		getEnvPerBot(graphqlURLPrefix),
+		getEnvPerBot(graphqlQueryPrefix),
+		getEnvPerBot(graphqlPathPrefix),
+	}
+	prefixes := []string{graphqlURLPrefix, graphqlQueryPrefix, graphqlPathPrefix}
+
+	seen := make(map[string]bool)
+	var botIDs []string
+	for _, values := range settings {
+		for botID := range values {
+			if !seen[botID] {
+				seen[botID] = true
+				botIDs = append(botIDs, botID)
+			}
+		}
+	}
+	sort.Strings(botIDs)
+
+	for _, botID := range botIDs {
+		name := graphqlURLPrefix + botID
+		if !targetIDs[botID] {
+			problems.add(name, "bot is not in TARGET_BOT_IDS")
+		}
+		for i, values := range settings {
+			if _, exists := values[botID]; !exists {
+				problems.add(name, "%s%s is not set", prefixes[i], botID)
+			}
+		}
+		if endpoint, exists := settings[0][botID]; exists {
+			validateURL(problems, name, endpoint)
+		}
+	}
+}
+
+// getServerCountFromGraphQL posts the query and follows the path through
+// the response's data. GraphQL errors are reported even with status 200.
+func getServerCountFromGraphQL(botID string, source GraphQLSource, timeout time.Duration) (int, error) {
+	payload, err := json.Marshal(map[string]string{"query": source.Query})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest("POST", source.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if source.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+source.Token)
+	}
+	identifyRequest(req)
+	req = tagBot(req, botID)
+
+	waitForHost(source.URL)
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("GraphQL endpoint returned status %d", resp.StatusCode)
+		if kind := statusError(resp.StatusCode); kind != nil && kind != ErrNotListed {
+			err = wrapKind(kind, err)
+		}
+		captureResponse("graphql", botID, source.URL, resp.StatusCode, body, err)
+		return 0, err
+	}
+
+	var result struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	var document any
+	if err := json.Unmarshal(body, &document); err != nil {
+		captureResponse("graphql", botID, source.URL, resp.StatusCode, body, err)
+		return 0, err
+	}
+	if json.Unmarshal(body, &result) == nil && len(result.Errors) > 0 {
+		err := fmt.Errorf("GraphQL error: %s", result.Errors[0].Message)
+		captureResponse("graphql", botID, source.URL, resp.StatusCode, body, err)
+		return 0, err
+	}
+
+	count, err := countAtPath(document, source.Path)
+	if err != nil {
+		captureResponse("graphql", botID, source.URL, resp.StatusCode, body, err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// countAtPath follows object keys and array indexes to a number.
+func countAtPath(document any, path []string) (int, error) {
+	value := document
+	for i, segment := range path {
+		switch node := value.(type) {
+		case map[string]any:
+			child, exists := node[segment]
+			if !exists {
+				return 0, fmt.Errorf("response has no %s", strings.Join(path[:i+1], "."))
+			}
+			value = child
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return 0, fmt.Errorf("response has no %s", strings.Join(path[:i+1], "."))
+			}
+			value = node[index]
+		default:
+			return 0, fmt.Errorf("response has no %s", strings.Join(path[:i+1], "."))
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return int(v), nil
+	case string:
+		if count, err := strconv.Atoi(v); err == nil {
+			return count, nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not a number", strings.Join(path, "."))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	guildPageSize     = 200
+	guildPageAttempts = 3
+)
+
+// guildPageBackoff is the wait before retrying a failed page, growing with
+// each attempt.
+var guildPageBackoff = time.Second
+
+// forEachGuildPage pages through the guilds the bot is in, starting after
+// the given guild ID (empty for the first page), and hands each page of
+// guild IDs to fn with the cursor to continue from, so callers never hold
+// more than they keep themselves. Only the ID field is decoded, and
+// with_counts is not requested. The cursor of the last complete page is the
+// checkpoint: a page that fails is retried from it with backoff instead of
+// starting over, which matters when a bot in 50k guilds needs hundreds of
+// pages.
+func forEachGuildPage(token, after string, fn func(ids []uint64, after string)) error {
+	version := discordAPIVersion("guilds")
+	if version == "" {
+		version = "10"
+	}
+
+	pages := 0
+	for {
+		path := fmt.Sprintf("/users/@me/guilds?limit=%d", guildPageSize)
+		if after != "" {
+			path += "&after=" + after
+		}
+
+		var guilds []struct {
+			ID string `json:"id"`
+		}
+		var err error
+		for attempt := 1; attempt <= guildPageAttempts; attempt++ {
+			guilds = guilds[:0]
+			if err = discordREST(token, version, path, &guilds); err == nil {
+				break
+			}
+			if attempt < guildPageAttempts {
+				log.Printf("Error fetching guild page %d (after %q), retrying: %v", pages+1, after, err)
+				time.Sleep(time.Duration(attempt) * guildPageBackoff)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("guild page %d (after %q): %w", pages+1, after, err)
+		}
+
+		ids := make([]uint64, 0, len(guilds))
+		for _, guild := range guilds {
+			id, err := strconv.ParseUint(guild.ID, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid guild ID %q: %v", guild.ID, err)
+			}
+			ids = append(ids, id)
+		}
+		if len(guilds) > 0 {
+			after = guilds[len(guilds)-1].ID
+		}
+		fn(ids, after)
+		pages++
+
+		if len(guilds) < guildPageSize {
+			return nil
+		}
+
+		// Small delay to avoid rate limiting
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// guildCheckpointAge is how long an interrupted guild count can be resumed.
+// Guilds joined or left since are missed, so an older count starts over.
+const guildCheckpointAge = 24 * time.Hour
+
+// GuildCheckpoint is how far an interrupted guild count got.
+type GuildCheckpoint struct {
+	After string    `json:"after"` // Guild ID the next page starts after
+	Count int       `json:"count"` // Guilds counted up to it
+	Saved time.Time `json:"saved"`
+}
+
+// countGuilds counts the bot's guilds without keeping their IDs. With
+// STATE_FILE the cursor and the count so far are saved after every page,
+// so a count that fails or is cut short by a restart continues from the
+// last page on the next run instead of starting over.
+func countGuilds(botID, token string) (int, error) {
+	checkpoint, resumed := loadGuildCheckpoint(botID)
+	if resumed {
+		log.Printf("Resuming the guild count of bot %s after guild %s (%d guilds counted)", botID, checkpoint.After, checkpoint.Count)
+	}
+
+	total := checkpoint.Count
+	err := forEachGuildPage(token, checkpoint.After, func(ids []uint64, after string) {
+		total += len(ids)
+		saveGuildCheckpoint(botID, &GuildCheckpoint{After: after, Count: total, Saved: time.Now()})
+	})
+	if err != nil {
+		return 0, err
+	}
+	saveGuildCheckpoint(botID, nil)
+	return total, nil
+}
+
+func loadGuildCheckpoint(botID string) (GuildCheckpoint, bool) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	checkpoint, exists := delivered.GuildCheckpoints[botID]
+	if !exists || time.Since(checkpoint.Saved) > guildCheckpointAge {
+		return GuildCheckpoint{}, false
+	}
+	return checkpoint, true
+}
+
+// saveGuildCheckpoint records the bot's checkpoint in STATE_FILE, or
+// removes it when nil.
+func saveGuildCheckpoint(botID string, checkpoint *GuildCheckpoint) {
+	if config.StateFile == "" {
+		return
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if checkpoint == nil {
+		if _, exists := delivered.GuildCheckpoints[botID]; !exists {
+			return
+		}
+		delete(delivered.GuildCheckpoints, botID)
+	} else {
+		if delivered.GuildCheckpoints == nil {
+			delivered.GuildCheckpoints = make(map[string]GuildCheckpoint)
+		}
+		delivered.GuildCheckpoints[botID] = *checkpoint
+	}
+	if err := saveState(); err != nil {
+		log.Printf("Error saving STATE_FILE: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCountGuildsResumesFromCheckpoint(t *testing.T) {
+	const guilds = 450
+	var (
+		mu      sync.Mutex
+		failing = true
+		cursors []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		after := r.URL.Query().Get("after")
+		mu.Lock()
+		cursors = append(cursors, after)
+		fail := failing && after != ""
+		mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		start, _ := strconv.Atoi(after)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, "[")
+		for id := start + 1; id <= min(start+guildPageSize, guilds); id++ {
+			if id > start+1 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `{"id": "%d"}`, id)
+		}
+		fmt.Fprint(w, "]")
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	previousTransport, previousBackoff := http.DefaultTransport, guildPageBackoff
+	http.DefaultTransport = redirectTransport{target, previousTransport}
+	guildPageBackoff = time.Millisecond
+	previousConfig, previousState := config, delivered
+	t.Cleanup(func() {
+		http.DefaultTransport, guildPageBackoff = previousTransport, previousBackoff
+		config, delivered = previousConfig, previousState
+	})
+	config.StateFile = filepath.Join(t.TempDir(), "state.json")
+	config.FetchTimeout = 5 * time.Second
+	delivered = deliveryState{Runs: make(map[string]map[string][]string)}
+
+	if _, err := countGuilds("1", "token"); err == nil {
+		t.Fatal("count with a failing second page succeeded")
+	}
+
+	// A restart reads the checkpoint back from STATE_FILE
+	delivered = deliveryState{}
+	loadState()
+	if checkpoint := delivered.GuildCheckpoints["1"]; checkpoint.After != "200" || checkpoint.Count != 200 {
+		t.Fatalf("checkpoint = %+v, want after 200 with 200 counted", checkpoint)
+	}
+
+	mu.Lock()
+	failing, cursors = false, nil
+	mu.Unlock()
+	count, err := countGuilds("1", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != guilds {
+		t.Errorf("count = %d, want %d", count, guilds)
+	}
+	if len(cursors) == 0 || cursors[0] != "200" {
+		t.Errorf("resumed count fetched after %q, want the checkpoint", cursors)
+	}
+	if _, exists := delivered.GuildCheckpoints["1"]; exists {
+		t.Error("checkpoint kept after the count finished")
+	}
+}
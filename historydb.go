@@ -0,0 +1,243 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// historySchema is the layout of HISTORY_DB: one row per bot fetched
+// successfully in each run.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS samples (
+	id           INTEGER PRIMARY KEY,
+	time         INTEGER NOT NULL, -- Unix seconds
+	bot_id       TEXT    NOT NULL,
+	server_count INTEGER NOT NULL,
+	source       TEXT    NOT NULL,
+	estimated    INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS samples_bot_time ON samples (bot_id, time);
+`
+
+// historySeedSamples is how many stored counts per bot seed the trends at
+// startup.
+const historySeedSamples = maxSmoothingWindow
+
+var historyDB *sql.DB
+
+// validateHistoryDB checks that the SQLite driver HISTORY_DB needs is
+// compiled in.
+func validateHistoryDB(problems *configProblems) {
+	if getEnvDefault("HISTORY_DB", "") != "" && !contains(sql.Drivers(), "sqlite") {
+		problems.add("HISTORY_DB", "this statbot was built without the sqlite driver (build with -tags sqlite)")
+	}
+}
+
+// openHistoryDB opens HISTORY_DB, creating the table on first use, and seeds
+// the previous counts and trends of the target bots from it, so changes and
+// charts carry on across restarts.
+func openHistoryDB() {
+	if config.HistoryDB == "" {
+		return
+	}
+
+	db, err := sql.Open("sqlite", config.HistoryDB)
+	if err != nil {
+		log.Fatalf("Error opening HISTORY_DB: %v", err)
+	}
+	// SQLite allows a single writer
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		log.Fatalf("Error creating the HISTORY_DB table: %v", err)
+	}
+	historyDB = db
+
+	seeded := 0
+	for _, botID := range config.TargetBotIDs {
+		samples, err := storedSamples(botID, historySeedSamples)
+		if err != nil {
+			log.Printf("Error reading the history of bot %s from HISTORY_DB: %v", botID, err)
+			continue
+		}
+		if len(samples) == 0 {
+			continue
+		}
+
+		countsMu.Lock()
+		lastCounts[botID] = samples[len(samples)-1].Count
+		countsMu.Unlock()
+		if len(samples) > 1 {
+			backfillBot(botID, samples)
+		}
+		seeded++
+	}
+	log.Printf("Loaded the history of %d bots from %s", seeded, config.HistoryDB)
+}
+
+// storedSamples returns the bot's latest stored counts, oldest first.
+func storedSamples(botID string, limit int) ([]backfillSample, error) {
+	rows, err := historyDB.Query(`SELECT time, server_count FROM samples WHERE bot_id = ? ORDER BY time DESC LIMIT ?`, botID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []backfillSample
+	for rows.Next() {
+		var seconds int64
+		var sample backfillSample
+		if err := rows.Scan(&seconds, &sample.Count); err != nil {
+			return nil, err
+		}
+		sample.Time = time.Unix(seconds, 0)
+		samples = append([]backfillSample{sample}, samples...)
+	}
+	return samples, rows.Err()
+}
+
+// storeSamples records the bots fetched successfully in HISTORY_DB.
+func storeSamples(allStats []BotStats) {
+	if historyDB == nil {
+		return
+	}
+
+	tx, err := historyDB.Begin()
+	if err != nil {
+		log.Printf("Error writing to HISTORY_DB: %v", err)
+		return
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	for _, stats := range allStats {
+		if stats.Error != nil || stats.Pending {
+			continue
+		}
+		_, err := tx.Exec(`INSERT INTO samples (time, bot_id, server_count, source, estimated) VALUES (?, ?, ?, ?, ?)`,
+			now, stats.BotID, stats.ServerCount, stats.Source, stats.Estimated)
+		if err != nil {
+			log.Printf("Error writing to HISTORY_DB: %v", err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error writing to HISTORY_DB: %v", err)
+	}
+}
+
+// recordPeriodChanges fills in each bot's change since about a day and a week
+// ago from HISTORY_DB. The stored count closest to that time is used, so runs
+// that drift by a few minutes, or missed runs, still have a baseline.
+func recordPeriodChanges(allStats []BotStats) {
+	if historyDB == nil {
+		return
+	}
+
+	now := time.Now()
+	for i := range allStats {
+		stats := &allStats[i]
+		if stats.Error != nil || stats.Pending {
+			continue
+		}
+
+		count, known, err := storedCountNear(stats.BotID, now.Add(-24*time.Hour), 3*time.Hour)
+		if err != nil {
+			log.Printf("Error reading the history of bot %s from HISTORY_DB: %v", stats.BotID, err)
+			continue
+		}
+		if known {
+			stats.DayChange, stats.HasDayChange = stats.ServerCount-count, true
+		}
+
+		count, known, err = storedCountNear(stats.BotID, now.Add(-7*24*time.Hour), 21*time.Hour)
+		if err != nil {
+			log.Printf("Error reading the history of bot %s from HISTORY_DB: %v", stats.BotID, err)
+			continue
+		}
+		if known {
+			stats.WeekChange, stats.HasWeekChange = stats.ServerCount-count, true
+		}
+	}
+}
+
+// storedCountNear returns the bot's stored count closest to the time, if one
+// was stored within the tolerance of it.
+func storedCountNear(botID string, at time.Time, tolerance time.Duration) (int, bool, error) {
+	target := at.Unix()
+	window := int64(tolerance / time.Second)
+
+	var count int
+	err := historyDB.QueryRow(`SELECT server_count FROM samples WHERE bot_id = ? AND time BETWEEN ? AND ? ORDER BY ABS(time - ?) LIMIT 1`,
+		botID, target-window, target+window, target).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return count, true, nil
+}
+
+// formatPeriodChanges renders the changes since a day and a week ago, e.g.
+// " (+120 today, +800 this week)", or nothing when neither is known.
+func formatPeriodChanges(language string, stats BotStats) string {
+	var parts []string
+	if stats.HasDayChange {
+		parts = append(parts, translate(language, "change_day", stats.DayChange))
+	}
+	if stats.HasWeekChange {
+		parts = append(parts, translate(language, "change_week", stats.WeekChange))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// mergeHistorySamples moves the duplicate bot's samples in the database at
+// path to the canonical ID. Samples taken in a run that also sampled the
+// canonical bot are dropped, so every run keeps one count per bot. It
+// returns how many samples were moved and dropped.
+func mergeHistorySamples(path, duplicate, canonical string) (moved, dropped int64, err error) {
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	if !contains(sql.Drivers(), "sqlite") {
+		return 0, 0, errors.New("this statbot was built without the sqlite driver (build with -tags sqlite)")
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM samples WHERE bot_id = ? AND time IN (SELECT time FROM samples WHERE bot_id = ?)`, duplicate, canonical)
+	if err != nil {
+		return 0, 0, err
+	}
+	if dropped, err = result.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	result, err = tx.Exec(`UPDATE samples SET bot_id = ? WHERE bot_id = ?`, canonical, duplicate)
+	if err != nil {
+		return 0, 0, err
+	}
+	if moved, err = result.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	return moved, dropped, tx.Commit()
+}
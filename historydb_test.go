@@ -0,0 +1,156 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestHistoryDB(t *testing.T) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "stats.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		t.Fatal(err)
+	}
+	historyDB = db
+	t.Cleanup(func() {
+		db.Close()
+		historyDB = nil
+	})
+}
+
+func insertTestSample(t *testing.T, at time.Time, botID string, count int) {
+	t.Helper()
+	_, err := historyDB.Exec(`INSERT INTO samples (time, bot_id, server_count, source) VALUES (?, ?, ?, 'discord')`, at.Unix(), botID, count)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreSamples(t *testing.T) {
+	openTestHistoryDB(t)
+
+	storeSamples([]BotStats{
+		{BotID: "1", ServerCount: 100, Source: "discord"},
+		{BotID: "2", Error: errors.New("down")},
+		{BotID: "3", Pending: true},
+		{BotID: "1", ServerCount: 110, Source: "topgg", Estimated: true},
+	})
+
+	samples, err := storedSamples("1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("stored %d samples for bot 1, want 2", len(samples))
+	}
+	for _, botID := range []string{"2", "3"} {
+		if samples, _ := storedSamples(botID, 10); len(samples) != 0 {
+			t.Errorf("stored %d samples for bot %s, which has no count", len(samples), botID)
+		}
+	}
+
+	var estimated int
+	if err := historyDB.QueryRow(`SELECT COUNT(*) FROM samples WHERE estimated = 1`).Scan(&estimated); err != nil {
+		t.Fatal(err)
+	}
+	if estimated != 1 {
+		t.Errorf("%d estimated samples, want 1", estimated)
+	}
+}
+
+func TestStoredSamplesOrder(t *testing.T) {
+	openTestHistoryDB(t)
+
+	now := time.Now().Truncate(time.Second)
+	for i, count := range []int{10, 20, 30, 40} {
+		insertTestSample(t, now.Add(time.Duration(i)*time.Hour), "1", count)
+	}
+
+	samples, err := storedSamples("1", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counts []int
+	for _, sample := range samples {
+		counts = append(counts, sample.Count)
+	}
+	if len(counts) != 3 || counts[0] != 20 || counts[1] != 30 || counts[2] != 40 {
+		t.Errorf("counts = %v, want the latest three oldest first [20 30 40]", counts)
+	}
+	if !samples[2].Time.Equal(now.Add(3 * time.Hour)) {
+		t.Errorf("latest sample at %v, want %v", samples[2].Time, now.Add(3*time.Hour))
+	}
+}
+
+func TestRecordPeriodChanges(t *testing.T) {
+	openTestHistoryDB(t)
+
+	now := time.Now()
+	insertTestSample(t, now.Add(-26*time.Hour), "1", 80)
+	insertTestSample(t, now.Add(-23*time.Hour), "1", 90) // Closest to a day ago
+	insertTestSample(t, now.Add(-7*24*time.Hour+5*time.Hour), "1", 50)
+	insertTestSample(t, now.Add(-30*time.Hour), "2", 10) // Outside the tolerance
+
+	allStats := []BotStats{
+		{BotID: "1", ServerCount: 100},
+		{BotID: "2", ServerCount: 20},
+		{BotID: "3", Error: errors.New("down")},
+	}
+	recordPeriodChanges(allStats)
+
+	if got := allStats[0]; !got.HasDayChange || got.DayChange != 10 || !got.HasWeekChange || got.WeekChange != 50 {
+		t.Errorf("bot 1: day %+d (%v), week %+d (%v), want +10 and +50", got.DayChange, got.HasDayChange, got.WeekChange, got.HasWeekChange)
+	}
+	if got := allStats[1]; got.HasDayChange || got.HasWeekChange {
+		t.Errorf("bot 2 has period changes without a sample near them")
+	}
+	if got := allStats[2]; got.HasDayChange || got.HasWeekChange {
+		t.Errorf("bot 3 failed but has period changes")
+	}
+}
+
+func TestMergeHistorySamples(t *testing.T) {
+	openTestHistoryDB(t)
+
+	now := time.Now().Truncate(time.Second)
+	insertTestSample(t, now.Add(-2*time.Hour), "old", 90)
+	insertTestSample(t, now.Add(-time.Hour), "old", 95)
+	insertTestSample(t, now.Add(-time.Hour), "new", 95) // The same run under both IDs
+	insertTestSample(t, now, "new", 100)
+	insertTestSample(t, now, "other", 5)
+
+	var path string
+	if err := historyDB.QueryRow(`SELECT file FROM pragma_database_list WHERE name = 'main'`).Scan(&path); err != nil {
+		t.Fatal(err)
+	}
+	moved, dropped, err := mergeHistorySamples(path, "old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 1 || dropped != 1 {
+		t.Errorf("moved %d and dropped %d samples, want 1 and 1", moved, dropped)
+	}
+
+	samples, err := storedSamples("new", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counts []int
+	for _, sample := range samples {
+		counts = append(counts, sample.Count)
+	}
+	if len(counts) != 3 || counts[0] != 90 || counts[1] != 95 || counts[2] != 100 {
+		t.Errorf("counts of the canonical bot = %v, want [90 95 100]", counts)
+	}
+	if samples, _ := storedSamples("other", 10); len(samples) != 1 {
+		t.Errorf("other bot has %d samples, want 1", len(samples))
+	}
+}
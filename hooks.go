@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	HookOnSample = "on_sample"
+	HookOnAlert  = "on_alert"
+	HookOnReport = "on_report"
+	HookOnStatus = "on_status"
+)
+
+var hookEvents = []string{HookOnSample, HookOnAlert, HookOnReport, HookOnStatus}
+
+// Bot statuses reported by the on_status hook.
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// hooksRunning tracks background hook executions so one-off commands can
+// wait for them before exiting.
+var hooksRunning sync.WaitGroup
+
+// Hook is an external action attached to a lifecycle event. Target is either
+// an http(s) URL that receives the payload as a POST body, or a shell command
+// that receives it on stdin.
+type Hook struct {
+	Target   string
+	Template *template.Template // Optional: renders the payload instead of the default JSON
+}
+
+// HookEvent is the data available to hook payload templates.
+type HookEvent struct {
+	Event       string    `json:"event"`
+	Time        time.Time `json:"time"`
+	Severity    string    `json:"severity,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	Downtime    int       `json:"downtime_seconds,omitempty"`
+	BotID       string    `json:"bot_id,omitempty"`
+	BotName     string    `json:"bot_name,omitempty"`
+	ServerCount int       `json:"server_count,omitempty"`
+	Installs    int       `json:"user_installs,omitempty"`
+	Patrons     int       `json:"patrons,omitempty"`
+	Stars       int       `json:"stars,omitempty"`
+	OpenIssues  int       `json:"open_issues,omitempty"`
+	Source      string    `json:"source,omitempty"`
+	Estimated   bool      `json:"estimated,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	ErrorKind   string    `json:"error_kind,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	Bots        []HookBot `json:"bots,omitempty"`
+}
+
+type HookBot struct {
+	BotID       string `json:"bot_id"`
+	BotName     string `json:"bot_name"`
+	ServerCount int    `json:"server_count"`
+	Error       string `json:"error,omitempty"`
+	ErrorKind   string `json:"error_kind,omitempty"`
+}
+
+// loadHooks reads HOOK_ON_<EVENT> and HOOK_ON_<EVENT>_TEMPLATE for each event.
+func loadHooks() map[string]Hook {
+	hooks := make(map[string]Hook)
+
+	for _, event := range hookEvents {
+		envName := "HOOK_" + strings.ToUpper(event)
+		target := strings.TrimSpace(os.Getenv(envName))
+		if target == "" {
+			continue
+		}
+
+		hook := Hook{Target: target}
+		if tmpl := os.Getenv(envName + "_TEMPLATE"); tmpl != "" {
+			parsed, err := template.New(event).Funcs(template.FuncMap{"json": toJSON}).Parse(tmpl)
+			if err != nil {
+				log.Fatalf("Invalid %s_TEMPLATE: %v", envName, err)
+			}
+			hook.Template = parsed
+		}
+
+		hooks[event] = hook
+		log.Printf("Registered %s hook", event)
+	}
+
+	return hooks
+}
+
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func sampleHookEvent(stats BotStats) HookEvent {
+	event := HookEvent{
+		Event:       HookOnSample,
+		Time:        time.Now(),
+		BotID:       stats.BotID,
+		BotName:     stats.BotName,
+		ServerCount: stats.ServerCount,
+		Installs:    stats.UserInstalls,
+		Patrons:     stats.Patrons,
+		Source:      stats.Source,
+		Estimated:   stats.Estimated,
+		Error:       errorString(stats.Error),
+		ErrorKind:   errorKind(stats.Error),
+	}
+	if stats.Repo != nil {
+		event.Stars = stats.Repo.Stars
+		event.OpenIssues = stats.Repo.OpenIssues
+	}
+	return event
+}
+
+// statusHookEvent describes a bot going down (its fetch started failing) or
+// coming back up, with how long it was down.
+func statusHookEvent(stats BotStats, status string, downtime time.Duration) HookEvent {
+	return HookEvent{
+		Event:     HookOnStatus,
+		Time:      time.Now(),
+		Status:    status,
+		Downtime:  int(downtime.Seconds()),
+		BotID:     stats.BotID,
+		BotName:   stats.BotName,
+		Error:     errorString(stats.Error),
+		ErrorKind: errorKind(stats.Error),
+	}
+}
+
+func reportHookEvent(message string, allStats []BotStats) HookEvent {
+	event := HookEvent{
+		Event:   HookOnReport,
+		Time:    time.Now(),
+		Message: message,
+	}
+	for _, stats := range allStats {
+		event.Bots = append(event.Bots, HookBot{
+			BotID:       stats.BotID,
+			BotName:     stats.BotName,
+			ServerCount: stats.ServerCount,
+			Error:       errorString(stats.Error),
+			ErrorKind:   errorKind(stats.Error),
+		})
+	}
+	return event
+}
+
+// fireHook runs the hook registered for the event in the background, if any.
+func fireHook(event HookEvent) {
+	hook, exists := config.Hooks[event.Event]
+	if !exists {
+		return
+	}
+
+	hooksRunning.Add(1)
+	go func() {
+		defer hooksRunning.Done()
+		err := runHook(hook, event)
+		metrics.recordDelivery("hook "+event.Event, err)
+		if err != nil {
+			log.Printf("Error running %s hook: %v", event.Event, err)
+		}
+	}()
+}
+
+func runHook(hook Hook, event HookEvent) error {
+	var payload bytes.Buffer
+	if hook.Template != nil {
+		if err := hook.Template.Execute(&payload, event); err != nil {
+			return fmt.Errorf("failed to render payload: %v", err)
+		}
+	} else if err := json.NewEncoder(&payload).Encode(event); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(hook.Target, "http://") || strings.HasPrefix(hook.Target, "https://") {
+		req, err := http.NewRequest("POST", hook.Target, &payload)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		identifyRequest(req)
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("hook endpoint returned status %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Target)
+	cmd.Stdin = &payload
+	cmd.Env = append(os.Environ(), "HOOK_EVENT="+event.Event)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("command failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import "net/http"
+
+const defaultUserAgent = "statbot/1.0 (+https://github.com/xemonodesign/bot-watcher)"
+
+// identifyRequest sets the headers that let bot lists and webhook owners
+// tell which watcher is calling: USER_AGENT, a CONTACT address sent as the
+// From header, and a DEPLOYMENT_ID so several deployments sharing an IP can
+// be told apart when coordinating rate limits.
+func identifyRequest(req *http.Request) {
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", config.UserAgent)
+	}
+	if config.Contact != "" {
+		req.Header.Set("From", config.Contact)
+	}
+	if config.DeploymentID != "" {
+		req.Header.Set("X-Statbot-Deployment", config.DeploymentID)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// botListing is the part of a bot list page the watcher compares.
+type botListing struct {
+	ShortDesc string
+	Tags      []string
+}
+
+var (
+	listingMu    sync.Mutex
+	lastListings = make(map[string]botListing) // List/Bot ID -> Listing at the last check
+)
+
+// DBLBot is the part of a discordbotlist.com listing the watcher uses.
+type DBLBot struct {
+	ShortDescription string   `json:"short_description"`
+	Tags             []string `json:"tags"`
+	Upvotes          *int     `json:"upvotes"` // Votes of all time, when the list includes them
+}
+
+// checkListings posts a diff when the short description or tags of the
+// bot's top.gg or DBL listing changed since the last check. Both listings
+// are fetched by the caller, and are nil when they couldn't be fetched
+// (top.gg also without TOPGG_TOKEN).
+func checkListings(botID, botName string, topgg *TopGGBot, dbl *DBLBot) {
+	if topgg != nil {
+		checkListing("top.gg", botID, botName, botListing{ShortDesc: topgg.ShortDesc, Tags: topgg.Tags})
+	}
+	if dbl != nil {
+		checkListing("DBL", botID, botName, botListing{ShortDesc: dbl.ShortDescription, Tags: dbl.Tags})
+	}
+}
+
+func checkListing(list, botID, botName string, listing botListing) {
+	listingMu.Lock()
+	previous, known := lastListings[list+"/"+botID]
+	lastListings[list+"/"+botID] = listing
+	listingMu.Unlock()
+
+	if !known || !moduleEnabled(ModulePublishing) {
+		return
+	}
+
+	diff := listingDiff(previous, listing)
+	if diff == "" {
+		return
+	}
+
+	message := fmt.Sprintf("📝 %s の%sの掲載情報が変更されました\n```diff\n%s```", botName, list, diff)
+	if _, err := sendChannelMessage(config.ListingChannelID, message); err != nil {
+		log.Printf("Error posting %s listing change for bot %s: %v", list, botID, err)
+	}
+}
+
+func listingDiff(previous, current botListing) string {
+	var diff string
+
+	if previous.ShortDesc != current.ShortDesc {
+		diff += "- 説明: " + previous.ShortDesc + "\n"
+		diff += "+ 説明: " + current.ShortDesc + "\n"
+	}
+
+	currentTags := make(map[string]bool)
+	for _, tag := range current.Tags {
+		currentTags[tag] = true
+	}
+	previousTags := make(map[string]bool)
+	for _, tag := range previous.Tags {
+		previousTags[tag] = true
+		if !currentTags[tag] {
+			diff += "- タグ: " + tag + "\n"
+		}
+	}
+	for _, tag := range current.Tags {
+		if !previousTags[tag] {
+			diff += "+ タグ: " + tag + "\n"
+		}
+	}
+
+	return diff
+}
+
+// getDBLBot fetches the bot's public discordbotlist.com listing, which
+// needs no token.
+func getDBLBot(botID string) (*DBLBot, error) {
+	url := fmt.Sprintf("https://discordbotlist.com/api/v1/bots/%s", botID)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	identifyRequest(req)
+	req = tagBot(req, botID)
+
+	waitForHost(url)
+	client := &http.Client{Timeout: fetchTimeout(botID, "dbl")}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("DBL API returned status %d", resp.StatusCode)
+		captureResponse("dbl", botID, url, resp.StatusCode, body, err)
+		return nil, err
+	}
+
+	var bot DBLBot
+	if err := json.Unmarshal(body, &bot); err != nil {
+		captureResponse("dbl", botID, url, resp.StatusCode, body, err)
+		return nil, err
+	}
+	return &bot, nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestListingDiff(t *testing.T) {
+	previous := botListing{ShortDesc: "多機能な音楽bot", Tags: []string{"Music", "Fun"}}
+	tests := []struct {
+		name    string
+		current botListing
+		want    string
+	}{
+		{"unchanged", botListing{ShortDesc: "多機能な音楽bot", Tags: []string{"Fun", "Music"}}, ""},
+		{"description", botListing{ShortDesc: "最強の音楽bot", Tags: []string{"Music", "Fun"}},
+			"- 説明: 多機能な音楽bot\n+ 説明: 最強の音楽bot\n"},
+		{"tags", botListing{ShortDesc: "多機能な音楽bot", Tags: []string{"Music", "Moderation"}},
+			"- タグ: Fun\n+ タグ: Moderation\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := listingDiff(previous, test.current); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -10,8 +10,11 @@ import (
 	"os/signal"
 	"runtime"
 	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -21,12 +24,151 @@ import (
 
 type Config struct {
 	DiscordToken     string
-	ChannelID        string
+	ChannelID        string            // Channel ID, "#name" or "GUILD_ID/#name"
+	ChannelGuildID   string            // Guild used to resolve "#name" channel references
 	TargetBotIDs     []string          // Multiple bot IDs
+	BotIDCheck       string            // fail, warn or off: what to do when a target ID isn't a Discord bot
 	TopGGToken       string            // Optional: for top.gg API
 	NotificationTime string            // Cron format or time like "09:00"
 	CustomWebhooks   map[string]string // Bot ID -> Webhook URL for custom stats endpoints
 	BotTokens        map[string]string // Bot ID -> Bot Token for direct API access
+	CountCommands    map[string]string // Bot ID -> Command that prints the server count
+	ReportScript     string            // Optional: Lua script that transforms stats before rendering
+	Hooks            map[string]Hook   // Lifecycle event -> external hook
+	BotNotes         map[string]string // Bot ID -> Freeform note shown in reports
+	NetworkStats     bool              // Report the deduplicated guild reach of owned bots
+	Destinations     []Destination     // Report channels with their language and timezone
+	PublicMetrics    map[string]bool   // Metrics allowed in public (redacted) reports
+	PrivateBots      map[string]bool   // Bots that are monitored but left out of totals and public output
+	ComputedMetrics  []ComputedMetric  // Metrics derived from the sample by COMPUTED_<NAME> expressions
+	Aggregates       []Aggregate       // Summary fields combining groups of bots (AGGREGATE_<NAME>)
+	ReportTotal      bool              // Show the plain sum in full reports when no aggregates replace it
+	BadgeTracking    bool              // Alert when Discord verification or top.gg certification changes
+	ListingTracking  bool              // Post a diff when a top.gg or DBL listing's description or tags change
+	ListingChannelID string            // Channel for listing diffs (defaults to CHANNEL_ID)
+	VoteTracking     bool              // Show top.gg and DBL votes per bot and combined
+	PreviewChannelID string            // Optional: private channel where reports are previewed before sending
+	PreviewMinutes   int               // How long before the scheduled time the preview is posted
+	StateFile        string            // Optional: file recording delivered runs so repeats are skipped
+	RequestLog       string            // Optional: JSON lines file of every outbound HTTP request
+	SnapshotFile     string            // Optional: JSON lines file of every bot's metrics at each run
+	BackfillFile     string            // Optional: CSV export of past counts that seeds trends at startup
+	HistoryDB        string            // Optional: SQLite database of every bot's count at each run
+	ReportImage      bool              // Send reports as an image card instead of text
+	ReportImageFont  string            // Optional: TTF/OTF font for report cards (e.g. for Japanese names)
+	Modules          map[string]bool   // Subsystems that run (see modules.go)
+	RulesFile        string            // Optional: YAML alert rules, reloaded when it changes
+	AuditLog         string            // Optional: JSON lines file of actions taken through Discord
+	DryRun           map[string]bool   // Notification targets that are logged instead of sent
+	CaptureDir       string            // Optional: directory for raw responses of failed fetches
+	CaptureDays      int               // How long captured responses are kept
+	DebugToken       string            // Bearer token callers of /api/debug must send; the listing is off when empty
+	UsernameRefresh  time.Duration     // How long a bot's cached username is used before it is looked up again
+	SourceRacing     bool              // Query the first two sources in parallel, first success wins
+
+	// Report approval
+	ApprovalChannelID     string // Optional: channel where moderators approve public reports
+	ApprovalMinutes       int    // How long a report waits for approval
+	ApprovalTimeoutAction string // internal (only public reports wait) or skip (the whole report waits)
+
+	// Bulk fetching
+	FetchConcurrency int           // Bots fetched at the same time
+	HostRateLimit    int           // Optional: requests per second to each bot list or webhook host
+	FetchDeadline    time.Duration // Optional: report with whatever finished after this long
+	PartialReports   bool          // Show bots past the deadline as pending and edit them in later
+
+	// Fetch timeouts
+	FetchTimeout   time.Duration            // Default HTTP timeout for a single source request
+	SourceTimeouts map[string]time.Duration // Source key -> Timeout
+	BotTimeouts    map[string]time.Duration // Bot ID -> Timeout for all of its sources
+
+	DiscordAPIVersions map[string]string // Feature -> Discord API version called directly instead of via discordgo
+
+	// Request identification
+	UserAgent    string // User-Agent sent to bot lists and webhooks
+	Contact      string // Optional: contact address sent as the From header
+	DeploymentID string // Optional: identifies this deployment to bot lists
+
+	UserInstallTracking bool // Report approximate user installs of owned bots
+
+	// Supporters
+	PatreonToken     string            // Optional: Patreon creator access token
+	PatreonCampaigns map[string]string // Bot ID -> Patreon campaign ID
+	GitHubToken      string            // Optional: raises the GitHub API rate limit
+	GitHubRepos      map[string]string // Bot ID -> "owner/repo"
+
+	// Anomaly detection
+	AnomalyDetection  bool // Alert on changes that are unusual for the bot
+	AnomalyThreshold  int  // Standard deviations from the moving average that count as unusual
+	AnomalyMinSamples int  // Changes observed before a bot is evaluated
+
+	// Smoothing of noisy sources
+	Smoothing map[string]Smoothing // Metric -> Rolling window applied before alert evaluation
+
+	// Source reconciliation
+	CanonicalSources    map[string]string // Bot ID -> Source key (webhook, discord, topgg, dbl, direct)
+	CrossCheck          bool              // Compare canonical counts with the next available source
+	CrossCheckTolerance int               // Percent difference tolerated before warning
+
+	// Change feed
+	FeedChannelID     string // Optional: channel for one-line change events
+	FeedMilestoneStep int    // Post when a count crosses a multiple of this
+	FeedMinChange     int    // Post when a count changes by at least this much between runs
+
+	// Milestone announcements
+	MastodonURL        string             // Mastodon instance URL, e.g. https://mastodon.social
+	MastodonToken      string             // Optional: access token with the write:statuses scope
+	BlueskyHandle      string             // Optional: Bluesky handle to post as
+	BlueskyAppPassword string             // App password for BlueskyHandle
+	AnnounceTemplate   *template.Template // Announcement text
+
+	// Report schedules
+	ReportSchedules   []ReportSchedule // Named reports with their own schedule, format and destinations (replace NOTIFICATION_TIME)
+	ScheduleTolerance time.Duration    // How late a scheduled report may start before the watchdog alerts (0 disables it)
+
+	// Report layout
+	ReportSort           string // config, name, count or growth
+	ReportFieldLayout    string // inline ("name : count") or block (name and count on separate lines)
+	ReportTotalsPosition string // top or bottom
+
+	// Alerting
+	CommandGuildID      string                  // Optional: register slash commands in this guild only
+	AlertRoutes         map[Severity]AlertRoute // Severity -> destinations
+	Notifiers           []notifierEntry         // Alert routes as notifiers with their filters and retries
+	PagerDutyRoutingKey string                  // Optional: PagerDuty Events API v2 routing key
+	Email               EmailConfig             // Optional: SMTP settings for email alerts
+	NtfyURL             string                  // Optional: ntfy topic URL, e.g. https://ntfy.sh/my-topic
+	NtfyToken           string                  // Optional: access token for protected ntfy topics
+	PushoverToken       string                  // Optional: Pushover application token
+	PushoverUser        string                  // Optional: Pushover user or group key
+	TeamsWebhookURL     string                  // Optional: Microsoft Teams incoming webhook URL
+	EscalationSteps     []EscalationStep        // Re-sends of unacknowledged critical alerts
+	EscalationRepeat    time.Duration           // Optional: repeat the last step this often until acknowledged
+
+	// HTTP server
+	HTTPAddr         string   // Optional: listen address for badges and the public API, e.g. :8080
+	PublicAPIBots    []string // Bots exposed by the unauthenticated count API
+	PublicAPIOrigins []string // Optional: origins allowed to call the count API (any when empty)
+
+	// top.gg caching proxy
+	ProxyToken string        // Bearer token callers of /proxy/topgg/ must send; the proxy is off when empty
+	ProxyTTL   time.Duration // How long upstream responses are cached
+
+	// Count sources on the bot's own infrastructure
+	SQLSources     map[string]SQLSource     // Bot ID -> Database query that returns the server count
+	RedisSources   map[string]RedisSource   // Bot ID -> Redis key holding the server count
+	GraphQLSources map[string]GraphQLSource // Bot ID -> GraphQL query that returns the server count
+
+	// Remediation of self-hosted bots
+	RemediationActions  map[string]string // Bot ID -> action run when the bot stays down
+	RedeployActions     map[string]string // Bot ID -> action run by /bot redeploy
+	OpsRoleIDs          []string          // Optional: roles allowed to use /bot (Manage Server when empty)
+	RemediationAfter    time.Duration     // How long a bot must be down before acting
+	RemediationCooldown time.Duration     // Minimum time between actions for the same bot
+	RemediationLimit    int               // Maximum actions per bot in 24 hours
+	RemediationDryRun   bool              // Only report what would be done
+
+	PprofAddr string // Optional: private listen address for pprof, e.g. localhost:6060
 }
 
 type TopGGStats struct {
@@ -38,7 +180,29 @@ type BotStats struct {
 	BotID       string
 	BotName     string
 	ServerCount int
+	Source      string // Source that produced the count
+	Estimated   bool   // The canonical source failed and another source was used
 	Error       error
+	Sources     map[string]int    // Source name -> Count of every source that answered
+	Fields      map[string]string // Extra fields set by the report script
+	Change      int               // Server count change since the previous run
+	HasChange   bool              // Whether a previous count was known
+	Pending     bool              // Still being fetched after the deadline
+
+	DayChange     int  // Server count change since about a day ago (needs HISTORY_DB)
+	HasDayChange  bool // Whether a count from a day ago is stored
+	WeekChange    int  // Server count change since about a week ago (needs HISTORY_DB)
+	HasWeekChange bool // Whether a count from a week ago is stored
+
+	UserInstalls    int         // Approximate user installs of the app (owned bots only)
+	HasUserInstalls bool        // Whether the user install count was fetched
+	Patrons         int         // Patron count of the bot's Patreon campaign
+	HasPatrons      bool        // Whether the patron count was fetched
+	Votes           []ListVotes // Votes per bot list (needs VOTE_TRACKING)
+
+	Repo *GitHubRepo // Stars and open issues of the bot's repository
+
+	Computed map[string]float64 // Per-bot computed metrics (see computed.go)
 }
 
 var (
@@ -47,10 +211,77 @@ var (
 )
 
 func main() {
+	// The setup wizard and config migration run before there is a
+	// configuration to load
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		runInitWizard()
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate-config" {
+		runMigrateConfig(os.Args[2:])
+		return
+	}
+
+	loadConfig()
+
+	// Create Discord session
+	var err error
+	session, err = discordgo.New("Bot " + config.DiscordToken)
+	if err != nil {
+		log.Fatal("Error creating Discord session:", err)
+	}
+
+	// Run a one-off CLI command instead of the bot if one was given
+	if len(os.Args) > 1 {
+		runCLI(os.Args[1:])
+		return
+	}
+
+	verifyTargetBots()
+
+	setupRules()
+	openHistoryDB()
+	backfillHistory()
+
+	// Register handlers
+	session.AddHandler(ready)
+	session.AddHandler(interactionCreate)
+	session.AddHandler(channelDelete)
+
+	// Open connection to Discord
+	err = session.Open()
+	if err != nil {
+		log.Fatal("Error opening Discord connection:", err)
+	}
+	defer session.Close()
+
+	// Setup cron job for daily notifications
+	if moduleEnabled(ModuleSampling) {
+		setupDailyNotification()
+	}
+
+	// Serve badges if HTTP_ADDR is set
+	startHTTPServer()
+	startProfiling()
+
+	// Setup memory cleanup routine
+	//setupMemoryCleanup()
+
+	// Wait for interrupt signal
+	fmt.Println("Bot is running. Press CTRL+C to exit.")
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	<-sc
+}
+
+// loadConfig reads the configuration from the environment (and .env).
+func loadConfig() {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
+	loadConfigFile()
+	validateEnv()
 
 	// Load configuration
 	targetBotIDs := os.Getenv("TARGET_BOT_IDS")
@@ -71,15 +302,7 @@ func main() {
 	}
 
 	// Parse custom webhooks (format: BOT_ID:WEBHOOK_URL,BOT_ID:WEBHOOK_URL)
-	customWebhooks := make(map[string]string)
-	if webhooks := os.Getenv("CUSTOM_WEBHOOKS"); webhooks != "" {
-		for _, webhook := range strings.Split(webhooks, ",") {
-			parts := strings.Split(strings.TrimSpace(webhook), ":")
-			if len(parts) == 2 {
-				customWebhooks[parts[0]] = parts[1]
-			}
-		}
-	}
+	customWebhooks := parseBotPairs(os.Getenv("CUSTOM_WEBHOOKS"))
 
 	// Parse bot tokens (format: BOT_ID:TOKEN,BOT_ID:TOKEN)
 	botTokens := make(map[string]string)
@@ -110,17 +333,140 @@ func main() {
 		}
 	}
 
+	// Parse bot notes: BOT_NOTE_<BOT_ID>=NOTE, or the legacy BOT_NOTES list
+	// (BOT_ID:NOTE,BOT_ID:NOTE), which can't hold notes with commas
+	botNotes := parseBotPairs(os.Getenv("BOT_NOTES"))
+	for botID, note := range getEnvPerBot(botNotePrefix) {
+		botNotes[botID] = note
+	}
+
 	log.Printf("Configured %d bot tokens", len(botTokens))
 	log.Printf("Configured %d custom webhooks", len(customWebhooks))
 
 	config = Config{
 		DiscordToken:     os.Getenv("DISCORD_TOKEN"),
 		ChannelID:        os.Getenv("CHANNEL_ID"),
+		ChannelGuildID:   os.Getenv("CHANNEL_GUILD_ID"),
 		TargetBotIDs:     botIDs,
+		BotIDCheck:       getEnvDefault("BOT_ID_CHECK", "fail"),
 		TopGGToken:       os.Getenv("TOPGG_TOKEN"),
 		NotificationTime: os.Getenv("NOTIFICATION_TIME"),
 		CustomWebhooks:   customWebhooks,
 		BotTokens:        botTokens,
+		CountCommands:    getEnvPerBot(countCommandPrefix),
+		ReportScript:     os.Getenv("REPORT_SCRIPT"),
+		Hooks:            loadHooks(),
+		BotNotes:         botNotes,
+		NetworkStats:     os.Getenv("NETWORK_STATS") == "true",
+		ReportTotal:      os.Getenv("REPORT_TOTAL") == "true",
+		PublicMetrics:    loadPublicMetrics(),
+		PrivateBots:      getEnvSet("PRIVATE_BOTS"),
+		ComputedMetrics:  loadComputedMetrics(),
+		BadgeTracking:    os.Getenv("BADGE_TRACKING") == "true",
+		ListingTracking:  os.Getenv("LISTING_TRACKING") == "true",
+		ListingChannelID: os.Getenv("LISTING_CHANNEL_ID"),
+		VoteTracking:     os.Getenv("VOTE_TRACKING") == "true",
+		PreviewChannelID: os.Getenv("PREVIEW_CHANNEL_ID"),
+		PreviewMinutes:   getEnvInt("PREVIEW_MINUTES", 30),
+		StateFile:        os.Getenv("STATE_FILE"),
+		RequestLog:       os.Getenv("REQUEST_LOG"),
+		SnapshotFile:     os.Getenv("SNAPSHOT_FILE"),
+		BackfillFile:     os.Getenv("BACKFILL_FILE"),
+		HistoryDB:        os.Getenv("HISTORY_DB"),
+		ReportImage:      os.Getenv("REPORT_IMAGE") == "true",
+		ReportImageFont:  os.Getenv("REPORT_IMAGE_FONT"),
+		Modules:          loadModules(),
+		RulesFile:        os.Getenv("RULES_FILE"),
+		AuditLog:         os.Getenv("AUDIT_LOG"),
+		DryRun:           loadDryRun(),
+		CaptureDir:       os.Getenv("DEBUG_CAPTURE_DIR"),
+		CaptureDays:      getEnvInt("DEBUG_CAPTURE_DAYS", 7),
+		DebugToken:       os.Getenv("DEBUG_TOKEN"),
+		UsernameRefresh:  time.Duration(getEnvInt("USERNAME_REFRESH_HOURS", 24)) * time.Hour,
+		SourceRacing:     os.Getenv("SOURCE_RACING") == "true",
+
+		ApprovalChannelID:     os.Getenv("APPROVAL_CHANNEL_ID"),
+		ApprovalMinutes:       getEnvInt("APPROVAL_MINUTES", 30),
+		ApprovalTimeoutAction: getEnvDefault("APPROVAL_TIMEOUT_ACTION", "internal"),
+
+		FetchConcurrency: getEnvInt("FETCH_CONCURRENCY", 1),
+		HostRateLimit:    getEnvInt("HOST_RATE_LIMIT", 0),
+		FetchDeadline:    time.Duration(getEnvInt("FETCH_DEADLINE", 0)) * time.Second,
+		PartialReports:   os.Getenv("PARTIAL_REPORTS") == "true",
+
+		FetchTimeout:   time.Duration(getEnvInt("FETCH_TIMEOUT", 10)) * time.Second,
+		SourceTimeouts: parseTimeouts("SOURCE_TIMEOUTS"),
+		BotTimeouts:    parseTimeouts("BOT_TIMEOUTS"),
+
+		DiscordAPIVersions: parseBotPairs(os.Getenv("DISCORD_API_VERSIONS")),
+
+		UserAgent:    getEnvDefault("USER_AGENT", defaultUserAgent),
+		Contact:      os.Getenv("CONTACT"),
+		DeploymentID: os.Getenv("DEPLOYMENT_ID"),
+
+		UserInstallTracking: os.Getenv("USER_INSTALL_TRACKING") == "true",
+
+		PatreonToken:     os.Getenv("PATREON_TOKEN"),
+		PatreonCampaigns: parseBotPairs(os.Getenv("PATREON_CAMPAIGNS")),
+		GitHubToken:      os.Getenv("GITHUB_TOKEN"),
+		GitHubRepos:      parseBotPairs(os.Getenv("GITHUB_REPOS")),
+
+		AnomalyDetection:  os.Getenv("ANOMALY_DETECTION") == "true",
+		AnomalyThreshold:  getEnvInt("ANOMALY_THRESHOLD", 3),
+		AnomalyMinSamples: getEnvInt("ANOMALY_MIN_SAMPLES", 7),
+
+		Smoothing: loadSmoothing(),
+
+		CanonicalSources:    parseBotPairs(os.Getenv("CANONICAL_SOURCES")),
+		CrossCheck:          os.Getenv("CROSS_CHECK") == "true",
+		CrossCheckTolerance: getEnvInt("CROSS_CHECK_TOLERANCE", 10),
+
+		FeedChannelID:     os.Getenv("FEED_CHANNEL_ID"),
+		FeedMilestoneStep: getEnvInt("FEED_MILESTONE_STEP", 0),
+		FeedMinChange:     getEnvInt("FEED_MIN_CHANGE", 0),
+
+		MastodonURL:        os.Getenv("MASTODON_URL"),
+		MastodonToken:      os.Getenv("MASTODON_TOKEN"),
+		BlueskyHandle:      os.Getenv("BLUESKY_HANDLE"),
+		BlueskyAppPassword: os.Getenv("BLUESKY_APP_PASSWORD"),
+		AnnounceTemplate:   loadAnnounceTemplate(),
+
+		ScheduleTolerance: time.Duration(getEnvInt("SCHEDULE_TOLERANCE", 5)) * time.Minute,
+
+		ReportSort:           getEnvDefault("REPORT_SORT", "config"),
+		ReportFieldLayout:    getEnvDefault("REPORT_FIELD_LAYOUT", "inline"),
+		ReportTotalsPosition: getEnvDefault("REPORT_TOTALS_POSITION", "bottom"),
+
+		CommandGuildID:      os.Getenv("COMMAND_GUILD_ID"),
+		PagerDutyRoutingKey: os.Getenv("PAGERDUTY_ROUTING_KEY"),
+		Email:               loadEmailConfig(),
+		NtfyURL:             os.Getenv("NTFY_URL"),
+		NtfyToken:           os.Getenv("NTFY_TOKEN"),
+		PushoverToken:       os.Getenv("PUSHOVER_TOKEN"),
+		PushoverUser:        os.Getenv("PUSHOVER_USER"),
+		TeamsWebhookURL:     os.Getenv("TEAMS_WEBHOOK_URL"),
+		EscalationRepeat:    time.Duration(getEnvInt("ESCALATION_REPEAT", 0)) * time.Minute,
+
+		HTTPAddr:         os.Getenv("HTTP_ADDR"),
+		PublicAPIBots:    getEnvList("PUBLIC_API_BOTS"),
+		PublicAPIOrigins: getEnvList("PUBLIC_API_ORIGINS"),
+
+		ProxyToken: os.Getenv("PROXY_TOKEN"),
+		ProxyTTL:   time.Duration(getEnvInt("PROXY_TTL", 300)) * time.Second,
+
+		SQLSources:     loadSQLSources(),
+		RedisSources:   loadRedisSources(),
+		GraphQLSources: loadGraphQLSources(),
+
+		RemediationActions:  getEnvPerBot(remediationPrefix),
+		RedeployActions:     getEnvPerBot(redeployPrefix),
+		OpsRoleIDs:          getEnvList("OPS_ROLE_IDS"),
+		RemediationAfter:    time.Duration(getEnvInt("REMEDIATION_AFTER", 10)) * time.Minute,
+		RemediationCooldown: time.Duration(getEnvInt("REMEDIATION_COOLDOWN", 30)) * time.Minute,
+		RemediationLimit:    getEnvInt("REMEDIATION_LIMIT", 3),
+		RemediationDryRun:   os.Getenv("REMEDIATION_DRY_RUN") == "true",
+
+		PprofAddr: os.Getenv("PPROF_ADDR"),
 	}
 
 	if config.DiscordToken == "" || config.ChannelID == "" || len(config.TargetBotIDs) == 0 {
@@ -131,62 +477,229 @@ func main() {
 		config.NotificationTime = "09:00" // Default to 9 AM
 	}
 
-	// Create Discord session
-	var err error
-	session, err = discordgo.New("Bot " + config.DiscordToken)
-	if err != nil {
-		log.Fatal("Error creating Discord session:", err)
+	if config.ListingChannelID == "" {
+		config.ListingChannelID = config.ChannelID
 	}
 
-	// Register ready handler
-	session.AddHandler(ready)
+	// Alert routes and destinations may fall back to CHANNEL_ID, so load them after validation
+	config.AlertRoutes = loadAlertRoutes()
+	config.Notifiers = buildNotifiers(config.AlertRoutes)
+	config.EscalationSteps = loadEscalationSteps()
+	config.Destinations = loadDestinations()
+	config.Aggregates = loadAggregates()
+	config.ReportSchedules = loadReportSchedules()
 
-	// Open connection to Discord
-	err = session.Open()
+	loadState()
+	setupRequestLog()
+}
+
+// getEnvDefault reads an environment variable, returning the default when
+// it is unset.
+func getEnvDefault(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvInt reads an integer environment variable, returning the default
+// when it is unset or invalid.
+func getEnvInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
 	if err != nil {
-		log.Fatal("Error opening Discord connection:", err)
+		log.Printf("Invalid %s %q, using default %d", name, value, defaultValue)
+		return defaultValue
 	}
-	defer session.Close()
+	return parsed
+}
 
-	// Setup cron job for daily notifications
-	setupDailyNotification()
+// getEnvList reads a comma-separated environment variable, skipping empty
+// items.
+func getEnvList(name string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
 
-	// Setup memory cleanup routine
-	//setupMemoryCleanup()
+// getEnvSet reads a comma-separated list as a set.
+func getEnvSet(name string) map[string]bool {
+	set := make(map[string]bool)
+	for _, item := range getEnvList(name) {
+		set[item] = true
+	}
+	return set
+}
 
-	// Wait for interrupt signal
-	fmt.Println("Bot is running. Press CTRL+C to exit.")
-	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
-	<-sc
+// botNotePrefix names the per-bot variables holding report notes, e.g.
+// BOT_NOTE_<BOT_ID>="Support: https://discord.gg/example, dashboard: https://dash.example.com".
+const botNotePrefix = "BOT_NOTE_"
+
+// getEnvPerBot reads the variables named <PREFIX><BOT_ID>, for per-bot
+// values that can't go in a BOT_ID:VALUE list because they contain commas.
+func getEnvPerBot(prefix string) map[string]string {
+	values := make(map[string]string)
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		botID, found := strings.CutPrefix(name, prefix)
+		if value = strings.TrimSpace(value); found && botID != "" && value != "" {
+			values[botID] = value
+		}
+	}
+	return values
+}
+
+// parseBotPairs parses "BOT_ID:VALUE,BOT_ID:VALUE" lists. Only the first
+// colon separates the ID, so values may contain colons (e.g. URLs).
+func parseBotPairs(value string) map[string]string {
+	pairs := make(map[string]string)
+	if value == "" {
+		return pairs
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		botID, pairValue, found := strings.Cut(strings.TrimSpace(pair), ":")
+		botID = strings.TrimSpace(botID)
+		pairValue = strings.TrimSpace(pairValue)
+		if !found || botID == "" || pairValue == "" {
+			log.Printf("Invalid BOT_ID:VALUE pair: %s", pair)
+			continue
+		}
+		pairs[botID] = pairValue
+	}
+
+	return pairs
 }
 
 func ready(s *discordgo.Session, event *discordgo.Ready) {
 	log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
 
+	if moduleEnabled(ModuleCommands) {
+		registerCommands(s)
+	}
+	preflightPermissions()
+
 	// Send initial notification
-	go checkAndNotifyServerCount()
+	if moduleEnabled(ModuleSampling) {
+		go checkAndNotifyServerCount()
+	}
+}
+
+// toCronExpr converts a time in HH:MM format to a daily cron expression.
+// Anything else is assumed to already be a cron expression.
+func toCronExpr(notificationTime string) string {
+	if len(notificationTime) == 5 && notificationTime[2] == ':' {
+		// Convert HH:MM to cron format
+		hour := notificationTime[:2]
+		minute := notificationTime[3:]
+		return fmt.Sprintf("%s %s * * *", minute, hour)
+	}
+	return notificationTime
+}
+
+// reportGroup is one scheduled run: a report schedule's destinations in one
+// timezone, which share a run so stats are fetched once.
+type reportGroup struct {
+	Schedule     ReportSchedule
+	Group        string // Identifies the run in run IDs, state and the watchdog
+	Expr         string // Cron expression in the group's timezone
+	Destinations []Destination
+}
+
+// reportGroups splits the report schedules into runs by timezone. Without
+// named schedules the report at NOTIFICATION_TIME is the only schedule.
+func reportGroups() []reportGroup {
+	schedules := config.ReportSchedules
+	if len(schedules) == 0 {
+		schedules = []ReportSchedule{{
+			When:         config.NotificationTime,
+			Expr:         toCronExpr(config.NotificationTime),
+			Format:       reportFull,
+			Destinations: config.Destinations,
+		}}
+	}
+
+	var groups []reportGroup
+	for _, schedule := range schedules {
+		var timezones []string
+		byTimezone := make(map[string][]Destination)
+		for _, destination := range schedule.Destinations {
+			tz := destination.Location.String()
+			if _, exists := byTimezone[tz]; !exists {
+				timezones = append(timezones, tz)
+			}
+			byTimezone[tz] = append(byTimezone[tz], destination)
+		}
+
+		for _, tz := range timezones {
+			// The default report keeps the timezone as its group so run IDs
+			// stay the same as before schedules were configured
+			group := tz
+			if schedule.Name != "" {
+				group = schedule.Name + "/" + tz
+			}
+
+			expr := schedule.Expr
+			if tz != "Local" && !strings.HasPrefix(expr, "CRON_TZ=") && !strings.HasPrefix(expr, "TZ=") {
+				expr = "CRON_TZ=" + tz + " " + expr
+			}
+			groups = append(groups, reportGroup{Schedule: schedule, Group: group, Expr: expr, Destinations: byTimezone[tz]})
+		}
+	}
+	return groups
 }
 
+// setupDailyNotification schedules the report at NOTIFICATION_TIME, or the
+// named REPORT_SCHEDULE_<NAME> reports instead when there are any.
 func setupDailyNotification() {
 	c := cron.New()
 
-	// Convert time to cron expression if it's in HH:MM format
-	cronExpr := config.NotificationTime
-	if len(config.NotificationTime) == 5 && config.NotificationTime[2] == ':' {
-		// Convert HH:MM to cron format
-		hour := config.NotificationTime[:2]
-		minute := config.NotificationTime[3:]
-		cronExpr = fmt.Sprintf("%s %s * * *", minute, hour)
-	}
+	for _, run := range reportGroups() {
+		schedule, group, expr, destinations := run.Schedule, run.Group, run.Expr, run.Destinations
+		tz := destinations[0].Location.String()
+		named := schedule.Name != ""
+
+		var id cron.EntryID
+		id, err := c.AddFunc(expr, func() {
+			// The entry's Prev is the time this run was scheduled for
+			scheduledAt := c.Entry(id).Prev
+			scheduledRunStarted(group, scheduledAt)
+			if named {
+				startSchedulePeriod(group)
+				defer finishSchedulePeriod(group)
+			}
+			runScheduledReport(reportRunID(scheduledAt, group), scheduledAt, group, destinations)
+		})
+		if err != nil {
+			log.Fatal("Error setting up cron job:", err)
+		}
+		watchSchedule(expr, group)
+		if schedule.Name == "" {
+			log.Printf("Daily notification scheduled at: %s (%s, %d destinations)", config.NotificationTime, tz, len(destinations))
+		} else {
+			log.Printf("Report %s scheduled %s as %s (%s, %d destinations)", schedule.Name, schedule.When, schedule.Format, tz, len(destinations))
+		}
+		resumeInterruptedRun(expr, group, destinations)
 
-	_, err := c.AddFunc(cronExpr, checkAndNotifyServerCount)
-	if err != nil {
-		log.Fatal("Error setting up cron job:", err)
+		// Previews are for the regular report; frequent compact reports
+		// and summaries go out as they are
+		if config.PreviewChannelID != "" && moduleEnabled(ModulePublishing) && schedule.Format == reportFull {
+			if err := schedulePreview(c, expr, group, destinations); err != nil {
+				log.Fatal("Error setting up preview cron job:", err)
+			}
+		}
 	}
 
 	c.Start()
-	log.Printf("Daily notification scheduled at: %s", config.NotificationTime)
+	startWatchdog()
 }
 
 func setupMemoryCleanup() {
@@ -225,101 +738,425 @@ func performMemoryCleanup() {
 }
 
 func checkAndNotifyServerCount() {
-	var allStats []BotStats
+	notifyDestinations("", time.Time{}, config.Destinations)
+}
+
+// notifyDestinations sends a report to each of the given destinations,
+// with the stats of the tick the run is due at (see collectTick).
+func notifyDestinations(runID string, tick time.Time, destinations []Destination) {
+	start := time.Now()
+	defer metrics.recordRun(start)
+
+	allStats, network, late := collectTick(tick)
+	outcomes := runOutcomes(allStats)
+	publishReport(runID, destinations, allStats, network, late)
+	recordRunResult(runID, start, outcomes)
+}
+
+// publishReport sends collected stats to the destinations, all of one
+// schedule group, and keeps them updated as late results arrive.
+// Destinations the run already reached are skipped. The stats are the
+// run's own copy, and their changes are rebased on the schedule's baseline.
+func publishReport(runID string, destinations []Destination, allStats []BotStats, network *NetworkStats, late <-chan fetchResult) {
+	if !moduleEnabled(ModulePublishing) {
+		// Late bots go through alerts, history and the feed in the
+		// collection either way
+		return
+	}
 
+	baseline := reportBaseline(destinations)
+	applyBaseline(allStats, baseline)
+	reportStats := transformStats(allStats)
+
+	var reports []sentReport
+	var held []Destination
+	for _, destination := range destinations {
+		if isDelivered(runID, destination.ChannelID) {
+			log.Printf("Run %s was already delivered to channel %s, skipping", runID, destination.ChannelID)
+			continue
+		}
+
+		if requiresApproval(destination) {
+			held = append(held, destination)
+			continue
+		}
+
+		messages := sendServerCountNotification(reportStats, network, destination)
+		if len(messages) > 0 {
+			recordDelivered(runID, destination.ChannelID, messages)
+			reports = append(reports, sentReport{Destination: destination, Messages: messages})
+		}
+	}
+
+	if len(held) > 0 {
+		requestApproval(runID, held, reportStats, network)
+	}
+
+	if late != nil {
+		// transformStats returns allStats itself without a script, and
+		// reportStats may still be rendered for approval, so late results
+		// patch a copy
+		patched := make([]BotStats, len(allStats))
+		copy(patched, allStats)
+		go patchReports(patched, network, late, reports, baseline)
+	}
+
+	// Clean up memory after processing
+	runtime.GC()
+}
+
+// collectStats fetches every bot and processes the results for changes,
+// alerts and the feed. Bots still pending after the deadline arrive later on
+// the returned channel.
+func collectStats() ([]BotStats, *NetworkStats, <-chan fetchResult) {
 	// Fetch stats for all configured bots
-	for _, botID := range config.TargetBotIDs {
-		stats := BotStats{
-			BotID: botID,
+	allStats, late := fetchAllStats(config.TargetBotIDs)
+
+	recordChanges(allStats)
+	recordPeriodChanges(allStats)
+	detectDuplicateBots(allStats)
+	computeMetrics(allStats)
+	updateLatest(allStats)
+	recordSnapshots(allStats)
+	storeSamples(allStats)
+	recordHistory(allStats)
+	evaluateAlerts(allStats)
+	alertStats := smoothStats(allStats)
+	evaluateAnomalies(alertStats)
+	evaluateRules(alertStats)
+	evaluateFeed(allStats)
+
+	guilds := listOwnedGuilds()
+	var network *NetworkStats
+	if config.NetworkStats {
+		var err error
+		network, err = getNetworkStats(guilds)
+		if err != nil {
+			log.Printf("Error computing network stats: %v", err)
 		}
+	}
+	computeUniqueAggregates(guilds)
 
-		// Try to get bot name
-		user, err := session.User(botID)
-		if err == nil {
-			stats.BotName = user.Username
+	return allStats, network, late
+}
+
+// transformStats lets the report script reshape the stats if one is configured.
+func transformStats(allStats []BotStats) []BotStats {
+	if config.ReportScript == "" {
+		return allStats
+	}
+
+	transformed, err := applyReportScript(allStats)
+	if err != nil {
+		log.Printf("Error running report script, using untransformed stats: %v", err)
+		return allStats
+	}
+	return transformed
+}
+
+// countSource is one way of fetching a bot's server count. Key is the
+// short name used in configuration (e.g. CANONICAL_SOURCES).
+type countSource struct {
+	Key   string
+	Name  string
+	Fetch func() (int, error)
+}
+
+// collectBotStats fetches the name and server count of one bot.
+func collectBotStats(botID string) BotStats {
+	stats := BotStats{
+		BotID: botID,
+	}
+
+	// The listings serve the badge, listing and vote checks, so each is
+	// fetched once for all of them
+	var topgg *TopGGBot
+	if config.TopGGToken != "" && (config.BadgeTracking || config.ListingTracking || config.VoteTracking) {
+		listing, err := getTopGGBot(botID)
+		if err != nil {
+			log.Printf("Error fetching top.gg listing for bot %s: %v", botID, err)
 		} else {
-			stats.BotName = "Unknown"
+			topgg = listing
 		}
+	}
+	var dbl *DBLBot
+	if config.ListingTracking || config.VoteTracking {
+		listing, err := getDBLBot(botID)
+		if err != nil {
+			log.Printf("Error fetching DBL listing for bot %s: %v", botID, err)
+		} else {
+			dbl = listing
+		}
+	}
+	if config.VoteTracking {
+		stats.Votes = listVotes(topgg, dbl)
+	}
 
-		// Get server count
-		count, err := getServerCount(botID)
+	// Try to get bot name
+	user, err := lookupBotUser(botID)
+	lastName := trackAccount(botID, user, err)
+	if err == nil {
+		stats.BotName = user.Username
+		if config.BadgeTracking {
+			checkBadges(user, topgg)
+		}
+	} else if lastName != "" {
+		stats.BotName = lastName
+	} else {
+		stats.BotName = "Unknown"
+	}
+
+	if config.ListingTracking {
+		checkListings(botID, stats.BotName, topgg, dbl)
+	}
+
+	// Get server count
+	result, err := getServerCount(botID)
+	if err != nil {
+		stats.Error = err
+		log.Printf("Error fetching server count for bot %s: %v", botID, err)
+	} else {
+		stats.ServerCount = result.Count
+		stats.Source = result.Source
+		stats.Estimated = result.Estimated
+		stats.Sources = result.Sources
+	}
+
+	if token, exists := config.BotTokens[botID]; exists && config.UserInstallTracking {
+		installs, err := getUserInstallCount(token)
 		if err != nil {
-			stats.Error = err
-			log.Printf("Error fetching server count for bot %s: %v", botID, err)
+			log.Printf("Error fetching user install count for bot %s: %v", botID, err)
 		} else {
-			stats.ServerCount = count
+			stats.UserInstalls = installs
+			stats.HasUserInstalls = true
 		}
+	}
 
-		allStats = append(allStats, stats)
+	if campaignID, exists := config.PatreonCampaigns[botID]; exists && config.PatreonToken != "" {
+		patrons, err := getPatronCount(botID, campaignID)
+		if err != nil {
+			log.Printf("Error fetching patron count for bot %s: %v", botID, err)
+		} else {
+			stats.Patrons = patrons
+			stats.HasPatrons = true
+		}
 	}
 
-	sendServerCountNotification(allStats)
+	if repo, exists := config.GitHubRepos[botID]; exists {
+		stats.Repo, err = getGitHubRepo(botID, repo)
+		if err != nil {
+			log.Printf("Error fetching GitHub repository %s for bot %s: %v", repo, botID, err)
+		}
+	}
 
-	// Clean up memory after processing
-	runtime.GC()
+	fireHook(sampleHookEvent(stats))
+
+	return stats
 }
 
-func getServerCount(botID string) (int, error) {
-	log.Printf("Fetching server count for bot %s", botID)
+// countSources lists the sources available for a bot in order of preference.
+func countSources(botID string) []countSource {
+	var sources []countSource
 
 	// Method 1: Try custom webhook if configured
 	if webhookURL, exists := config.CustomWebhooks[botID]; exists {
-		log.Printf("Trying custom webhook for bot %s: %s", botID, webhookURL)
-		count, err := getServerCountFromCustomWebhook(botID, webhookURL)
-		if err == nil {
-			log.Printf("Successfully got count from custom webhook for bot %s: %d", botID, count)
-			return count, nil
-		}
-		log.Printf("Failed to get count from custom webhook for bot %s: %v", botID, err)
+		sources = append(sources, countSource{"webhook", "custom webhook", func() (int, error) {
+			return getServerCountFromCustomWebhook(botID, webhookURL, fetchTimeout(botID, "webhook"))
+		}})
+	}
+
+	// Method 2: Run the bot's count command (locally or over ssh) if configured
+	if command, exists := config.CountCommands[botID]; exists {
+		sources = append(sources, countSource{"command", "count command", func() (int, error) {
+			return getServerCountFromCommand(botID, command, fetchTimeout(botID, "command"))
+		}})
+	}
+
+	// Method 3: Query the bot's own database if configured
+	if source, exists := config.SQLSources[botID]; exists {
+		sources = append(sources, countSource{"sql", "SQL query", func() (int, error) {
+			return getServerCountFromSQL(source.DSN, source.Query, fetchTimeout(botID, "sql"))
+		}})
+	}
+
+	// Method 4: Read the count the bot caches in Redis if configured
+	if source, exists := config.RedisSources[botID]; exists {
+		sources = append(sources, countSource{"redis", "Redis", func() (int, error) {
+			return getServerCountFromRedis(source, fetchTimeout(botID, "redis"))
+		}})
 	}
 
-	// Method 2: Try direct Discord API if bot token is available
+	// Method 5: Query the bot's GraphQL dashboard API if configured
+	if source, exists := config.GraphQLSources[botID]; exists {
+		sources = append(sources, countSource{"graphql", "GraphQL", func() (int, error) {
+			return getServerCountFromGraphQL(botID, source, fetchTimeout(botID, "graphql"))
+		}})
+	}
+
+	// Method 6: Try direct Discord API if bot token is available
 	if token, exists := config.BotTokens[botID]; exists {
-		log.Printf("Trying Discord API for bot %s", botID)
-		count, err := getServerCountFromDiscordAPI(botID, token)
-		if err == nil {
-			log.Printf("Successfully got count from Discord API for bot %s: %d", botID, count)
-			return count, nil
-		}
-		log.Printf("Failed to get count from Discord API for bot %s: %v", botID, err)
+		sources = append(sources, countSource{"discord", "Discord API", func() (int, error) {
+			return getServerCountFromDiscordAPI(botID, token, fetchTimeout(botID, "discord"))
+		}})
 	} else {
 		log.Printf("No bot token configured for bot %s", botID)
 	}
 
-	// Method 3: Try top.gg API if token is available
+	// Method 7: Try top.gg API if token is available
 	if config.TopGGToken != "" {
-		log.Printf("Trying top.gg API for bot %s", botID)
-		count, err := getServerCountFromTopGG(botID)
+		sources = append(sources, countSource{"topgg", "top.gg", func() (int, error) {
+			return getServerCountFromTopGG(botID, fetchTimeout(botID, "topgg"))
+		}})
+	}
+
+	// Method 8: Try Discord Bot List API (doesn't require authentication)
+	sources = append(sources, countSource{"dbl", "DBL", func() (int, error) {
+		return getServerCountFromDBL(botID, fetchTimeout(botID, "dbl"))
+	}})
+
+	// Method 9: If the bot is in the same server, try to get it directly
+	// This only works if this monitoring bot is in the same servers
+	sources = append(sources, countSource{"direct", "direct method", func() (int, error) {
+		return getServerCountDirectly(botID)
+	}})
+
+	return sources
+}
+
+// CountResult is a successfully fetched server count. Estimated is set
+// when the bot's canonical source failed and another source stood in.
+type CountResult struct {
+	Count     int
+	Source    string
+	Estimated bool
+	Sources   map[string]int // Source name -> Count of every source that answered (the cross-check included)
+}
+
+// getServerCount tries each source in turn and returns the first count
+// along with the name of the source that produced it. With SOURCE_RACING
+// the first two sources are queried in parallel. A bot with a canonical
+// source uses only that source, falling back to the others as an estimate.
+func getServerCount(botID string) (CountResult, error) {
+	log.Printf("Fetching server count for bot %s", botID)
+
+	sources := countSources(botID)
+	var sourceErrors []error
+	estimated := false
+
+	if key, exists := config.CanonicalSources[botID]; exists {
+		canonical, others, found := splitCanonical(sources, key)
+		if !found {
+			log.Printf("Canonical source %q is not available for bot %s", key, botID)
+		} else {
+			count, err := trySource(botID, canonical)
+			if err == nil {
+				sources := map[string]int{canonical.Name: count}
+				crossCheck(botID, canonical.Name, count, others, sources)
+				return CountResult{Count: count, Source: canonical.Name, Sources: sources}, nil
+			}
+			sourceErrors = append(sourceErrors, err)
+			sources = others
+			estimated = true
+		}
+	}
+
+	if config.SourceRacing && len(sources) >= 2 {
+		count, source, errs := raceSources(botID, sources[:2])
+		if errs == nil {
+			return CountResult{Count: count, Source: source, Estimated: estimated, Sources: map[string]int{source: count}}, nil
+		}
+		sourceErrors = append(sourceErrors, errs...)
+		sources = sources[2:]
+	}
+
+	for _, source := range sources {
+		count, err := trySource(botID, source)
 		if err == nil {
-			log.Printf("Successfully got count from top.gg for bot %s: %d", botID, count)
-			return count, nil
+			return CountResult{Count: count, Source: source.Name, Estimated: estimated, Sources: map[string]int{source.Name: count}}, nil
 		}
-		log.Printf("Failed to get count from top.gg for bot %s: %v", botID, err)
+		sourceErrors = append(sourceErrors, err)
 	}
 
-	// Method 4: Try Discord Bot List API (doesn't require authentication)
-	log.Printf("Trying Discord Bot List API for bot %s", botID)
-	count, err := getServerCountFromDBL(botID)
-	if err == nil {
-		log.Printf("Successfully got count from DBL for bot %s: %d", botID, count)
-		return count, nil
+	return CountResult{}, &FetchError{Errors: sourceErrors}
+}
+
+func splitCanonical(sources []countSource, key string) (countSource, []countSource, bool) {
+	for i, source := range sources {
+		if source.Key == key {
+			others := append(append([]countSource{}, sources[:i]...), sources[i+1:]...)
+			return source, others, true
+		}
 	}
-	log.Printf("Failed to get count from DBL for bot %s: %v", botID, err)
+	return countSource{}, sources, false
+}
 
-	// Method 5: If the bot is in the same server, try to get it directly
-	// This only works if this monitoring bot is in the same servers
-	log.Printf("Trying direct method for bot %s", botID)
-	count, err = getServerCountDirectly(botID)
-	if err == nil {
-		log.Printf("Successfully got count from direct method for bot %s: %d", botID, count)
-		return count, nil
+// crossCheck compares the canonical count with the next available source
+// and logs a warning when they disagree by more than CROSS_CHECK_TOLERANCE
+// percent. The canonical count is always the one reported; the other count
+// is added to sources.
+func crossCheck(botID, canonicalName string, canonicalCount int, others []countSource, sources map[string]int) {
+	if !config.CrossCheck || len(others) == 0 {
+		return
+	}
+
+	other := others[0]
+	count, err := trySource(botID, other)
+	if err != nil {
+		return
+	}
+	sources[other.Name] = count
+	if canonicalCount == 0 {
+		return
+	}
+
+	diff := float64(count-canonicalCount) / float64(canonicalCount) * 100
+	if diff > float64(config.CrossCheckTolerance) || -diff > float64(config.CrossCheckTolerance) {
+		log.Printf("Warning: %s reports %d for bot %s but canonical %s reports %d (%.1f%% apart)",
+			other.Name, count, botID, canonicalName, canonicalCount, diff)
+	}
+}
+
+func trySource(botID string, source countSource) (int, error) {
+	log.Printf("Trying %s for bot %s", source.Name, botID)
+	count, err := source.Fetch()
+	if err != nil {
+		log.Printf("Failed to get count from %s for bot %s: %v", source.Name, botID, err)
+		return 0, classifyError(err)
+	}
+	log.Printf("Successfully got count from %s for bot %s: %d", source.Name, botID, count)
+	return count, nil
+}
+
+// raceSources queries the sources in parallel and returns the first
+// successful count. The slower sources keep running but are ignored.
+func raceSources(botID string, sources []countSource) (int, string, []error) {
+	type result struct {
+		count  int
+		source string
+		err    error
 	}
-	log.Printf("Failed to get count from direct method for bot %s: %v", botID, err)
 
-	return 0, fmt.Errorf("could not fetch server count from any source")
+	results := make(chan result, len(sources))
+	for _, source := range sources {
+		go func(source countSource) {
+			count, err := trySource(botID, source)
+			results <- result{count, source.Name, err}
+		}(source)
+	}
+
+	var errs []error
+	for range sources {
+		r := <-results
+		if r.err == nil {
+			log.Printf("%s won the source race for bot %s", r.source, botID)
+			return r.count, r.source, nil
+		}
+		errs = append(errs, r.err)
+	}
+	return 0, "", errs
 }
 
-func getServerCountFromTopGG(botID string) (int, error) {
+func getServerCountFromTopGG(botID string, timeout time.Duration) (int, error) {
 	url := fmt.Sprintf("https://top.gg/api/bots/%s/stats", botID)
 
 	req, err := http.NewRequest("GET", url, nil)
@@ -328,8 +1165,11 @@ func getServerCountFromTopGG(botID string) (int, error) {
 	}
 
 	req.Header.Set("Authorization", config.TopGGToken)
+	identifyRequest(req)
+	req = tagBot(req, botID)
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	waitForHost(url)
+	client := &http.Client{Timeout: timeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		return 0, err
@@ -340,36 +1180,65 @@ func getServerCountFromTopGG(botID string) (int, error) {
 		resp = nil
 	}()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return 0, fmt.Errorf("top.gg API returned status %d: %s", resp.StatusCode, string(body))
+		err := fmt.Errorf("top.gg API returned status %d: %s", resp.StatusCode, string(body))
+		if kind := statusError(resp.StatusCode); kind != nil {
+			err = wrapKind(kind, err)
+		}
+		captureResponse("topgg", botID, url, resp.StatusCode, body, err)
+		return 0, err
 	}
 
 	var stats TopGGStats
-	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+	if err := json.Unmarshal(body, &stats); err != nil {
+		captureResponse("topgg", botID, url, resp.StatusCode, body, err)
 		return 0, err
 	}
 
 	return stats.ServerCount, nil
 }
 
-func getServerCountFromDBL(botID string) (int, error) {
+func getServerCountFromDBL(botID string, timeout time.Duration) (int, error) {
 	// Discord Bot List API (discordbotlist.com)
 	url := fmt.Sprintf("https://discordbotlist.com/api/v1/bots/%s/stats", botID)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	identifyRequest(req)
+	req = tagBot(req, botID)
+
+	waitForHost(url)
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
 	if err != nil {
 		return 0, err
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("DBL API returned status %d", resp.StatusCode)
+		err := fmt.Errorf("DBL API returned status %d", resp.StatusCode)
+		if kind := statusError(resp.StatusCode); kind != nil {
+			err = wrapKind(kind, err)
+		}
+		captureResponse("dbl", botID, url, resp.StatusCode, body, err)
+		return 0, err
 	}
 
 	var result map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.Unmarshal(body, &result); err != nil {
+		captureResponse("dbl", botID, url, resp.StatusCode, body, err)
 		return 0, err
 	}
 
@@ -377,7 +1246,9 @@ func getServerCountFromDBL(botID string) (int, error) {
 		return int(guilds), nil
 	}
 
-	return 0, fmt.Errorf("could not parse guild count from DBL response")
+	err = fmt.Errorf("could not parse guild count from DBL response")
+	captureResponse("dbl", botID, url, resp.StatusCode, body, err)
+	return 0, err
 }
 
 func getServerCountDirectly(botID string) (int, error) {
@@ -404,56 +1275,86 @@ func getServerCountDirectly(botID string) (int, error) {
 	return count, fmt.Errorf("only mutual servers counted (not total)")
 }
 
-func getServerCountFromCustomWebhook(_, webhookURL string) (int, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(webhookURL)
+func getServerCountFromCustomWebhook(botID, webhookURL string, timeout time.Duration) (int, error) {
+	req, err := http.NewRequest("GET", webhookURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	identifyRequest(req)
+	req = tagBot(req, botID)
+
+	waitForHost(webhookURL)
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
 	if err != nil {
 		return 0, err
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		err := fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		if kind := statusError(resp.StatusCode); kind != nil && kind != ErrNotListed {
+			err = wrapKind(kind, err)
+		}
+		captureResponse("webhook", botID, webhookURL, resp.StatusCode, body, err)
+		return 0, err
 	}
 
 	// Try to parse different response formats
 	var result map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.Unmarshal(body, &result); err != nil {
+		captureResponse("webhook", botID, webhookURL, resp.StatusCode, body, err)
 		return 0, err
 	}
 
-	// Common field names for server count
+	if count, found := countFromFields(result); found {
+		return count, nil
+	}
+
+	err = fmt.Errorf("could not find server count in webhook response")
+	captureResponse("webhook", botID, webhookURL, resp.StatusCode, body, err)
+	return 0, err
+}
+
+// countFromFields looks for the server count under the common field names
+// custom endpoints use.
+func countFromFields(result map[string]any) (int, bool) {
 	possibleFields := []string{"server_count", "serverCount", "guilds", "guild_count", "guildCount", "servers"}
 	for _, field := range possibleFields {
 		if val, ok := result[field]; ok {
 			switch v := val.(type) {
 			case float64:
-				return int(v), nil
+				return int(v), true
 			case int:
-				return v, nil
+				return v, true
 			case string:
 				var count int
 				if _, err := fmt.Sscanf(v, "%d", &count); err == nil {
-					return count, nil
+					return count, true
 				}
 			}
 		}
 	}
-
-	return 0, fmt.Errorf("could not find server count in webhook response")
+	return 0, false
 }
 
-func getServerCountFromDiscordAPI(_, token string) (int, error) {
+func getServerCountFromDiscordAPI(botID, token string, timeout time.Duration) (int, error) {
 	// Create a temporary session for the bot
 	botSession, err := discordgo.New("Bot " + token)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create Discord session: %v", err)
 	}
+	botSession.Client = &http.Client{Timeout: timeout}
 
 	// Method 1: Try to get bot info first to check if it's sharded
 	botUser, err := botSession.User("@me")
 	if err != nil {
-		return 0, fmt.Errorf("failed to get bot user info: %v", err)
+		return 0, fmt.Errorf("failed to get bot user info: %w", err)
 	}
 
 	log.Printf("Bot user: %s (ID: %s)", botUser.Username, botUser.ID)
@@ -467,7 +1368,7 @@ func getServerCountFromDiscordAPI(_, token string) (int, error) {
 
 		// If sharding is required, try with proper shard configuration
 		if gateway.Shards > 1 {
-			return getServerCountWithSharding(botSession, gateway.Shards)
+			return getServerCountWithSharding(botID, botSession, gateway.Shards)
 		}
 	}
 
@@ -477,7 +1378,7 @@ func getServerCountFromDiscordAPI(_, token string) (int, error) {
 		// If sharding is required, try with minimal sharding
 		if strings.Contains(err.Error(), "4011") || strings.Contains(err.Error(), "Sharding required") {
 			log.Printf("Sharding required, attempting with shard configuration")
-			return getServerCountWithSharding(botSession, 1)
+			return getServerCountWithSharding(botID, botSession, 1)
 		}
 		log.Printf("Failed to open websocket connection: %v, falling back to REST API", err)
 	} else {
@@ -498,36 +1399,16 @@ func getServerCountFromDiscordAPI(_, token string) (int, error) {
 	// Method 4: Fallback to REST API
 	log.Printf("Falling back to REST API")
 
-	totalGuilds := 0
-	after := ""
-
-	for {
-		// Use the REST API method
-		guilds, err := botSession.UserGuilds(100, "", after)
-		if err != nil {
-			return 0, fmt.Errorf("failed to fetch guilds via REST API: %v", err)
-		}
-
-		if len(guilds) == 0 {
-			break
-		}
-
-		totalGuilds += len(guilds)
-
-		// If we got less than 100 guilds, we're done
-		if len(guilds) < 100 {
-			break
-		}
-
-		// Set the after parameter for the next request
-		after = guilds[len(guilds)-1].ID
+	totalGuilds, err := countGuilds(botID, token)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch guilds via REST API: %w", err)
 	}
 
 	log.Printf("REST API returned %d guilds", totalGuilds)
 	return totalGuilds, nil
 }
 
-func getServerCountWithSharding(botSession *discordgo.Session, recommendedShards int) (int, error) {
+func getServerCountWithSharding(botID string, botSession *discordgo.Session, recommendedShards int) (int, error) {
 	log.Printf("Attempting sharded connection with %d shards", recommendedShards)
 
 	// Set shard information
@@ -536,7 +1417,7 @@ func getServerCountWithSharding(botSession *discordgo.Session, recommendedShards
 
 	err := botSession.Open()
 	if err != nil {
-		return 0, fmt.Errorf("failed to open sharded connection: %v", err)
+		return 0, fmt.Errorf("failed to open sharded connection: %w", err)
 	}
 	defer botSession.Close()
 
@@ -553,47 +1434,13 @@ func getServerCountWithSharding(botSession *discordgo.Session, recommendedShards
 		log.Printf("Got %d guilds from shard 0, but this is not the total count for sharded bot", guildCount)
 	}
 
-	// Use REST API for accurate total count with larger limit
-	totalGuilds := 0
-	after := ""
-	maxIterations := 50 // Safety limit to prevent infinite loops
-	iteration := 0
-
-	for iteration < maxIterations {
-		// Try to get more guilds per request (max is 200)
-		guilds, err := botSession.UserGuilds(200, "", after)
-		if err != nil {
-			// If 200 fails, try with 100
-			guilds, err = botSession.UserGuilds(100, "", after)
-			if err != nil {
-				return 0, fmt.Errorf("failed to fetch guilds via REST API in sharded mode: %v", err)
-			}
-		}
-
-		if len(guilds) == 0 {
-			break
-		}
-
-		totalGuilds += len(guilds)
-		log.Printf("REST API iteration %d: got %d guilds, total: %d", iteration+1, len(guilds), totalGuilds)
-
-		// If we got less than the requested amount, we're done
-		if len(guilds) < 200 && len(guilds) < 100 {
-			break
-		}
-
-		after = guilds[len(guilds)-1].ID
-		iteration++
-
-		// Small delay to avoid rate limiting
-		time.Sleep(100 * time.Millisecond)
-	}
-
-	if iteration >= maxIterations {
-		log.Printf("Warning: Reached maximum iterations (%d), there might be more guilds", maxIterations)
+	// Use REST API for accurate total count
+	totalGuilds, err := countGuilds(botID, strings.TrimPrefix(botSession.Token, "Bot "))
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch guilds via REST API in sharded mode: %w", err)
 	}
 
-	log.Printf("REST API in sharded mode returned %d guilds after %d iterations", totalGuilds, iteration)
+	log.Printf("REST API in sharded mode returned %d guilds", totalGuilds)
 
 	// If we still don't have the expected count, try a different approach
 	if totalGuilds < 2500 { // If it seems incomplete for a large bot
@@ -604,32 +1451,176 @@ func getServerCountWithSharding(botSession *discordgo.Session, recommendedShards
 	return totalGuilds, nil
 }
 
-func sendServerCountNotification(allStats []BotStats) {
+func sendServerCountNotification(allStats []BotStats, network *NetworkStats, destination Destination) []*discordgo.Message {
+	message := buildReportMessage(allStats, network, destination)
+
+	// messageの内容をDiscordに送信
+	var messages []*discordgo.Message
+	var err error
+	if config.ReportImage && destination.Format != reportCompact && destination.Format != reportSummary {
+		messages, err = sendReportCard(allStats, network, destination, message)
+	} else {
+		messages, err = sendMessages(destination.ChannelID, splitMessage(message))
+	}
+	metrics.recordDelivery("report", err)
+	if err != nil {
+		log.Printf("Error sending message to channel %s: %v", destination.ChannelID, err)
+		return nil
+	}
+
+	log.Printf("Successfully sent server count notification for %d bots to channel %s", len(allStats), destination.ChannelID)
+	publishMessages(destination, messages)
+	fireHook(reportHookEvent(message, allStats))
+	return messages
+}
+
+// buildReportMessage renders the report in the destination's language,
+// timezone and format. Public destinations omit errors, notes and any metric
+// that is not in PUBLIC_REPORT_METRICS.
+func buildReportMessage(allStats []BotStats, network *NetworkStats, destination Destination) string {
+	switch destination.Format {
+	case reportCompact:
+		return buildCompactMessage(allStats, destination)
+	case reportSummary:
+		return buildSummaryMessage(allStats, destination)
+	}
+
 	var message string
 
-	message = "⏰" + time.Now().Format("2006-01-02 15:04:05")
+	message = "⏰" + time.Now().In(destination.Location).Format("2006-01-02 15:04:05")
+
+	var totals string
+	if network != nil && network.BotCount > 1 && (!destination.Public || config.PublicMetrics["network"]) {
+		totals = "\n" + translate(destination.Language, "network", network.UniqueGuilds, network.TotalGuilds)
+	}
+	// Configured aggregates replace the plain sum, which double-counts
+	// servers shared by several bots
+	if aggregates := aggregateValues(allStats, destination); len(aggregates) > 0 {
+		for _, aggregate := range aggregates {
+			totals += "\n" + aggregate.String()
+		}
+	} else if config.ReportTotal {
+		total := 0
+		for _, stats := range visibleStats(allStats, destination) {
+			if stats.Error == nil && !stats.Pending && !config.PrivateBots[stats.BotID] {
+				total += stats.ServerCount
+			}
+		}
+		totals += "\n" + translate(destination.Language, "total", total)
+	}
+	if votes := combinedVotes(allStats, destination); len(votes) > 0 && (!destination.Public || config.PublicMetrics["votes"]) {
+		totals += "\n" + translate(destination.Language, "votes_total", totalVotes(votes), formatVotes(destination.Language, votes))
+	}
+	if computed := formatComputed(latestComputedTotals(), destination.Public); computed != "" {
+		totals += "\n📐 " + strings.TrimPrefix(computed, " | ")
+	}
+
+	if config.ReportTotalsPosition == "top" {
+		message += totals
+	}
 
-	for _, stats := range allStats {
+	for _, stats := range visibleStats(allStats, destination) {
 		var fieldValue string
-		if stats.Error != nil {
-			fieldValue = fmt.Sprintf("エラー: %v", stats.Error)
+		if stats.Pending {
+			fieldValue = translate(destination.Language, "pending")
+		} else if stats.Error != nil {
+			fieldValue = describeError(destination.Language, stats.Error)
 		} else {
 			fieldValue = fmt.Sprintf("**%d**", stats.ServerCount)
+			if stats.Estimated {
+				fieldValue += " " + translate(destination.Language, "estimated", stats.Source)
+			}
+			if !destination.Public || config.PublicMetrics["changes"] {
+				fieldValue += formatPeriodChanges(destination.Language, stats)
+			}
+			if stats.HasUserInstalls && (!destination.Public || config.PublicMetrics["user_installs"]) {
+				fieldValue += " " + translate(destination.Language, "user_installs", stats.UserInstalls)
+			}
+			if stats.HasPatrons && (!destination.Public || config.PublicMetrics["patrons"]) {
+				fieldValue += " " + translate(destination.Language, "patrons", stats.Patrons)
+			}
+			if len(stats.Votes) > 0 && (!destination.Public || config.PublicMetrics["votes"]) {
+				fieldValue += " " + translate(destination.Language, "votes", totalVotes(stats.Votes), formatVotes(destination.Language, stats.Votes))
+			}
+			if stats.Repo != nil && (!destination.Public || config.PublicMetrics["github"]) {
+				fieldValue += " " + translate(destination.Language, "github", stats.Repo.Stars, stats.Repo.OpenIssues)
+			}
 		}
 
+		fields := stats.Fields
+		if destination.Public {
+			fields = make(map[string]string)
+			for name, value := range stats.Fields {
+				if config.PublicMetrics[name] {
+					fields[name] = value
+				}
+			}
+		}
+		fieldValue += formatFields(fields) + formatComputed(stats.Computed, destination.Public)
+
 		botDisplay := stats.BotName
 		if botDisplay == "Unknown" || botDisplay == "" {
 			botDisplay = stats.BotID
 		}
 
-		message += "\n" + botDisplay + " : " + fieldValue
+		if config.ReportFieldLayout == "block" {
+			message += "\n**" + botDisplay + "**\n" + fieldValue
+		} else {
+			message += "\n" + botDisplay + " : " + fieldValue
+		}
+
+		if note, exists := config.BotNotes[stats.BotID]; exists && !destination.Public {
+			message += "\n　📝 " + note
+		}
 	}
 
-	// messageの内容をDiscordに送信
-	_, err := session.ChannelMessageSend(config.ChannelID, message)
-	if err != nil {
-		log.Printf("Error sending message: %v", err)
-	} else {
-		log.Printf("Successfully sent server count notification for %d bots", len(allStats))
+	if config.ReportTotalsPosition != "top" {
+		message += totals
 	}
+
+	return message
+}
+
+// visibleStats returns the bots shown to a destination in report order.
+// Public reports leave out failed bots, and every bot when server counts
+// aren't a public metric.
+func visibleStats(allStats []BotStats, destination Destination) []BotStats {
+	var visible []BotStats
+	for _, stats := range sortStats(allStats) {
+		if destination.Public && (stats.Error != nil || !config.PublicMetrics["servers"] || config.PrivateBots[stats.BotID]) {
+			continue
+		}
+		visible = append(visible, stats)
+	}
+	return visible
+}
+
+// sortStats orders the bots according to REPORT_SORT. Bots that failed to
+// fetch, or have no previous count when sorting by growth, go last.
+func sortStats(allStats []BotStats) []BotStats {
+	sorted := make([]BotStats, len(allStats))
+	copy(sorted, allStats)
+
+	switch config.ReportSort {
+	case "name":
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return strings.ToLower(sorted[i].BotName) < strings.ToLower(sorted[j].BotName)
+		})
+	case "count":
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if (sorted[i].Error == nil) != (sorted[j].Error == nil) {
+				return sorted[i].Error == nil
+			}
+			return sorted[i].ServerCount > sorted[j].ServerCount
+		})
+	case "growth":
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if sorted[i].HasChange != sorted[j].HasChange {
+				return sorted[i].HasChange
+			}
+			return sorted[i].Change > sorted[j].Change
+		})
+	}
+
+	return sorted
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// selfMetrics tracks the watcher's own operation since startup: how long
+// runs take, how notifier deliveries fare and how late scheduled runs
+// start.
+type selfMetrics struct {
+	mu sync.Mutex
+
+	Runs          int
+	LastRunAt     time.Time
+	LastRunTime   time.Duration
+	TotalRunTime  time.Duration
+	MaxRunTime    time.Duration
+	Deliveries    map[string]*deliveryCount // Notifier -> Outcomes
+	LastDrift     time.Duration
+	MaxDrift      time.Duration
+	ScheduledRuns int
+	LateRuns      int // Scheduled runs that started later than SCHEDULE_TOLERANCE
+	MissedRuns    int // Scheduled runs that hadn't started SCHEDULE_TOLERANCE after their time
+	ClockJumps    int // Times the wall clock jumped by more than SCHEDULE_TOLERANCE
+}
+
+type deliveryCount struct {
+	Success int
+	Failure int
+}
+
+var metrics = &selfMetrics{Deliveries: make(map[string]*deliveryCount)}
+
+func (m *selfMetrics) recordRun(start time.Time) {
+	duration := time.Since(start)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Runs++
+	m.LastRunAt = start
+	m.LastRunTime = duration
+	m.TotalRunTime += duration
+	if duration > m.MaxRunTime {
+		m.MaxRunTime = duration
+	}
+}
+
+func (m *selfMetrics) recordDelivery(notifier string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count, exists := m.Deliveries[notifier]
+	if !exists {
+		count = &deliveryCount{}
+		m.Deliveries[notifier] = count
+	}
+	if err != nil {
+		count.Failure++
+	} else {
+		count.Success++
+	}
+}
+
+// recordDrift records how long after its scheduled time a cron run started.
+func (m *selfMetrics) recordDrift(drift time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ScheduledRuns++
+	m.LastDrift = drift
+	if drift > m.MaxDrift {
+		m.MaxDrift = drift
+	}
+}
+
+func (m *selfMetrics) recordLateRun() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.LateRuns++
+}
+
+func (m *selfMetrics) recordMissedRun() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.MissedRuns++
+}
+
+func (m *selfMetrics) recordClockJump() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ClockJumps++
+}
+
+func (m *selfMetrics) summary() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("📊 **statbot メトリクス**\n")
+
+	if m.Runs == 0 {
+		b.WriteString("実行: まだ実行されていません\n")
+	} else {
+		average := m.TotalRunTime / time.Duration(m.Runs)
+		fmt.Fprintf(&b, "実行: %d回 (最終: %s, 所要時間 %v / 平均 %v / 最大 %v)\n",
+			m.Runs, m.LastRunAt.Format("2006-01-02 15:04:05"),
+			m.LastRunTime.Round(time.Millisecond), average.Round(time.Millisecond), m.MaxRunTime.Round(time.Millisecond))
+	}
+
+	if m.ScheduledRuns > 0 {
+		fmt.Fprintf(&b, "スケジュールのずれ: 最終 %v / 最大 %v (%d回)\n",
+			m.LastDrift.Round(time.Millisecond), m.MaxDrift.Round(time.Millisecond), m.ScheduledRuns)
+	}
+	if m.LateRuns > 0 || m.MissedRuns > 0 || m.ClockJumps > 0 {
+		fmt.Fprintf(&b, "スケジュールの異常: 遅延 %d回 / 未開始 %d回 / 時刻のずれ %d回\n", m.LateRuns, m.MissedRuns, m.ClockJumps)
+	}
+
+	notifiers := make([]string, 0, len(m.Deliveries))
+	for notifier := range m.Deliveries {
+		notifiers = append(notifiers, notifier)
+	}
+	sort.Strings(notifiers)
+
+	for _, notifier := range notifiers {
+		count := m.Deliveries[notifier]
+		total := count.Success + count.Failure
+		fmt.Fprintf(&b, "送信 %s: 成功 %d / 失敗 %d (成功率 %.1f%%)\n",
+			notifier, count.Success, count.Failure, float64(count.Success)/float64(total)*100)
+	}
+
+	return b.String()
+}
+
+// handleMetrics serves the same metrics as /watch metrics at /metrics in
+// the Prometheus text format, for scraping alongside other services.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(metrics.prometheus()))
+}
+
+func (m *selfMetrics) prometheus() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	metric := func(name, kind, help string, value float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+	}
+
+	metric("statbot_runs_total", "counter", "Report runs since startup.", float64(m.Runs))
+	metric("statbot_run_duration_seconds_total", "counter", "Time spent in report runs.", m.TotalRunTime.Seconds())
+	metric("statbot_last_run_duration_seconds", "gauge", "Duration of the latest run.", m.LastRunTime.Seconds())
+	metric("statbot_max_run_duration_seconds", "gauge", "Longest run since startup.", m.MaxRunTime.Seconds())
+	if !m.LastRunAt.IsZero() {
+		metric("statbot_last_run_timestamp_seconds", "gauge", "When the latest run started.", float64(m.LastRunAt.Unix()))
+	}
+	metric("statbot_scheduled_runs_total", "counter", "Scheduled runs since startup.", float64(m.ScheduledRuns))
+	metric("statbot_schedule_drift_seconds", "gauge", "How late the latest scheduled run started.", m.LastDrift.Seconds())
+	metric("statbot_max_schedule_drift_seconds", "gauge", "Latest start of a scheduled run since startup.", m.MaxDrift.Seconds())
+	metric("statbot_late_runs_total", "counter", "Scheduled runs that started later than SCHEDULE_TOLERANCE.", float64(m.LateRuns))
+	metric("statbot_missed_runs_total", "counter", "Scheduled runs that hadn't started SCHEDULE_TOLERANCE after their time.", float64(m.MissedRuns))
+	metric("statbot_clock_jumps_total", "counter", "Wall clock jumps larger than SCHEDULE_TOLERANCE.", float64(m.ClockJumps))
+
+	notifiers := make([]string, 0, len(m.Deliveries))
+	for notifier := range m.Deliveries {
+		notifiers = append(notifiers, notifier)
+	}
+	sort.Strings(notifiers)
+
+	b.WriteString("# HELP statbot_deliveries_total Deliveries by notifier and outcome.\n# TYPE statbot_deliveries_total counter\n")
+	for _, notifier := range notifiers {
+		count := m.Deliveries[notifier]
+		fmt.Fprintf(&b, "statbot_deliveries_total{notifier=%q,outcome=\"success\"} %d\n", notifier, count.Success)
+		fmt.Fprintf(&b, "statbot_deliveries_total{notifier=%q,outcome=\"failure\"} %d\n", notifier, count.Failure)
+	}
+
+	return b.String()
+}
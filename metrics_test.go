@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleMetrics(t *testing.T) {
+	previous := metrics
+	t.Cleanup(func() { metrics = previous })
+	metrics = &selfMetrics{Deliveries: make(map[string]*deliveryCount)}
+	metrics.recordRun(time.Now().Add(-2 * time.Second))
+	metrics.recordDelivery("alert channel", nil)
+	metrics.recordDelivery("alert channel", errors.New("forbidden"))
+	metrics.recordMissedRun()
+
+	recorder := httptest.NewRecorder()
+	handleMetrics(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := recorder.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", got)
+	}
+	body := recorder.Body.String()
+	for _, want := range []string{
+		"# TYPE statbot_runs_total counter\nstatbot_runs_total 1\n",
+		"statbot_missed_runs_total 1\n",
+		`statbot_deliveries_total{notifier="alert channel",outcome="success"} 1` + "\n",
+		`statbot_deliveries_total{notifier="alert channel",outcome="failure"} 1` + "\n",
+		"statbot_last_run_timestamp_seconds ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics have no %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Subsystems that can be switched off so minimal deployments only run what
+// they need.
+const (
+	ModuleSampling   = "sampling"   // Scheduled and startup runs
+	ModulePublishing = "publishing" // Reports, previews, the feed, listing diffs and announcements
+	ModuleAlerting   = "alerting"   // Alert delivery to every notifier
+	ModuleCommands   = "commands"   // Slash command registration
+	ModuleHTTP       = "http"       // Badges, public API and proxy (also needs HTTP_ADDR)
+)
+
+var modules = []string{ModuleSampling, ModulePublishing, ModuleAlerting, ModuleCommands, ModuleHTTP}
+
+// loadModules reads which modules run. MODULES lists the only modules to
+// run; DISABLE_MODULES switches off modules from that set (every module by
+// default).
+func loadModules() map[string]bool {
+	enabled := make(map[string]bool)
+	if only := getEnvList("MODULES"); len(only) > 0 {
+		for _, module := range only {
+			enabled[module] = true
+		}
+	} else {
+		for _, module := range modules {
+			enabled[module] = true
+		}
+	}
+
+	var disabled []string
+	for _, module := range getEnvList("DISABLE_MODULES") {
+		delete(enabled, module)
+	}
+	for _, module := range modules {
+		if !enabled[module] {
+			disabled = append(disabled, module)
+		}
+	}
+	if len(disabled) > 0 {
+		log.Printf("Disabled modules: %s", strings.Join(disabled, ", "))
+	}
+	return enabled
+}
+
+func moduleEnabled(module string) bool {
+	return config.Modules[module]
+}
+
+// validateModules checks MODULES and DISABLE_MODULES for unknown names.
+func validateModules(problems *configProblems) {
+	for _, name := range []string{"MODULES", "DISABLE_MODULES"} {
+		for i, module := range getEnvList(name) {
+			if !contains(modules, module) {
+				problems.add(fmt.Sprintf("%s[%d]", name, i), "unknown module %q (expected %s)", module, strings.Join(modules, ", "))
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// NetworkStats describes the combined reach of all owned bots (those with a
+// configured bot token) that aren't private. Guilds served by several
+// owned bots count once in UniqueGuilds but once per bot in TotalGuilds.
+type NetworkStats struct {
+	BotCount     int
+	UniqueGuilds int
+	TotalGuilds  int
+}
+
+func getNetworkStats(guilds guildLists) (*NetworkStats, error) {
+	if len(config.BotTokens) == 0 {
+		return nil, fmt.Errorf("no bot tokens configured")
+	}
+	return countUniqueGuilds(config.TargetBotIDs, guilds)
+}
+
+// guildLists holds the guild IDs of the owned bots listed in one run, or why
+// listing them failed. Guild IDs are kept as integers, which is much smaller
+// than strings for owned bots in tens of thousands of guilds.
+type guildLists struct {
+	IDs    map[string][]uint64
+	Errors map[string]error
+}
+
+// listOwnedGuilds lists the guilds of every owned bot that isn't private
+// once, for the network stats and the unique aggregates to share, since
+// listing guilds is expensive.
+func listOwnedGuilds() guildLists {
+	var botIDs []string
+	if config.NetworkStats {
+		botIDs = append(botIDs, config.TargetBotIDs...)
+	}
+	for _, aggregate := range config.Aggregates {
+		if aggregate.Function == aggregateUnique {
+			botIDs = append(botIDs, aggregate.BotIDs...)
+		}
+	}
+
+	guilds := guildLists{IDs: make(map[string][]uint64), Errors: make(map[string]error)}
+	for _, botID := range botIDs {
+		if _, listed := guilds.IDs[botID]; listed || guilds.Errors[botID] != nil {
+			continue
+		}
+		token, exists := config.BotTokens[botID]
+		if !exists || config.PrivateBots[botID] {
+			continue
+		}
+
+		var ids []uint64
+		err := forEachGuildPage(token, "", func(page []uint64, _ string) {
+			ids = append(ids, page...)
+		})
+		if err != nil {
+			guilds.Errors[botID] = err
+			continue
+		}
+		guilds.IDs[botID] = ids
+	}
+	return guilds
+}
+
+// countUniqueGuilds combines the guild lists of the given bots that are
+// owned and not private.
+func countUniqueGuilds(botIDs []string, guilds guildLists) (*NetworkStats, error) {
+	network := &NetworkStats{}
+	seen := make(map[uint64]struct{})
+
+	for _, botID := range botIDs {
+		if _, exists := config.BotTokens[botID]; !exists {
+			log.Printf("Bot %s has no token, excluding it from network stats", botID)
+			continue
+		}
+		if config.PrivateBots[botID] {
+			continue
+		}
+		if err := guilds.Errors[botID]; err != nil {
+			return nil, fmt.Errorf("failed to fetch guilds for bot %s: %v", botID, err)
+		}
+
+		for _, id := range guilds.IDs[botID] {
+			seen[id] = struct{}{}
+		}
+		network.TotalGuilds += len(guilds.IDs[botID])
+		network.BotCount++
+	}
+
+	network.UniqueGuilds = len(seen)
+	return network, nil
+}
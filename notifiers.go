@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notifier delivers an alert to one destination.
+type Notifier interface {
+	// Name identifies the notifier kind in logs, metrics and retry settings.
+	Name() string
+	Notify(alert Alert, text string) error
+}
+
+// RetryPolicy controls how often a notifier is retried after a failure.
+type RetryPolicy struct {
+	Attempts int
+	Backoff  time.Duration // Doubled after every failed attempt
+}
+
+// notifierEntry is a notifier with the severities it receives.
+type notifierEntry struct {
+	Notifier   Notifier
+	Severities map[Severity]bool
+	Retry      RetryPolicy
+}
+
+type channelNotifier struct {
+	ChannelID string
+	RoleIDs   []string
+}
+
+func (n channelNotifier) Name() string { return "alert channel" }
+
+func (n channelNotifier) Notify(alert Alert, text string) error {
+	content := text
+	for _, roleID := range n.RoleIDs {
+		content = fmt.Sprintf("<@&%s> ", roleID) + content
+	}
+	var err error
+	if alert.AckID != "" {
+		_, err = sendWithComponents(n.ChannelID, content, ackButton(alert.AckID, "確認", false))
+	} else {
+		_, err = sendChannelMessage(n.ChannelID, content)
+	}
+	if err != nil {
+		return fmt.Errorf("channel %s: %v", n.ChannelID, err)
+	}
+	return nil
+}
+
+type pagerDutyNotifier struct{}
+
+func (pagerDutyNotifier) Name() string { return "pagerduty" }
+
+func (pagerDutyNotifier) Notify(alert Alert, text string) error {
+	if err := sendPagerDutyAlert(alert, text); err != nil {
+		return fmt.Errorf("PagerDuty: %v", err)
+	}
+	return nil
+}
+
+type emailNotifier struct{}
+
+func (emailNotifier) Name() string { return "email" }
+
+func (emailNotifier) Notify(alert Alert, text string) error {
+	if err := sendEmailAlert(alert, text); err != nil {
+		return fmt.Errorf("email: %v", err)
+	}
+	return nil
+}
+
+// buildNotifiers turns the per-severity alert routes into notifiers that
+// each carry their own severity filter and retry policy.
+func buildNotifiers(routes map[Severity]AlertRoute) []notifierEntry {
+	var entries []notifierEntry
+	add := func(severity Severity, notifier Notifier) {
+		entries = append(entries, notifierEntry{
+			Notifier:   notifier,
+			Severities: map[Severity]bool{severity: true},
+			Retry:      loadRetryPolicy(notifier.Name()),
+		})
+	}
+
+	for _, severity := range severities {
+		route, exists := routes[severity]
+		if !exists {
+			continue
+		}
+		for _, channelID := range route.ChannelIDs {
+			add(severity, channelNotifier{ChannelID: channelID, RoleIDs: route.RoleIDs})
+		}
+		if route.PagerDuty {
+			add(severity, pagerDutyNotifier{})
+		}
+		if route.Email {
+			add(severity, emailNotifier{})
+		}
+		if route.Ntfy {
+			add(severity, ntfyNotifier{})
+		}
+		if route.Pushover {
+			add(severity, pushoverNotifier{})
+		}
+		if route.Teams {
+			add(severity, teamsNotifier{})
+		}
+	}
+
+	return entries
+}
+
+// loadRetryPolicy reads NOTIFIER_RETRY_<NAME> in the format ATTEMPTS:SECONDS,
+// e.g. NOTIFIER_RETRY_PAGERDUTY=3:5. Notifiers are tried once by default.
+// Invalid values are reported by validateEnv before this runs.
+func loadRetryPolicy(name string) RetryPolicy {
+	value := os.Getenv(retryPrefix + strings.ToUpper(strings.ReplaceAll(name, " ", "_")))
+	policy, err := parseRetryPolicy(value)
+	if err != nil {
+		return RetryPolicy{Attempts: 1}
+	}
+	return policy
+}
+
+const retryPrefix = "NOTIFIER_RETRY_"
+
+func parseRetryPolicy(value string) (RetryPolicy, error) {
+	policy := RetryPolicy{Attempts: 1}
+	if value == "" {
+		return policy, nil
+	}
+
+	attempts, seconds, _ := strings.Cut(value, ":")
+	parsedAttempts, err := strconv.Atoi(strings.TrimSpace(attempts))
+	if err != nil || parsedAttempts < 1 {
+		return policy, fmt.Errorf("attempts %q should be a number of at least 1 (format ATTEMPTS:SECONDS)", attempts)
+	}
+	policy.Attempts = parsedAttempts
+
+	if seconds != "" {
+		parsedSeconds, err := strconv.Atoi(strings.TrimSpace(seconds))
+		if err != nil || parsedSeconds < 0 {
+			return policy, fmt.Errorf("backoff %q should be a number of seconds (format ATTEMPTS:SECONDS)", seconds)
+		}
+		policy.Backoff = time.Duration(parsedSeconds) * time.Second
+	}
+	return policy, nil
+}
+
+// validateRetryPolicies checks every NOTIFIER_RETRY_<NAME>.
+func validateRetryPolicies(problems *configProblems) {
+	var names []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, retryPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := parseRetryPolicy(strings.TrimSpace(os.Getenv(name))); err != nil {
+			problems.add(name, "%v", err)
+		}
+	}
+}
+
+var (
+	alertQueue     = make(chan func(), 100)
+	alertQueueOnce sync.Once
+)
+
+// queueAlert hands the alert to a background worker that delivers alerts in
+// order, so notifier retries never hold up the run that raised them.
+func queueAlert(alert Alert) {
+	queueAlertWork(func() {
+		for _, err := range deliverAlert(alert) {
+			log.Printf("Error delivering alert: %v", err)
+		}
+	})
+}
+
+// queueAlertWork runs work on the alert delivery worker, after everything
+// queued before it.
+func queueAlertWork(work func()) {
+	alertQueueOnce.Do(func() {
+		go func() {
+			for work := range alertQueue {
+				work()
+			}
+		}()
+	})
+	alertQueue <- work
+}
+
+// deliverAlert fans the alert out to every notifier that accepts its
+// severity (or the alert's own notifiers) and returns the delivery
+// failures. Notifiers run concurrently so one notifier's retries never
+// delay another.
+func deliverAlert(alert Alert) []error {
+	text := formatAlert(alert)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	entries := config.Notifiers
+	if alert.Notifiers != nil {
+		entries = alert.Notifiers
+	}
+
+	for _, entry := range entries {
+		if !entry.Severities[alert.Severity] {
+			continue
+		}
+
+		wg.Add(1)
+		go func(entry notifierEntry) {
+			defer wg.Done()
+			if err := notifyWithRetry(entry, alert, text); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(entry)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+func notifyWithRetry(entry notifierEntry, alert Alert, text string) error {
+	if dryRun(entry.Notifier.Name()) {
+		logDryRun(entry.Notifier.Name(), alert.Severity.String()+" alert", text)
+		return nil
+	}
+
+	backoff := entry.Retry.Backoff
+
+	var err error
+	for attempt := 1; attempt <= entry.Retry.Attempts; attempt++ {
+		err = entry.Notifier.Notify(alert, text)
+		metrics.recordDelivery(entry.Notifier.Name(), err)
+		if err == nil {
+			return nil
+		}
+
+		if attempt < entry.Retry.Attempts {
+			log.Printf("Notifier %s failed (attempt %d/%d), retrying in %v: %v", entry.Notifier.Name(), attempt, entry.Retry.Attempts, backoff, err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return err
+}
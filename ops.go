@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	opsConfirmPrefix = "ops-confirm:"
+	opsCancelPrefix  = "ops-cancel:"
+)
+
+// opsConfirmWindow is how long a /bot confirmation stays usable.
+const opsConfirmWindow = 2 * time.Minute
+
+// botCommand is /bot: info for every target bot, and restart and redeploy
+// when some bot has an action for them.
+func botCommand() *discordgo.ApplicationCommand {
+	command := &discordgo.ApplicationCommand{
+		Name:                     "bot",
+		Description:              "botの詳細を表示し、自分でホストしているbotを操作します",
+		DefaultMemberPermissions: &manageGuildPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			botSubcommand("info", "botについて分かっていることをまとめて表示します"),
+		},
+	}
+	if opsEnabled() {
+		command.Options = append(command.Options,
+			botSubcommand("restart", "botの自動復旧アクション（REMEDIATE_<BOT_ID>）を実行します"),
+			botSubcommand("redeploy", "botの再デプロイアクション（REDEPLOY_<BOT_ID>）を実行します"),
+		)
+	}
+	return command
+}
+
+func botSubcommand(name, description string) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        name,
+		Description: description,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         "name",
+				Description:  "botのIDまたは名前",
+				Required:     true,
+				Autocomplete: true,
+			},
+		},
+	}
+}
+
+// pendingOp is a /bot action waiting for its requester to confirm it.
+type pendingOp struct {
+	Operation string // "restart" or "redeploy"
+	BotID     string
+	UserID    string
+	Expires   time.Time
+}
+
+var (
+	opsMu      sync.Mutex
+	pendingOps = make(map[string]*pendingOp) // Confirmation ID -> Pending action
+	nextOpID   int
+)
+
+// opsEnabled reports whether any bot has an action /bot could run.
+func opsEnabled() bool {
+	return len(config.RemediationActions) > 0 || len(config.RedeployActions) > 0
+}
+
+func opsAction(operation, botID string) (string, bool) {
+	actions := config.RemediationActions
+	if operation == "redeploy" {
+		actions = config.RedeployActions
+	}
+	action, exists := actions[botID]
+	return action, exists
+}
+
+// canRunOps reports whether the member may use /bot: a member of one of
+// OPS_ROLE_IDS, or anyone who can manage the server when that is unset.
+func canRunOps(member *discordgo.Member) bool {
+	if member == nil {
+		return false
+	}
+	if len(config.OpsRoleIDs) == 0 {
+		return member.Permissions&discordgo.PermissionManageServer != 0
+	}
+	for _, roleID := range member.Roles {
+		if contains(config.OpsRoleIDs, roleID) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleBotCommand shows /bot info, or asks the requester to confirm an
+// action with a button before anything runs.
+func handleBotCommand(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	if subcommand.Name == "info" {
+		handleBotInfo(s, i, subcommand)
+		return
+	}
+	if !canRunOps(i.Member) {
+		respondEphemeral(s, i, "このコマンドを使用する権限がありません")
+		return
+	}
+
+	var botRef string
+	for _, option := range subcommand.Options {
+		if option.Name == "name" {
+			botRef = option.StringValue()
+		}
+	}
+	botID, ok := resolveBot(botRef)
+	if !ok {
+		respondEphemeral(s, i, fmt.Sprintf("bot %q は監視対象ではありません", botRef))
+		return
+	}
+	action, exists := opsAction(subcommand.Name, botID)
+	if !exists {
+		respondEphemeral(s, i, fmt.Sprintf("%s には %s アクションが設定されていません", botLabel(botID), subcommand.Name))
+		return
+	}
+
+	opsMu.Lock()
+	for pendingID, op := range pendingOps {
+		if time.Now().After(op.Expires) {
+			delete(pendingOps, pendingID)
+		}
+	}
+	nextOpID++
+	id := strconv.Itoa(nextOpID)
+	pendingOps[id] = &pendingOp{
+		Operation: subcommand.Name,
+		BotID:     botID,
+		UserID:    i.Member.User.ID,
+		Expires:   time.Now().Add(opsConfirmWindow),
+	}
+	opsMu.Unlock()
+
+	content := fmt.Sprintf("%s に対して %s（%s）を実行しますか？", botLabel(botID), subcommand.Name, describeRemediation(action))
+	if config.RemediationDryRun {
+		content += "\nREMEDIATION_DRY_RUNが有効なため、実際には実行されません"
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Flags:      discordgo.MessageFlagsEphemeral,
+			Components: opsButtons(id, ""),
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to /bot %s: %v", subcommand.Name, err)
+	}
+}
+
+func opsButtons(id, outcome string) []discordgo.MessageComponent {
+	if outcome != "" {
+		return []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: outcome, Style: discordgo.SecondaryButton, CustomID: opsConfirmPrefix + id, Disabled: true},
+			}},
+		}
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "実行", Style: discordgo.DangerButton, CustomID: opsConfirmPrefix + id},
+			discordgo.Button{Label: "キャンセル", Style: discordgo.SecondaryButton, CustomID: opsCancelPrefix + id},
+		}},
+	}
+}
+
+// isOpsButton returns the confirmation ID of a /bot button and whether it
+// confirms the action.
+func isOpsButton(customID string) (id string, confirm bool, ok bool) {
+	if id, ok := strings.CutPrefix(customID, opsConfirmPrefix); ok {
+		return id, true, true
+	}
+	if id, ok := strings.CutPrefix(customID, opsCancelPrefix); ok {
+		return id, false, true
+	}
+	return "", false, false
+}
+
+func handleOpsButton(s *discordgo.Session, i *discordgo.InteractionCreate, id string, confirm bool) {
+	user := interactionUser(i)
+
+	opsMu.Lock()
+	op, exists := pendingOps[id]
+	if exists && user != nil && op.UserID == user.ID {
+		delete(pendingOps, id)
+	}
+	opsMu.Unlock()
+
+	if !exists || time.Now().After(op.Expires) {
+		respondEphemeral(s, i, "この操作は既に処理済みか期限切れです")
+		return
+	}
+	if user == nil || op.UserID != user.ID {
+		respondEphemeral(s, i, "この操作を確認できるのはコマンドを実行した本人だけです")
+		return
+	}
+
+	if !confirm {
+		updateOpsMessage(s, i, id, "キャンセルしました")
+		return
+	}
+
+	// The action can take longer than the interaction deadline
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		log.Printf("Error deferring /bot confirmation: %v", err)
+	}
+
+	// Restarts share the limits of the automatic remediation
+	var refused string
+	if op.Operation == "restart" {
+		refused = claimManualRemediation(op.BotID)
+	}
+
+	action, _ := opsAction(op.Operation, op.BotID)
+	content := i.Message.Content
+	outcome := "✅ 実行しました"
+	switch {
+	case refused != "":
+		outcome = "❌ 実行しませんでした"
+		content += "\n" + refused
+	case config.RemediationDryRun:
+		recordAudit(user, op.Operation+" bot", fmt.Sprintf("%s: %s", op.BotID, describeRemediation(action)))
+		outcome = "REMEDIATION_DRY_RUNのため実行しませんでした"
+	default:
+		recordAudit(user, op.Operation+" bot", fmt.Sprintf("%s: %s", op.BotID, describeRemediation(action)))
+		sample, _ := latestSampleOf(op.BotID)
+		stats := BotStats{BotID: op.BotID, BotName: sample.BotName}
+		if err := runRemediation(action, statusHookEvent(stats, StatusDown, 0)); err != nil {
+			outcome = "❌ 失敗しました"
+			content += "\n" + err.Error()
+			log.Printf("Error running %s for bot %s: %v", op.Operation, op.BotID, err)
+		}
+	}
+
+	components := opsButtons(id, outcome)
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &content, Components: &components}); err != nil {
+		log.Printf("Error editing /bot confirmation: %v", err)
+	}
+}
+
+func updateOpsMessage(s *discordgo.Session, i *discordgo.InteractionCreate, id, outcome string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    i.Message.Content,
+			Components: opsButtons(id, outcome),
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating /bot confirmation: %v", err)
+	}
+}
+
+func botLabel(botID string) string {
+	if sample, known := latestSampleOf(botID); known && sample.BotName != "" {
+		return fmt.Sprintf("%s (%s)", sample.BotName, botID)
+	}
+	return botID
+}
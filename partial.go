@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// sentReport remembers the messages a report was posted as, so they can be
+// edited when late results arrive.
+type sentReport struct {
+	Destination Destination
+	Messages    []*discordgo.Message
+}
+
+// patchReports fills in bots that were still pending when the report was
+// sent, editing every sent report in place as each result arrives. The
+// results come processed from the run's collection, and their changes are
+// rebased on the report's baseline.
+func patchReports(allStats []BotStats, network *NetworkStats, late <-chan fetchResult, reports []sentReport, baseline map[string]int) {
+	for result := range late {
+		log.Printf("Late result arrived for bot %s, updating %d reports", result.stats.BotID, len(reports))
+		storeLateResult(allStats, result)
+		applyBaseline(allStats[result.index:result.index+1], baseline)
+
+		reportStats := transformStats(allStats)
+		for i := range reports {
+			var err error
+			if config.ReportImage {
+				err = editReportCard(&reports[i], reportStats, network)
+			} else {
+				message := buildReportMessage(reportStats, network, reports[i].Destination)
+				err = editReport(&reports[i], splitMessage(message))
+			}
+			metrics.recordDelivery("report edit", err)
+			if err != nil {
+				log.Printf("Error updating report in channel %s: %v", reports[i].Destination.ChannelID, err)
+			}
+		}
+	}
+}
+
+// processLateResult stores a late bot's stats and runs the per-run
+// processing that was skipped while it was pending.
+func processLateResult(allStats []BotStats, result fetchResult) {
+	allStats[result.index] = result.stats
+
+	recordChanges(allStats[result.index : result.index+1])
+	recordPeriodChanges(allStats[result.index : result.index+1])
+	computeMetrics(allStats)
+	updateLatest(allStats[result.index : result.index+1])
+	recordSnapshots(allStats[result.index : result.index+1])
+	storeSamples(allStats[result.index : result.index+1])
+	recordHistory(allStats[result.index : result.index+1])
+	evaluateAlerts(allStats)
+	alertStats := smoothStats(allStats[result.index : result.index+1])
+	evaluateAnomalies(alertStats)
+	evaluateRules(alertStats)
+	evaluateFeed(allStats[result.index : result.index+1])
+}
+
+// storeLateResult puts a processed late result into a run's copy of the
+// stats.
+func storeLateResult(allStats []BotStats, result fetchResult) {
+	allStats[result.index] = result.stats
+	computeMetrics(allStats)
+}
+
+// editReport replaces the content of a sent report. Parts beyond the
+// original message count are posted as new messages, and surplus messages
+// are deleted.
+func editReport(report *sentReport, parts []string) error {
+	var messages []*discordgo.Message
+
+	for i, part := range parts {
+		if i < len(report.Messages) {
+			sent := report.Messages[i]
+			if sent.Content == part {
+				messages = append(messages, sent)
+				continue
+			}
+			if sent.ID == dryRunMessageID {
+				messages = append(messages, dryRunMessage(sent.ChannelID, part))
+				continue
+			}
+			edited, err := session.ChannelMessageEdit(sent.ChannelID, sent.ID, part)
+			if err != nil {
+				return err
+			}
+			messages = append(messages, edited)
+			continue
+		}
+
+		sent, err := sendChannelMessage(report.Destination.ChannelID, part)
+		if err != nil {
+			return err
+		}
+		publishMessages(report.Destination, []*discordgo.Message{sent})
+		messages = append(messages, sent)
+	}
+
+	for _, surplus := range report.Messages[min(len(parts), len(report.Messages)):] {
+		if surplus.ID == dryRunMessageID {
+			continue
+		}
+		if err := session.ChannelMessageDelete(surplus.ChannelID, surplus.ID); err != nil {
+			return err
+		}
+	}
+
+	report.Messages = messages
+	return nil
+}
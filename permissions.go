@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type channelPermission struct {
+	Bit  int64
+	Name string
+}
+
+// Permissions every destination channel needs for messages to go through.
+var requiredPermissions = []channelPermission{
+	{discordgo.PermissionViewChannel, "View Channel"},
+	{discordgo.PermissionSendMessages, "Send Messages"},
+}
+
+// Permissions that aren't needed to send but affect how messages render
+// (links in notes don't unfurl without Embed Links).
+var recommendedPermissions = []channelPermission{
+	{discordgo.PermissionEmbedLinks, "Embed Links"},
+}
+
+var (
+	watcherMu sync.Mutex
+	watcherID string
+)
+
+// watcherUserID returns the watcher's own user ID. The session state only
+// has it once the gateway connection is open, which CLI commands never do,
+// so it is looked up once otherwise.
+func watcherUserID() (string, error) {
+	if user := session.State.User; user != nil {
+		return user.ID, nil
+	}
+
+	watcherMu.Lock()
+	defer watcherMu.Unlock()
+	if watcherID == "" {
+		user, err := session.User("@me")
+		if err != nil {
+			return "", err
+		}
+		watcherID = user.ID
+	}
+	return watcherID, nil
+}
+
+func missingPermissions(channelID string, permissions []channelPermission) ([]string, error) {
+	userID, err := watcherUserID()
+	if err != nil {
+		return nil, err
+	}
+	granted, err := session.UserChannelPermissions(userID, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, permission := range permissions {
+		if granted&permission.Bit != permission.Bit {
+			missing = append(missing, permission.Name)
+		}
+	}
+	return missing, nil
+}
+
+// checkSendPermissions returns an error naming the missing permissions if
+// the watcher can't post in the channel.
+func checkSendPermissions(channelID string) error {
+	missing, err := missingPermissions(channelID, requiredPermissions)
+	if err != nil {
+		return fmt.Errorf("failed to check permissions in channel %s: %v", channelID, err)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing permissions in channel %s: %s", channelLabel(channelID), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func channelLabel(channelID string) string {
+	if channel, err := session.State.Channel(channelID); err == nil {
+		return fmt.Sprintf("#%s (%s)", channel.Name, channelID)
+	}
+	return channelID
+}
+
+// destinationChannels lists every channel reference the watcher posts to.
+func destinationChannels() []string {
+	seen := make(map[string]bool)
+	var refs []string
+	add := func(ref string) {
+		if ref != "" && !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	for _, destination := range config.Destinations {
+		add(destination.ChannelID)
+	}
+	add(config.FeedChannelID)
+	if config.ListingTracking {
+		add(config.ListingChannelID)
+	}
+	for _, route := range config.AlertRoutes {
+		for _, channelID := range route.ChannelIDs {
+			add(channelID)
+		}
+	}
+	return refs
+}
+
+// preflightPermissions checks every destination at startup and logs exactly
+// which permission is missing where.
+func preflightPermissions() {
+	problems := 0
+
+	for _, ref := range destinationChannels() {
+		channelID, err := resolveChannel(ref)
+		if err != nil {
+			log.Printf("❌ Cannot resolve channel %s: %v", ref, err)
+			problems++
+			continue
+		}
+
+		missing, err := missingPermissions(channelID, requiredPermissions)
+		if err != nil {
+			log.Printf("❌ Cannot check permissions in channel %s: %v", channelID, err)
+			problems++
+			continue
+		}
+		if len(missing) > 0 {
+			log.Printf("❌ Missing permissions in channel %s: %s", channelLabel(channelID), strings.Join(missing, ", "))
+			problems++
+		}
+
+		if missing, err := missingPermissions(channelID, recommendedPermissions); err == nil && len(missing) > 0 {
+			log.Printf("⚠️ Recommended permissions missing in channel %s: %s", channelLabel(channelID), strings.Join(missing, ", "))
+		}
+	}
+
+	for _, destination := range config.Destinations {
+		if !destination.Publish {
+			continue
+		}
+		channelID, err := resolveChannel(destination.ChannelID)
+		if err != nil {
+			continue
+		}
+		if channel, err := session.State.Channel(channelID); err == nil && channel.Type != discordgo.ChannelTypeGuildNews {
+			log.Printf("⚠️ Channel %s is not an announcement channel, reports sent there can't be published", channelLabel(channelID))
+			problems++
+		}
+	}
+
+	if problems == 0 {
+		log.Printf("Permission check passed for all destination channels")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// redirectTransport sends every request to the test server instead,
+// through base or http.DefaultTransport.
+type redirectTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	if t.base != nil {
+		return t.base.RoundTrip(req)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// fakeDiscord points the session at a fake REST API, without opening the
+// gateway, in which the watcher (user 900) has the permissions in channel
+// 111 of guild 222. It returns the contents of the messages posted there.
+func fakeDiscord(t testing.TB, permissions int64) func() []string {
+	t.Helper()
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v"), "/")
+		var reply any
+		switch r.Method + " /" + path {
+		case "GET /users/@me":
+			reply = map[string]any{"id": "900", "username": "statbot", "bot": true}
+		case "GET /channels/111":
+			reply = map[string]any{"id": "111", "guild_id": "222", "name": "alerts", "type": 0}
+		case "GET /guilds/222":
+			reply = map[string]any{"id": "222", "owner_id": "1", "roles": []any{
+				map[string]any{"id": "222", "name": "@everyone", "permissions": strconv.FormatInt(permissions, 10)},
+			}}
+		case "GET /guilds/222/members/900":
+			reply = map[string]any{"user": map[string]any{"id": "900"}, "roles": []string{}}
+		case "POST /channels/111/messages":
+			var message struct {
+				Content string `json:"content"`
+			}
+			json.NewDecoder(r.Body).Decode(&message)
+			mu.Lock()
+			sent = append(sent, message.Content)
+			mu.Unlock()
+			reply = map[string]any{"id": "333", "channel_id": "111", "content": message.Content}
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Unknown", "code": 0}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reply)
+	}))
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+	s, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Client = &http.Client{Transport: redirectTransport{target: target}}
+
+	previousSession, previousConfig := session, config
+	session = s
+	watcherID = ""
+	t.Cleanup(func() {
+		session, config = previousSession, previousConfig
+		watcherID = ""
+	})
+
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), sent...)
+	}
+}
+
+// The CLI never opens the gateway, so the session state has no user.
+func TestSendTestAlertWithoutGateway(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions int64
+		wantSent    bool
+		wantError   string
+	}{
+		{"allowed", discordgo.PermissionViewChannel | discordgo.PermissionSendMessages, true, ""},
+		{"missing send", discordgo.PermissionViewChannel, false, "Send Messages"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sent := fakeDiscord(t, test.permissions)
+			config.AlertRoutes = map[Severity]AlertRoute{SeverityInfo: {ChannelIDs: []string{"111"}}}
+			config.Notifiers = buildNotifiers(config.AlertRoutes)
+
+			summary := sendTestAlert(SeverityInfo)
+
+			if got := len(sent()) == 1; got != test.wantSent {
+				t.Errorf("sent %d messages, want sent: %v\n%s", len(sent()), test.wantSent, summary)
+			}
+			if test.wantError == "" && strings.Contains(summary, "❌") {
+				t.Errorf("summary reports a failure:\n%s", summary)
+			}
+			if test.wantError != "" && !strings.Contains(summary, test.wantError) {
+				t.Errorf("summary does not mention %q:\n%s", test.wantError, summary)
+			}
+		})
+	}
+}
+
+// Messages with buttons go through the same permission check as the rest.
+func TestSendWithComponentsChecksPermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions int64
+		wantErr     bool
+	}{
+		{"allowed", discordgo.PermissionViewChannel | discordgo.PermissionSendMessages, false},
+		{"missing send", discordgo.PermissionViewChannel, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sent := fakeDiscord(t, test.permissions)
+
+			_, err := sendWithComponents("111", "approve?", approvalButtons("1", ""))
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, test.wantErr)
+			}
+			if got, want := len(sent()), 1; test.wantErr && got != 0 || !test.wantErr && got != want {
+				t.Errorf("sent %d messages", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robfig/cron/v3"
+)
+
+const publishButtonPrefix = "publish:"
+
+// offsetSchedule fires a fixed duration before another schedule.
+type offsetSchedule struct {
+	schedule cron.Schedule
+	offset   time.Duration
+}
+
+func (s offsetSchedule) Next(t time.Time) time.Time {
+	return s.schedule.Next(t.Add(s.offset)).Add(-s.offset)
+}
+
+// reportPreview is a report posted to the preview channel ahead of its
+// scheduled time. The scheduled run publishes exactly these stats, so what
+// was reviewed is what goes out.
+type reportPreview struct {
+	RunID        string
+	Destinations []Destination
+	Stats        []BotStats
+	Network      *NetworkStats
+	Published    bool
+}
+
+var (
+	previewMu sync.Mutex
+	previews  = make(map[string]*reportPreview) // Timezone group -> Pending preview
+)
+
+// schedulePreview posts the group's report PREVIEW_MINUTES before expr.
+func schedulePreview(c *cron.Cron, expr, group string, destinations []Destination) error {
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return err
+	}
+
+	offset := time.Duration(config.PreviewMinutes) * time.Minute
+	var id cron.EntryID
+	id = c.Schedule(offsetSchedule{schedule: schedule, offset: offset}, cron.FuncJob(func() {
+		// The report itself is scheduled offset after this run
+		tick := c.Entry(id).Prev
+		sendPreview(group, destinations, tick, tick.Add(offset))
+	}))
+	log.Printf("Report preview scheduled %d minutes ahead (%s)", config.PreviewMinutes, group)
+	return nil
+}
+
+func sendPreview(group string, destinations []Destination, tick, publishAt time.Time) {
+	allStats, network, late := collectTick(tick)
+	// The preview is early enough to wait for every bot
+	for result := range late {
+		storeLateResult(allStats, result)
+	}
+	applyBaseline(allStats, reportBaseline(destinations))
+
+	preview := &reportPreview{
+		RunID:        reportRunID(publishAt, group),
+		Destinations: destinations,
+		Stats:        allStats,
+		Network:      network,
+	}
+
+	// Render as the group's first destination would see it
+	destination := destinations[0]
+	destination.ChannelID = config.PreviewChannelID
+	message := fmt.Sprintf("👀 **プレビュー**: %s に以下のレポートが%d件の送信先に送信されます\n",
+		publishAt.In(destination.Location).Format("15:04"), len(destinations)) +
+		buildReportMessage(transformStats(allStats), network, destination)
+
+	if _, err := sendWithComponents(config.PreviewChannelID, message, publishButton(group, false)); err != nil {
+		log.Printf("Error sending report preview: %v", err)
+		return
+	}
+
+	previewMu.Lock()
+	previews[group] = preview
+	previewMu.Unlock()
+}
+
+func publishButton(group string, published bool) []discordgo.MessageComponent {
+	label := "今すぐ公開"
+	if published {
+		label = "公開済み"
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    label,
+				Style:    discordgo.PrimaryButton,
+				CustomID: publishButtonPrefix + group,
+				Disabled: published,
+			},
+		}},
+	}
+}
+
+// takePreview removes and returns the group's pending preview, if any.
+func takePreview(group string) *reportPreview {
+	previewMu.Lock()
+	defer previewMu.Unlock()
+
+	preview := previews[group]
+	delete(previews, group)
+	return preview
+}
+
+// runScheduledReport sends the group's report at its scheduled time. With a
+// preview channel, the previewed stats are sent unless the preview was
+// already published early.
+func runScheduledReport(runID string, scheduledAt time.Time, group string, destinations []Destination) {
+	if config.PreviewChannelID == "" {
+		notifyDestinations(runID, scheduledAt, destinations)
+		return
+	}
+
+	preview := takePreview(group)
+	switch {
+	case preview == nil:
+		log.Printf("No preview found for %s, fetching stats now", group)
+		notifyDestinations(runID, scheduledAt, destinations)
+	case preview.Published:
+		log.Printf("Report for %s was already published from its preview", group)
+	default:
+		start := time.Now()
+		publishReport(runID, preview.Destinations, preview.Stats, preview.Network, nil)
+		metrics.recordRun(start)
+		recordRunResult(runID, start, runOutcomes(preview.Stats))
+	}
+}
+
+func handlePublishButton(s *discordgo.Session, i *discordgo.InteractionCreate, group string) {
+	previewMu.Lock()
+	preview, exists := previews[group]
+	publish := exists && !preview.Published
+	if publish {
+		preview.Published = true
+	}
+	previewMu.Unlock()
+
+	if !publish {
+		respondEphemeral(s, i, "このプレビューのレポートは既に送信されています")
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    i.Message.Content,
+			Components: publishButton(group, true),
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating preview message: %v", err)
+	}
+
+	user := i.User
+	if i.Member != nil {
+		user = i.Member.User
+	}
+	log.Printf("Report preview for %s published early by %s", group, user.Username)
+
+	publishReport(preview.RunID, preview.Destinations, preview.Stats, preview.Network, nil)
+}
+
+// isPublishButton reports whether a component custom ID belongs to a
+// preview's publish button, and returns its timezone group.
+func isPublishButton(customID string) (string, bool) {
+	return strings.CutPrefix(customID, publishButtonPrefix)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// startProfiling serves the pprof endpoints on PPROF_ADDR. They expose
+// goroutine stacks and heap contents, so they get their own listener that
+// should be bound to localhost or a private network, not the public
+// HTTP_ADDR.
+func startProfiling() {
+	if config.PprofAddr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	server := &http.Server{
+		Addr:              config.PprofAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("pprof listening on %s", config.PprofAddr)
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("pprof server stopped: %v", err)
+		}
+	}()
+}
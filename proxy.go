@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const topGGAPI = "https://top.gg/api/"
+
+// cachedResponse is an upstream response kept for PROXY_TTL.
+type cachedResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+	Expires     time.Time
+}
+
+var (
+	proxyMu         sync.Mutex
+	proxyCache      = make(map[string]*cachedResponse)
+	proxyInflight   = make(map[string]*sync.Mutex)
+	proxyRetryAfter time.Time
+)
+
+// handleTopGGProxy serves GET /proxy/topgg/bots/{bot_id} and
+// /proxy/topgg/bots/{bot_id}/stats from a cache of top.gg responses, so
+// other services share the watcher's token and rate limits. Callers
+// authenticate with "Authorization: Bearer PROXY_TOKEN".
+func handleTopGGProxy(w http.ResponseWriter, r *http.Request) {
+	if !bearerAuthorized(r, config.ProxyToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/proxy/topgg/")
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] != "bots" || parts[1] == "" || (len(parts) == 3 && parts[2] != "stats") {
+		http.NotFound(w, r)
+		return
+	}
+	botID := parts[1]
+
+	cached, err := cachedTopGG(botID, path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	cacheState := "HIT"
+	age := time.Until(cached.Expires)
+	if age <= 0 {
+		cacheState = "STALE"
+		age = 0
+	}
+	w.Header().Set("X-Cache", cacheState)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(age.Seconds())))
+	if cached.ContentType != "" {
+		w.Header().Set("Content-Type", cached.ContentType)
+	}
+	w.WriteHeader(cached.Status)
+	w.Write(cached.Body)
+}
+
+// bearerAuthorized reports whether the request sends "Authorization: Bearer
+// <token>" with the expected token, which must not be empty.
+func bearerAuthorized(r *http.Request, expected string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// cachedTopGG returns the cached response for path, fetching it from top.gg
+// when it has expired. Concurrent misses for the same path share one
+// upstream request. When top.gg fails or asks to back off, the expired
+// response is served if there is one.
+func cachedTopGG(botID, path string) (*cachedResponse, error) {
+	proxyMu.Lock()
+	lock, exists := proxyInflight[path]
+	if !exists {
+		lock = &sync.Mutex{}
+		proxyInflight[path] = lock
+	}
+	proxyMu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	proxyMu.Lock()
+	cached := proxyCache[path]
+	backingOff := time.Now().Before(proxyRetryAfter)
+	proxyMu.Unlock()
+
+	if cached != nil && (time.Now().Before(cached.Expires) || backingOff) {
+		return cached, nil
+	}
+	if backingOff {
+		return nil, wrapKind(ErrRateLimited, fmt.Errorf("top.gg rate limit, retry after %s", time.Until(proxyRetryAfter).Round(time.Second)))
+	}
+
+	fresh, err := fetchTopGG(botID, path)
+	if err != nil {
+		if cached != nil {
+			log.Printf("Error refreshing top.gg %s, serving stale response: %v", path, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	proxyMu.Lock()
+	proxyCache[path] = fresh
+	proxyMu.Unlock()
+	return fresh, nil
+}
+
+// fetchTopGG requests path from the top.gg API. Only successful and
+// not-found responses are cacheable; other statuses are errors.
+func fetchTopGG(botID, path string) (*cachedResponse, error) {
+	url := topGGAPI + path
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", config.TopGGToken)
+	identifyRequest(req)
+	req = tagBot(req, botID)
+
+	waitForHost(url)
+	client := &http.Client{Timeout: fetchTimeout(botID, "topgg")}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if retryAfter <= 0 {
+			retryAfter = 60
+		}
+		proxyMu.Lock()
+		proxyRetryAfter = time.Now().Add(time.Duration(retryAfter) * time.Second)
+		proxyMu.Unlock()
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		err := fmt.Errorf("top.gg API returned status %d: %s", resp.StatusCode, string(body))
+		if kind := statusError(resp.StatusCode); kind != nil {
+			err = wrapKind(kind, err)
+		}
+		return nil, err
+	}
+
+	return &cachedResponse{
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        body,
+		Expires:     time.Now().Add(config.ProxyTTL),
+	}, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PublicCount is one bot in the public count API.
+type PublicCount struct {
+	BotID       string             `json:"bot_id"`
+	BotName     string             `json:"bot_name"`
+	ServerCount int                `json:"server_count"`
+	Estimated   bool               `json:"estimated,omitempty"`
+	Metrics     map[string]float64 `json:"metrics,omitempty"` // Computed metrics in PUBLIC_REPORT_METRICS
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// handlePublicCounts serves /api/counts (every allowlisted bot) and
+// /api/counts/{bot_id}. It needs no authentication, so only bots listed in
+// PUBLIC_API_BOTS are exposed, and only their latest server count.
+func handlePublicCounts(w http.ResponseWriter, r *http.Request) {
+	if !setCORSHeaders(w, r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body any
+	if botID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/counts"), "/"); botID != "" {
+		count, ok := publicCount(botID)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		body = count
+	} else {
+		counts := []PublicCount{}
+		for _, botID := range config.PublicAPIBots {
+			if count, ok := publicCount(botID); ok {
+				counts = append(counts, count)
+			}
+		}
+		list := map[string]any{"bots": counts}
+		if totals := publicMetrics(latestComputedTotals()); len(totals) > 0 {
+			list["metrics"] = totals
+		}
+		body = list
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha1.Sum(data)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func publicCount(botID string) (PublicCount, bool) {
+	allowed := false
+	for _, id := range config.PublicAPIBots {
+		if id == botID {
+			allowed = true
+			break
+		}
+	}
+	if !allowed || config.PrivateBots[botID] {
+		return PublicCount{}, false
+	}
+
+	sample, known := latestSampleOf(botID)
+	if !known {
+		return PublicCount{}, false
+	}
+	return PublicCount{
+		BotID:       botID,
+		BotName:     sample.BotName,
+		ServerCount: sample.ServerCount,
+		Estimated:   sample.Estimated,
+		Metrics:     publicMetrics(sample.Computed),
+		UpdatedAt:   sample.Time,
+	}, true
+}
+
+// publicMetrics keeps the computed metrics listed in PUBLIC_REPORT_METRICS.
+func publicMetrics(values map[string]float64) map[string]float64 {
+	public := make(map[string]float64)
+	for name, value := range values {
+		if config.PublicMetrics[name] {
+			public[name] = value
+		}
+	}
+	return public
+}
+
+// setCORSHeaders allows the request's origin if it is in PUBLIC_API_ORIGINS
+// (any origin when unset). It reports false for a disallowed origin.
+func setCORSHeaders(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if len(config.PublicAPIOrigins) == 0 {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		allowed := false
+		for _, o := range config.PublicAPIOrigins {
+			if strings.EqualFold(o, origin) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "If-None-Match")
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
+	w.Header().Set("Access-Control-Max-Age", "86400")
+	return true
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ntfyNotifier publishes alerts to an ntfy topic URL.
+type ntfyNotifier struct{}
+
+func (ntfyNotifier) Name() string { return "ntfy" }
+
+func (ntfyNotifier) Notify(alert Alert, text string) error {
+	if config.NtfyURL == "" {
+		return fmt.Errorf("ntfy: NTFY_URL is not set")
+	}
+
+	req, err := http.NewRequest("POST", config.NtfyURL, strings.NewReader(text))
+	if err != nil {
+		return fmt.Errorf("ntfy: %v", err)
+	}
+	req.Header.Set("Title", fmt.Sprintf("statbot %s alert", strings.ToUpper(alert.Severity.String())))
+	req.Header.Set("Priority", map[Severity]string{
+		SeverityInfo:     "default",
+		SeverityWarn:     "high",
+		SeverityCritical: "urgent",
+	}[alert.Severity])
+	req.Header.Set("Tags", alert.Severity.String())
+	if config.NtfyToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.NtfyToken)
+	}
+	identifyRequest(req)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ntfy: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ntfy: server returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// pushoverNotifier sends alerts through the Pushover message API.
+type pushoverNotifier struct{}
+
+func (pushoverNotifier) Name() string { return "pushover" }
+
+func (pushoverNotifier) Notify(alert Alert, text string) error {
+	if config.PushoverToken == "" || config.PushoverUser == "" {
+		return fmt.Errorf("Pushover: PUSHOVER_TOKEN and PUSHOVER_USER must be set")
+	}
+
+	// High priority bypasses the user's quiet hours
+	priority := map[Severity]int{
+		SeverityInfo:     -1,
+		SeverityWarn:     0,
+		SeverityCritical: 1,
+	}[alert.Severity]
+
+	form := url.Values{
+		"token":     {config.PushoverToken},
+		"user":      {config.PushoverUser},
+		"title":     {fmt.Sprintf("statbot %s alert", strings.ToUpper(alert.Severity.String()))},
+		"message":   {text},
+		"priority":  {strconv.Itoa(priority)},
+		"timestamp": {strconv.FormatInt(alert.Time.Unix(), 10)},
+	}
+
+	req, err := http.NewRequest("POST", "https://api.pushover.net/1/messages.json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("Pushover: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	identifyRequest(req)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Pushover: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Pushover: API returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Per-bot variables of the Redis source: REDIS_URL_<BOT_ID> is the server,
+// REDIS_KEY_<BOT_ID> the key holding the count, and REDIS_FIELD_<BOT_ID>
+// the field when the key is a hash.
+const (
+	redisURLPrefix   = "REDIS_URL_"
+	redisKeyPrefix   = "REDIS_KEY_"
+	redisFieldPrefix = "REDIS_FIELD_"
+)
+
+// RedisSource is a Redis key (or hash field) holding a bot's count.
+type RedisSource struct {
+	URL   string
+	Key   string
+	Field string // Optional: read with HGET instead of GET
+}
+
+func loadRedisSources() map[string]RedisSource {
+	sources := make(map[string]RedisSource)
+	keys := getEnvPerBot(redisKeyPrefix)
+	fields := getEnvPerBot(redisFieldPrefix)
+	for botID, redisURL := range getEnvPerBot(redisURLPrefix) {
+		if key, exists := keys[botID]; exists {
+			sources[botID] = RedisSource{URL: redisURL, Key: key, Field: fields[botID]}
+		}
+	}
+	return sources
+}
+
+// validateRedisSources checks that every Redis source belongs to a target
+// bot, has a key and a redis:// or rediss:// URL.
+func validateRedisSources(problems *configProblems, targetIDs map[string]bool) {
+	urls := getEnvPerBot(redisURLPrefix)
+	keys := getEnvPerBot(redisKeyPrefix)
+
+	botIDs := make([]string, 0, len(urls))
+	for botID := range urls {
+		botIDs = append(botIDs, botID)
+	}
+	for botID := range keys {
+		if _, exists := urls[botID]; !exists {
+			botIDs = append(botIDs, botID)
+		}
+	}
+	sort.Strings(botIDs)
+
+	for _, botID := range botIDs {
+		name := redisURLPrefix + botID
+		if !targetIDs[botID] {
+			problems.add(name, "bot is not in TARGET_BOT_IDS")
+		}
+		redisURL, hasURL := urls[botID]
+		if _, hasKey := keys[botID]; !hasKey {
+			problems.add(name, "%s%s is not set", redisKeyPrefix, botID)
+		}
+		if !hasURL {
+			problems.add(redisKeyPrefix+botID, "%s is not set", name)
+		} else if parsed, err := url.Parse(redisURL); err != nil || (parsed.Scheme != "redis" && parsed.Scheme != "rediss") || parsed.Host == "" {
+			problems.add(name, "expected redis://[USER:PASSWORD@]HOST:PORT[/DB] or rediss://")
+		}
+	}
+}
+
+// getServerCountFromRedis reads the count with GET, or HGET for a hash
+// field. The value is either a number or a JSON object with one of the
+// field names custom webhooks use.
+func getServerCountFromRedis(source RedisSource, timeout time.Duration) (int, error) {
+	conn, err := dialRedis(source.URL, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var reply *string
+	if source.Field != "" {
+		reply, err = conn.do("HGET", source.Key, source.Field)
+	} else {
+		reply, err = conn.do("GET", source.Key)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if reply == nil {
+		return 0, fmt.Errorf("redis key %s is not set", redisKeyLabel(source))
+	}
+
+	value := strings.TrimSpace(*reply)
+	if count, err := strconv.Atoi(value); err == nil {
+		return count, nil
+	}
+	var result map[string]any
+	if err := json.Unmarshal([]byte(value), &result); err == nil {
+		if count, found := countFromFields(result); found {
+			return count, nil
+		}
+	}
+	return 0, fmt.Errorf("could not parse server count from redis key %s", redisKeyLabel(source))
+}
+
+func redisKeyLabel(source RedisSource) string {
+	if source.Field != "" {
+		return source.Key + " " + source.Field
+	}
+	return source.Key
+}
+
+// redisConn is a minimal RESP client: enough to authenticate, select a
+// database and read a single value.
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func dialRedis(redisURL string, timeout time.Duration) (*redisConn, error) {
+	parsed, err := url.Parse(redisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	if parsed.Scheme == "rediss" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", parsed.Host, &tls.Config{ServerName: parsed.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", parsed.Host)
+	}
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return nil, wrapKind(ErrTimeout, err)
+		}
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	c := &redisConn{Conn: conn, reader: bufio.NewReader(conn)}
+
+	if parsed.User != nil {
+		password, hasPassword := parsed.User.Password()
+		args := []string{"AUTH", password}
+		if !hasPassword {
+			args = []string{"AUTH", parsed.User.Username()}
+		} else if username := parsed.User.Username(); username != "" {
+			args = []string{"AUTH", username, password}
+		}
+		if _, err := c.do(args...); err != nil {
+			c.Close()
+			return nil, wrapKind(ErrUnauthorized, err)
+		}
+	}
+	if db := strings.Trim(parsed.Path, "/"); db != "" && db != "0" {
+		if _, err := c.do("SELECT", db); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// do sends a command and returns its reply, or nil for a nil reply.
+func (c *redisConn) do(args ...string) (*string, error) {
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.Write([]byte(command.String())); err != nil {
+		return nil, err
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, fmt.Errorf("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		value := line[1:]
+		return &value, nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis reply %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2) // Value and trailing CRLF
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		value := string(data[:size])
+		return &value, nil
+	default:
+		return nil, fmt.Errorf("unexpected redis reply %q (the key must hold a string or hash)", line)
+	}
+}
+
+func (c *redisConn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers every command read from conn with the next reply.
+func fakeRedis(t *testing.T, conn net.Conn, replies ...string) <-chan []string {
+	t.Helper()
+	commands := make(chan []string, len(replies))
+	go func() {
+		defer close(commands)
+		reader := bufio.NewReader(conn)
+		for _, reply := range replies {
+			command, err := readRESPCommand(reader)
+			if err != nil {
+				return
+			}
+			commands <- command
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return
+			}
+		}
+	}()
+	return commands
+}
+
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	var count int
+	if _, err := fmt.Sscanf(line, "*%d\r\n", &count); err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		var size int
+		if _, err := fmt.Sscanf(line, "$%d\r\n", &size); err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func TestRedisDo(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    string
+		isNil   bool
+		wantErr string
+	}{
+		{"simple string", "+OK\r\n", "OK", false, ""},
+		{"integer", ":42\r\n", "42", false, ""},
+		{"bulk string", "$5\r\nhello\r\n", "hello", false, ""},
+		{"bulk string with CRLF", "$7\r\n12\r\n345\r\n", "12\r\n345", false, ""},
+		{"empty bulk string", "$0\r\n\r\n", "", false, ""},
+		{"nil", "$-1\r\n", "", true, ""},
+		{"error", "-WRONGTYPE Operation against a key\r\n", "", false, "redis: WRONGTYPE"},
+		{"array", "*1\r\n$1\r\na\r\n", "", false, "must hold a string or hash"},
+		{"bad size", "$x\r\n", "", false, "invalid redis reply"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+			commands := fakeRedis(t, server, test.reply)
+
+			c := &redisConn{Conn: client, reader: bufio.NewReader(client)}
+			got, err := c.do("HGET", "stats", "guild count")
+			if command := <-commands; strings.Join(command, "|") != "HGET|stats|guild count" {
+				t.Errorf("server read %q", command)
+			}
+
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("err = %v, want one mentioning %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("do: %v", err)
+			}
+			if test.isNil {
+				if got != nil {
+					t.Errorf("got %q, want nil", *got)
+				}
+				return
+			}
+			if got == nil || *got != test.want {
+				t.Errorf("got %v, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDialRedisAuthenticatesAndSelects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for command := range fakeRedis(t, conn, "+OK\r\n", "+OK\r\n") {
+			received <- command
+		}
+		close(received)
+	}()
+
+	c, err := dialRedis("redis://app:secret@"+listener.Addr().String()+"/2", time.Second)
+	if err != nil {
+		t.Fatalf("dialRedis: %v", err)
+	}
+	c.Close()
+
+	var commands []string
+	for command := range received {
+		commands = append(commands, strings.Join(command, " "))
+	}
+	if want := "AUTH app secret,SELECT 2"; strings.Join(commands, ",") != want {
+		t.Errorf("commands = %q, want %q", commands, want)
+	}
+}
+
+func TestDialRedisRejectedPassword(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for range fakeRedis(t, conn, "-WRONGPASS invalid password\r\n") {
+		}
+	}()
+
+	_, err = dialRedis("redis://:wrong@"+listener.Addr().String(), time.Second)
+	if errorKind(err) != "unauthorized" {
+		t.Errorf("err = %v (kind %q), want an unauthorized error", err, errorKind(err))
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	remediationPrefix = "REMEDIATE_"
+	redeployPrefix    = "REDEPLOY_"
+	dockerPrefix      = "docker:"
+)
+
+var containerPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Remediation and redeploy actions are read from REMEDIATE_<BOT_ID> and
+// REDEPLOY_<BOT_ID>. An action is an http(s) URL that receives a POST (e.g.
+// the host's supervisor), "docker:CONTAINER" to restart a local container,
+// or a shell command such as an ssh invocation. Actions get the on_status
+// payload like hooks do.
+
+// remediation tracks a down bot waiting for its remediation action, and the
+// actions already taken for it.
+type remediation struct {
+	Down  bool
+	Since time.Time
+	Timer *time.Timer
+	Runs  []time.Time // Actions taken in the last 24 hours
+}
+
+var (
+	remediationMu sync.Mutex
+	remediations  = make(map[string]*remediation) // Bot ID -> remediation
+)
+
+// validateRemediation checks that every REMEDIATE_<BOT_ID> and
+// REDEPLOY_<BOT_ID> belongs to a target bot and has a usable action, and
+// the OPS_ROLE_IDS allowed to run them by hand.
+func validateRemediation(problems *configProblems, targetIDs map[string]bool) {
+	for i, roleID := range getEnvList("OPS_ROLE_IDS") {
+		if !snowflakePattern.MatchString(roleID) {
+			problems.add(fmt.Sprintf("OPS_ROLE_IDS[%d]", i), "%q is not a Discord ID", roleID)
+		}
+	}
+
+	var names []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, remediationPrefix) || strings.HasPrefix(name, redeployPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		action := strings.TrimSpace(os.Getenv(name))
+		botID := strings.TrimPrefix(strings.TrimPrefix(name, remediationPrefix), redeployPrefix)
+		if !targetIDs[botID] {
+			problems.add(name, "bot is not in TARGET_BOT_IDS")
+		}
+		switch {
+		case action == "":
+			problems.add(name, "empty action")
+		case strings.HasPrefix(action, "http://") || strings.HasPrefix(action, "https://"):
+			validateURL(problems, name, action)
+		case strings.HasPrefix(action, dockerPrefix):
+			if container := strings.TrimPrefix(action, dockerPrefix); !containerPattern.MatchString(container) {
+				problems.add(name, "%q is not a container name", container)
+			}
+		}
+	}
+}
+
+// scheduleRemediation arms the remediation action of a bot that just went
+// down. It runs once the bot has been down for REMEDIATION_AFTER.
+func scheduleRemediation(stats BotStats) {
+	if _, exists := config.RemediationActions[stats.BotID]; !exists {
+		return
+	}
+
+	remediationMu.Lock()
+	defer remediationMu.Unlock()
+
+	state, exists := remediations[stats.BotID]
+	if !exists {
+		state = &remediation{}
+		remediations[stats.BotID] = state
+	}
+	if state.Down {
+		return
+	}
+	state.Down = true
+	state.Since = time.Now()
+	armRemediation(state, stats, config.RemediationAfter)
+}
+
+// armRemediation must be called with remediationMu held.
+func armRemediation(state *remediation, stats BotStats, after time.Duration) {
+	var timer *time.Timer
+	timer = time.AfterFunc(after, func() { remediate(stats, timer) })
+	state.Timer = timer
+}
+
+// cancelRemediation stops a pending action when the bot comes back up. The
+// actions already taken still count towards REMEDIATION_LIMIT.
+func cancelRemediation(botID string) {
+	remediationMu.Lock()
+	defer remediationMu.Unlock()
+
+	state, exists := remediations[botID]
+	if !exists || !state.Down {
+		return
+	}
+	state.Down = false
+	if state.Timer != nil {
+		state.Timer.Stop()
+		state.Timer = nil
+	}
+}
+
+// remediate runs the action for a bot that is still down, within the safety
+// limits: at most REMEDIATION_LIMIT actions per bot in 24 hours, at least
+// REMEDIATION_COOLDOWN apart, and only after a fresh fetch confirms the
+// outage. While the bot stays down it is retried after every cooldown.
+func remediate(stats BotStats, timer *time.Timer) {
+	remediationMu.Lock()
+	state := remediations[stats.BotID]
+	if state == nil || !state.Down || state.Timer != timer {
+		remediationMu.Unlock()
+		return
+	}
+	state.Timer = nil
+
+	now := time.Now()
+	recent := state.recentRuns(now)
+	if len(recent) >= config.RemediationLimit {
+		remediationMu.Unlock()
+		raiseAlert(Alert{
+			Severity: SeverityCritical,
+			BotID:    stats.BotID,
+			BotName:  stats.BotName,
+			Message:  fmt.Sprintf("自動復旧の上限（24時間で%d回）に達したため、これ以上実行しません", config.RemediationLimit),
+			Rule:     RuleRemediation,
+		})
+		return
+	}
+	if len(recent) > 0 {
+		if wait := config.RemediationCooldown - now.Sub(recent[len(recent)-1]); wait > 0 {
+			armRemediation(state, stats, wait)
+			remediationMu.Unlock()
+			return
+		}
+	}
+	downtime := now.Sub(state.Since)
+	remediationMu.Unlock()
+
+	if _, err := getServerCount(stats.BotID); err == nil {
+		log.Printf("Bot %s answered again, skipping remediation", stats.BotID)
+		return
+	}
+
+	action := config.RemediationActions[stats.BotID]
+	recordAudit(nil, "remediate", fmt.Sprintf("%s: %s", stats.BotID, describeRemediation(action)))
+
+	alert := Alert{
+		Severity: SeverityWarn,
+		BotID:    stats.BotID,
+		BotName:  stats.BotName,
+		Message:  fmt.Sprintf("%v以上ダウンしているため自動復旧（%s）を実行しました", downtime.Round(time.Minute), describeRemediation(action)),
+		Rule:     RuleRemediation,
+	}
+	if config.RemediationDryRun {
+		alert.Message = fmt.Sprintf("%v以上ダウンしています。REMEDIATION_DRY_RUNのため自動復旧（%s）は実行しません", downtime.Round(time.Minute), describeRemediation(action))
+	} else if err := runRemediation(action, statusHookEvent(stats, StatusDown, downtime)); err != nil {
+		alert.Severity = SeverityCritical
+		alert.Message = fmt.Sprintf("自動復旧（%s）に失敗しました: %v", describeRemediation(action), err)
+	}
+
+	remediationMu.Lock()
+	state.Runs = append(state.Runs, time.Now())
+	if state.Down && state.Timer == nil {
+		armRemediation(state, stats, config.RemediationCooldown)
+	}
+	remediationMu.Unlock()
+
+	raiseAlert(alert)
+}
+
+// recentRuns drops the actions older than 24 hours and returns the rest. The
+// caller holds remediationMu.
+func (state *remediation) recentRuns(now time.Time) []time.Time {
+	recent := state.Runs[:0]
+	for _, run := range state.Runs {
+		if now.Sub(run) < 24*time.Hour {
+			recent = append(recent, run)
+		}
+	}
+	state.Runs = recent
+	return recent
+}
+
+// claimManualRemediation counts a /bot restart against the same
+// REMEDIATION_LIMIT and REMEDIATION_COOLDOWN as the automatic actions. It
+// returns why the restart can't run yet, or "" once it is recorded.
+func claimManualRemediation(botID string) string {
+	remediationMu.Lock()
+	defer remediationMu.Unlock()
+
+	state, exists := remediations[botID]
+	if !exists {
+		state = &remediation{}
+		remediations[botID] = state
+	}
+
+	now := time.Now()
+	recent := state.recentRuns(now)
+	if len(recent) >= config.RemediationLimit {
+		return fmt.Sprintf("24時間で%d回の上限（REMEDIATION_LIMIT）に達しています", config.RemediationLimit)
+	}
+	if len(recent) > 0 {
+		if wait := config.RemediationCooldown - now.Sub(recent[len(recent)-1]); wait > 0 {
+			return fmt.Sprintf("前回の実行から間隔が短すぎます（あと%v、REMEDIATION_COOLDOWN）", wait.Round(time.Second))
+		}
+	}
+	state.Runs = append(state.Runs, now)
+	return ""
+}
+
+func runRemediation(action string, event HookEvent) error {
+	if container, found := strings.CutPrefix(action, dockerPrefix); found {
+		action = "docker restart " + container
+	}
+	err := runHook(Hook{Target: action}, event)
+	metrics.recordDelivery("remediation", err)
+	return err
+}
+
+// describeRemediation names an action without the URL path or command
+// arguments, which may carry credentials.
+func describeRemediation(action string) string {
+	switch {
+	case strings.HasPrefix(action, dockerPrefix):
+		return "docker restart " + strings.TrimPrefix(action, dockerPrefix)
+	case strings.HasPrefix(action, "http://") || strings.HasPrefix(action, "https://"):
+		if parsed, err := url.Parse(action); err == nil {
+			return "POST " + parsed.Host
+		}
+		return "POST"
+	default:
+		command, _, _ := strings.Cut(action, " ")
+		return command
+	}
+}
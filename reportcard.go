@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	cardWidth   = 800
+	cardPadding = 40
+	cardRow     = 48
+)
+
+var (
+	cardBackground = color.RGBA{0x2b, 0x2d, 0x31, 0xff}
+	cardAccent     = color.RGBA{0x58, 0x65, 0xf2, 0xff}
+	cardText       = color.RGBA{0xf2, 0xf3, 0xf5, 0xff}
+	cardMuted      = color.RGBA{0x94, 0x9b, 0xa4, 0xff}
+	cardUp         = color.RGBA{0x23, 0xa5, 0x5a, 0xff}
+	cardDown       = color.RGBA{0xf2, 0x3f, 0x43, 0xff}
+	cardDivider    = color.RGBA{0x3f, 0x41, 0x47, 0xff}
+)
+
+// cardFonts are the faces used to draw report cards.
+type cardFonts struct {
+	title, name, count, small font.Face
+}
+
+// loadCardFonts builds the card faces from REPORT_IMAGE_FONT, or the bundled
+// Go fonts, which only cover Latin, Greek and Cyrillic script. Bot names in
+// other scripts need a font that has their glyphs, e.g. Noto Sans JP.
+func loadCardFonts() (*cardFonts, error) {
+	regularData, boldData := goregular.TTF, gobold.TTF
+	if config.ReportImageFont != "" {
+		data, err := os.ReadFile(config.ReportImageFont)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read REPORT_IMAGE_FONT: %v", err)
+		}
+		regularData, boldData = data, data
+	}
+
+	regular, err := opentype.Parse(regularData)
+	if err != nil {
+		return nil, err
+	}
+	bold, err := opentype.Parse(boldData)
+	if err != nil {
+		return nil, err
+	}
+
+	face := func(f *opentype.Font, size float64) (font.Face, error) {
+		return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	}
+
+	fonts := &cardFonts{}
+	if fonts.title, err = face(bold, 32); err != nil {
+		return nil, err
+	}
+	if fonts.name, err = face(regular, 24); err != nil {
+		return nil, err
+	}
+	if fonts.count, err = face(bold, 26); err != nil {
+		return nil, err
+	}
+	if fonts.small, err = face(regular, 18); err != nil {
+		return nil, err
+	}
+	return fonts, nil
+}
+
+// renderReportCard draws the bots a destination sees as a PNG image card.
+func renderReportCard(allStats []BotStats, network *NetworkStats, destination Destination) ([]byte, error) {
+	fonts, err := loadCardFonts()
+	if err != nil {
+		return nil, err
+	}
+
+	visible := visibleStats(allStats, destination)
+	showNetwork := network != nil && network.BotCount > 1 && (!destination.Public || config.PublicMetrics["network"])
+	aggregates := aggregateValues(allStats, destination)
+
+	height := cardPadding*2 + 70 + len(visible)*cardRow
+	if showNetwork || len(aggregates) > 0 {
+		height += 10
+	}
+	if showNetwork {
+		height += cardRow
+	}
+	height += len(aggregates) * cardRow
+
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{cardBackground}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, cardWidth, 8), &image.Uniform{cardAccent}, image.Point{}, draw.Src)
+
+	y := cardPadding + 32
+	drawText(img, fonts.title, cardText, cardPadding, y, "statbot")
+	timestamp := time.Now().In(destination.Location).Format("2006-01-02 15:04")
+	drawTextRight(img, fonts.small, cardMuted, cardWidth-cardPadding, y, timestamp)
+	y += 38
+	draw.Draw(img, image.Rect(cardPadding, y, cardWidth-cardPadding, y+2), &image.Uniform{cardDivider}, image.Point{}, draw.Src)
+
+	for _, stats := range visible {
+		y += cardRow
+
+		name := stats.BotName
+		if name == "Unknown" || name == "" {
+			name = stats.BotID
+		}
+
+		var count string
+		countColor := color.Color(cardText)
+		switch {
+		case stats.Pending:
+			count, countColor = "…", cardMuted
+		case stats.Error != nil:
+			count, countColor = "—", cardDown
+		case stats.Estimated:
+			count = "~" + formatThousands(stats.ServerCount)
+		default:
+			count = formatThousands(stats.ServerCount)
+		}
+
+		right := cardWidth - cardPadding
+		if stats.HasChange && stats.Error == nil && !stats.Pending {
+			change := fmt.Sprintf("%+d", stats.Change)
+			changeColor := cardMuted
+			if stats.Change > 0 {
+				changeColor = cardUp
+			} else if stats.Change < 0 {
+				changeColor = cardDown
+			}
+			drawTextRight(img, fonts.small, changeColor, right, y, change)
+			right -= 110
+		}
+		drawTextRight(img, fonts.count, countColor, right, y, count)
+
+		nameWidth := right - cardPadding - font.MeasureString(fonts.count, count).Ceil() - 24
+		drawText(img, fonts.name, cardText, cardPadding, y, fitText(fonts.name, name, nameWidth))
+	}
+
+	if showNetwork || len(aggregates) > 0 {
+		y += 10
+		draw.Draw(img, image.Rect(cardPadding, y, cardWidth-cardPadding, y+2), &image.Uniform{cardDivider}, image.Point{}, draw.Src)
+	}
+	if showNetwork {
+		y += cardRow
+		drawText(img, fonts.name, cardMuted, cardPadding, y, "Σ")
+		drawTextRight(img, fonts.count, cardText, cardWidth-cardPadding, y,
+			formatThousands(network.UniqueGuilds)+" / "+formatThousands(network.TotalGuilds))
+	}
+	for _, aggregate := range aggregates {
+		y += cardRow
+		drawText(img, fonts.name, cardMuted, cardPadding, y, aggregateSymbols[aggregate.Function]+" "+aggregate.Name)
+		drawTextRight(img, fonts.count, cardText, cardWidth-cardPadding, y, formatThousands(int(math.Round(aggregate.Value))))
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func drawText(img draw.Image, face font.Face, c color.Color, x, y int, text string) {
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(text)
+}
+
+func drawTextRight(img draw.Image, face font.Face, c color.Color, right, y int, text string) {
+	drawText(img, face, c, right-font.MeasureString(face, text).Ceil(), y, text)
+}
+
+// fitText shortens text with an ellipsis until it fits in width pixels.
+func fitText(face font.Face, text string, width int) string {
+	if font.MeasureString(face, text).Ceil() <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if candidate := string(runes) + "…"; font.MeasureString(face, candidate).Ceil() <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// formatThousands renders 1234567 as "1,234,567".
+func formatThousands(n int) string {
+	digits := strconv.Itoa(n)
+	if n < 0 {
+		return "-" + formatThousands(-n)
+	}
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return digits
+}
+
+// sendReportCard posts the report as an image card with the text report as
+// a fallback when rendering fails.
+func sendReportCard(allStats []BotStats, network *NetworkStats, destination Destination, message string) ([]*discordgo.Message, error) {
+	card, err := renderReportCard(allStats, network, destination)
+	if err != nil {
+		log.Printf("Error rendering report card, sending text instead: %v", err)
+		return sendMessages(destination.ChannelID, splitMessage(message))
+	}
+	if dryRun("discord") {
+		return []*discordgo.Message{dryRunMessage(destination.ChannelID, fmt.Sprintf("[report card, %d bytes]", len(card)))}, nil
+	}
+
+	channelID, err := resolveChannel(destination.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSendPermissions(channelID); err != nil {
+		return nil, err
+	}
+
+	sent, err := session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Files: []*discordgo.File{{Name: "report.png", ContentType: "image/png", Reader: bytes.NewReader(card)}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []*discordgo.Message{sent}, nil
+}
+
+// editReportCard replaces the image of a sent report card.
+func editReportCard(report *sentReport, allStats []BotStats, network *NetworkStats) error {
+	card, err := renderReportCard(allStats, network, report.Destination)
+	if err != nil {
+		return err
+	}
+
+	sent := report.Messages[0]
+	if sent.ID == dryRunMessageID {
+		report.Messages[0] = dryRunMessage(sent.ChannelID, fmt.Sprintf("[report card, %d bytes]", len(card)))
+		return nil
+	}
+	edited, err := session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:          sent.ID,
+		Channel:     sent.ChannelID,
+		Files:       []*discordgo.File{{Name: "report.png", ContentType: "image/png", Reader: bytes.NewReader(card)}},
+		Attachments: &[]*discordgo.MessageAttachment{},
+	})
+	if err != nil {
+		return err
+	}
+	report.Messages[0] = edited
+	return nil
+}
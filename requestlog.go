@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestLogSize is how many recent requests are kept in memory.
+const requestLogSize = 500
+
+// RequestRecord describes one outbound HTTP request.
+type RequestRecord struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Host       string    `json:"host"`
+	Status     int       `json:"status,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	BotID      string    `json:"bot_id,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type botIDKey struct{}
+
+// tagBot attaches the bot a request is made for, for the request log.
+func tagBot(req *http.Request, botID string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), botIDKey{}, botID))
+}
+
+var (
+	requestLogMu sync.Mutex
+	recentReqs   []RequestRecord
+	requestQueue chan RequestRecord // Feeds the REQUEST_LOG file writer
+	droppedReqs  int
+)
+
+// loggingTransport records every request that goes through it.
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+func (t loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	record := RequestRecord{
+		Time:       start,
+		Method:     req.Method,
+		Host:       req.URL.Host,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if botID, ok := req.Context().Value(botIDKey{}).(string); ok {
+		record.BotID = botID
+	}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record.Status = resp.StatusCode
+	}
+	logRequest(record)
+
+	return resp, err
+}
+
+// setupRequestLog routes every HTTP client that uses the default transport,
+// discordgo's included, through the request log. Writes to REQUEST_LOG go
+// through a bounded queue and are dropped when the disk can't keep up, so
+// a slow disk never slows down fetching.
+func setupRequestLog() {
+	http.DefaultTransport = loggingTransport{base: http.DefaultTransport}
+
+	if config.RequestLog == "" {
+		return
+	}
+
+	file, err := os.OpenFile(config.RequestLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Fatalf("Error opening REQUEST_LOG: %v", err)
+	}
+
+	requestQueue = make(chan RequestRecord, 1000)
+	go func() {
+		encoder := json.NewEncoder(file)
+		for record := range requestQueue {
+			if err := encoder.Encode(record); err != nil {
+				log.Printf("Error writing request log: %v", err)
+			}
+		}
+	}()
+}
+
+func logRequest(record RequestRecord) {
+	requestLogMu.Lock()
+	recentReqs = append(recentReqs, record)
+	if len(recentReqs) > requestLogSize {
+		recentReqs = recentReqs[len(recentReqs)-requestLogSize:]
+	}
+	requestLogMu.Unlock()
+
+	if requestQueue == nil {
+		return
+	}
+	select {
+	case requestQueue <- record:
+	default:
+		requestLogMu.Lock()
+		droppedReqs++
+		requestLogMu.Unlock()
+	}
+}
+
+// requestSummary aggregates the recent requests per host.
+func requestSummary() string {
+	requestLogMu.Lock()
+	defer requestLogMu.Unlock()
+
+	if len(recentReqs) == 0 {
+		return "まだリクエストはありません"
+	}
+
+	type hostStats struct {
+		count, failures int
+		total, max      int64
+	}
+	byHost := make(map[string]*hostStats)
+	for _, record := range recentReqs {
+		stats, exists := byHost[record.Host]
+		if !exists {
+			stats = &hostStats{}
+			byHost[record.Host] = stats
+		}
+		stats.count++
+		if record.Error != "" || record.Status >= 400 {
+			stats.failures++
+		}
+		stats.total += record.DurationMS
+		if record.DurationMS > stats.max {
+			stats.max = record.DurationMS
+		}
+	}
+
+	hosts := make([]string, 0, len(byHost))
+	for host := range byHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🌐 **直近%d件のリクエスト**\n", len(recentReqs))
+	for _, host := range hosts {
+		stats := byHost[host]
+		fmt.Fprintf(&b, "%s: %d件, 失敗 %d件, 平均 %dms / 最大 %dms\n",
+			host, stats.count, stats.failures, stats.total/int64(stats.count), stats.max)
+	}
+	if droppedReqs > 0 {
+		fmt.Fprintf(&b, "REQUEST_LOGへの書き込みが追いつかず%d件を破棄しました\n", droppedReqs)
+	}
+	return b.String()
+}
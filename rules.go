@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ruleMetrics are the per-bot values rules can test, and whether the value
+// is known for this run.
+var ruleMetrics = map[string]func(stats BotStats) (float64, bool){
+	"server_count": func(s BotStats) (float64, bool) { return float64(s.ServerCount), true },
+	"change":       func(s BotStats) (float64, bool) { return float64(s.Change), s.HasChange },
+	"change_percent": func(s BotStats) (float64, bool) {
+		previous := s.ServerCount - s.Change
+		if !s.HasChange || previous == 0 {
+			return 0, false
+		}
+		return float64(s.Change) * 100 / float64(previous), true
+	},
+	"user_installs": func(s BotStats) (float64, bool) { return float64(s.UserInstalls), s.HasUserInstalls },
+	"patrons":       func(s BotStats) (float64, bool) { return float64(s.Patrons), s.HasPatrons },
+	"votes":         func(s BotStats) (float64, bool) { return float64(totalVotes(s.Votes)), len(s.Votes) > 0 },
+	"stars": func(s BotStats) (float64, bool) {
+		if s.Repo == nil {
+			return 0, false
+		}
+		return float64(s.Repo.Stars), true
+	},
+	"open_issues": func(s BotStats) (float64, bool) {
+		if s.Repo == nil {
+			return 0, false
+		}
+		return float64(s.Repo.OpenIssues), true
+	},
+}
+
+var ruleOperators = map[string]func(a, b float64) bool{
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// AlertRule is one entry of the rules file.
+type AlertRule struct {
+	Name         string   `yaml:"name"`
+	Bots         []string `yaml:"bots"`         // Optional: bot IDs the rule applies to (all when empty)
+	Metric       string   `yaml:"metric"`       // One of ruleMetrics, or a per-bot computed metric
+	Condition    string   `yaml:"condition"`    // Operator and value, e.g. "< 1000"
+	For          string   `yaml:"for"`          // Optional: how long the condition must hold, e.g. "2h"
+	Severity     string   `yaml:"severity"`     // info, warn or critical
+	Destinations []string `yaml:"destinations"` // Optional: ALERT_ROUTES tokens (the severity's routes when empty)
+	Message      string   `yaml:"message"`      // Optional: alert text
+
+	compare   func(a, b float64) bool
+	threshold float64
+	window    time.Duration
+	severity  Severity
+	route     *AlertRoute
+}
+
+type rulesFile struct {
+	Rules []AlertRule `yaml:"rules"`
+}
+
+// ruleState tracks one rule for one bot between runs.
+type ruleState struct {
+	Since  time.Time // When the condition started holding
+	Firing bool
+}
+
+var (
+	rulesMu     sync.Mutex
+	alertRules  []AlertRule
+	ruleStates  = make(map[string]*ruleState)
+	rulesLoaded time.Time
+)
+
+// loadRules reads and validates RULES_FILE. Every problem in the file is
+// reported at once; the caller decides whether that is fatal.
+func loadRules(path string) ([]AlertRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file rulesFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, err
+	}
+
+	var problems configProblems
+	names := make(map[string]bool)
+	for i := range file.Rules {
+		rule := &file.Rules[i]
+		field := fmt.Sprintf("rules[%d]", i)
+
+		if rule.Name == "" {
+			problems.add(field+".name", "required")
+		} else if names[rule.Name] {
+			problems.add(field+".name", "duplicate rule name")
+		}
+		names[rule.Name] = true
+
+		if computed, ok := computedMetric(rule.Metric); ok {
+			if !computed.PerBot {
+				problems.add(field+".metric", "computed metric %q is a network-wide total, not a per-bot value", rule.Metric)
+			}
+		} else if _, ok := ruleMetrics[rule.Metric]; !ok {
+			problems.add(field+".metric", "unknown metric %q (expected server_count, change, change_percent, user_installs, patrons, stars, open_issues or a computed metric)", rule.Metric)
+		}
+
+		operator, value, _ := strings.Cut(strings.TrimSpace(rule.Condition), " ")
+		rule.compare = ruleOperators[operator]
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if rule.compare == nil || err != nil {
+			problems.add(field+".condition", "%q should be an operator (<, <=, >, >=, ==, !=) and a number, e.g. \"< 1000\"", rule.Condition)
+		}
+		rule.threshold = threshold
+
+		if rule.For != "" {
+			if rule.window, err = time.ParseDuration(rule.For); err != nil || rule.window < 0 {
+				problems.add(field+".for", "%q is not a duration like 30m or 2h", rule.For)
+			}
+		}
+
+		if rule.severity, err = parseSeverity(rule.Severity); err != nil {
+			problems.add(field+".severity", "%v", err)
+		}
+
+		for _, id := range rule.Bots {
+			if !isTargetBot(id) {
+				problems.add(field+".bots", "%s is not in TARGET_BOT_IDS", id)
+			}
+		}
+
+		if len(rule.Destinations) > 0 {
+			route, unknown := parseAlertRoute(rule.Destinations)
+			for _, destination := range unknown {
+				problems.add(field+".destinations", "unknown destination %q", destination)
+			}
+			rule.route = &route
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New("\n  " + strings.Join(problems, "\n  "))
+	}
+	return file.Rules, nil
+}
+
+// setupRules loads RULES_FILE at startup, exiting on any problem, and
+// reloads it whenever it changes. A broken edit is logged and the previous
+// rules stay active.
+func setupRules() {
+	if config.RulesFile == "" {
+		return
+	}
+
+	rules, err := loadRules(config.RulesFile)
+	if err != nil {
+		log.Fatalf("Invalid RULES_FILE %s: %v", config.RulesFile, err)
+	}
+	setRules(rules)
+
+	go func() {
+		for range time.Tick(30 * time.Second) {
+			info, err := os.Stat(config.RulesFile)
+			if err != nil {
+				log.Printf("Error checking RULES_FILE: %v", err)
+				continue
+			}
+
+			rulesMu.Lock()
+			changed := info.ModTime().After(rulesLoaded)
+			rulesMu.Unlock()
+			if !changed {
+				continue
+			}
+
+			rules, err := loadRules(config.RulesFile)
+			if err != nil {
+				log.Printf("Not reloading RULES_FILE, keeping the previous rules: %v", err)
+				rulesMu.Lock()
+				rulesLoaded = info.ModTime()
+				rulesMu.Unlock()
+				continue
+			}
+			setRules(rules)
+		}
+	}()
+}
+
+func setRules(rules []AlertRule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+
+	alertRules = rules
+	rulesLoaded = time.Now()
+
+	// Forget the state of rules that no longer exist
+	names := make(map[string]bool)
+	for _, rule := range rules {
+		names[rule.Name] = true
+	}
+	for key := range ruleStates {
+		name, _, _ := strings.Cut(key, "/")
+		if !names[name] {
+			delete(ruleStates, key)
+		}
+	}
+	log.Printf("Loaded %d alert rules from %s", len(rules), config.RulesFile)
+}
+
+// evaluateRules raises an alert when a rule's condition has held for its
+// window, and an info alert to the same destinations once it clears.
+func evaluateRules(allStats []BotStats) {
+	rulesMu.Lock()
+	var alerts []Alert
+	now := time.Now()
+
+	for _, rule := range alertRules {
+		for _, stats := range allStats {
+			if stats.Error != nil || stats.Pending || (len(rule.Bots) > 0 && !contains(rule.Bots, stats.BotID)) {
+				continue
+			}
+			value, known := ruleValue(rule.Metric, stats)
+			if !known {
+				continue
+			}
+
+			key := rule.Name + "/" + stats.BotID
+			state := ruleStates[key]
+			if state == nil {
+				state = &ruleState{}
+				ruleStates[key] = state
+			}
+
+			if rule.compare(value, rule.threshold) {
+				if state.Since.IsZero() {
+					state.Since = now
+				}
+				if !state.Firing && now.Sub(state.Since) >= rule.window {
+					state.Firing = true
+					alerts = append(alerts, ruleAlert(rule, stats, rule.severity, ruleMessage(rule, value)))
+				}
+			} else {
+				if state.Firing {
+					alerts = append(alerts, ruleAlert(rule, stats, SeverityInfo, fmt.Sprintf("ルール %s が解消しました (%s = %s)", rule.Name, rule.Metric, formatRuleValue(value))))
+				}
+				*state = ruleState{}
+			}
+		}
+	}
+	rulesMu.Unlock()
+
+	for _, alert := range alerts {
+		raiseAlert(alert)
+	}
+}
+
+func ruleAlert(rule AlertRule, stats BotStats, severity Severity, message string) Alert {
+	alert := Alert{
+		Severity: severity,
+		BotID:    stats.BotID,
+		BotName:  stats.BotName,
+		Message:  message,
+		Rule:     rule.Name,
+	}
+	if rule.route != nil {
+		// Resolutions go where the alert went, whatever the info routes are
+		alert.Notifiers = buildNotifiers(map[Severity]AlertRoute{severity: *rule.route})
+	}
+	return alert
+}
+
+func ruleMessage(rule AlertRule, value float64) string {
+	if rule.Message != "" {
+		return rule.Message
+	}
+	message := fmt.Sprintf("ルール %s: %s が %s (%s)", rule.Name, rule.Metric, formatRuleValue(value), rule.Condition)
+	if rule.window > 0 {
+		message += fmt.Sprintf("、%s以上継続", rule.For)
+	}
+	return message
+}
+
+// ruleValue is a built-in metric of the bot, or one of its computed metrics.
+func ruleValue(metric string, stats BotStats) (float64, bool) {
+	if value, ok := ruleMetrics[metric]; ok {
+		return value(stats)
+	}
+	value, known := stats.Computed[metric]
+	return value, known
+}
+
+func formatRuleValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
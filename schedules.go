@@ -0,0 +1,381 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	reportSchedulePrefix     = "REPORT_SCHEDULE_"
+	reportFormatPrefix       = "REPORT_FORMAT_"
+	reportDestinationsPrefix = "REPORT_DESTINATIONS_"
+)
+
+// Report formats of a schedule.
+const (
+	reportFull    = "full"    // The regular report
+	reportCompact = "compact" // One line with every bot's count and change
+	reportSummary = "summary" // Changes since the schedule's previous run
+)
+
+var reportFormats = []string{reportFull, reportCompact, reportSummary}
+
+// ReportSchedule is a named report read from REPORT_SCHEDULE_<NAME>, with
+// its format in REPORT_FORMAT_<NAME> and its destinations in
+// REPORT_DESTINATIONS_<NAME> (the REPORT_DESTINATIONS ones when unset).
+// Several schedules run side by side, e.g. an hourly compact report in an
+// ops channel next to the daily report and a weekly summary.
+type ReportSchedule struct {
+	Name         string
+	When         string // Preset (hourly, daily, weekly, monthly), HH:MM or cron expression
+	Expr         string // Cron expression the schedule runs at
+	Format       string
+	Destinations []Destination
+}
+
+// schedulePeriod is what a named schedule's reports compare against: the
+// counts at the schedule's previous run. Changes in its reports and its
+// summaries are relative to them, not to whichever schedule fetched last.
+// The counts of the current run become the next period's baseline once the
+// run is over, so late edits of a report still compare against the same
+// baseline.
+type schedulePeriod struct {
+	Start      time.Time      `json:"start"`                 // When the baseline counts were taken
+	Counts     map[string]int `json:"counts"`                // Bot ID -> Count at the start of the period
+	NextStart  time.Time      `json:"next_start,omitempty"`  // When the current run's counts were taken
+	NextCounts map[string]int `json:"next_counts,omitempty"` // Bot ID -> Count at the current run
+}
+
+// loadReportSchedules reads every REPORT_SCHEDULE_<NAME> in name order.
+// Invalid schedules are reported by validateEnv before this runs.
+func loadReportSchedules() []ReportSchedule {
+	var schedules []ReportSchedule
+	for name, when := range getEnvPerBot(reportSchedulePrefix) {
+		expr, err := scheduleExpr(when, config.NotificationTime)
+		if err != nil {
+			continue
+		}
+
+		schedule := ReportSchedule{
+			Name:         strings.ToLower(name),
+			When:         when,
+			Expr:         expr,
+			Format:       getEnvDefault(reportFormatPrefix+name, reportFull),
+			Destinations: config.Destinations,
+		}
+		if value := os.Getenv(reportDestinationsPrefix + name); value != "" {
+			schedule.Destinations = parseDestinations(value)
+		}
+
+		destinations := make([]Destination, len(schedule.Destinations))
+		for i, destination := range schedule.Destinations {
+			destination.Schedule = schedule.Name
+			destination.Format = schedule.Format
+			destinations[i] = destination
+		}
+		schedule.Destinations = destinations
+		schedules = append(schedules, schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Name < schedules[j].Name })
+
+	if len(schedules) > 0 {
+		log.Printf("Configured %d report schedules", len(schedules))
+	}
+	return schedules
+}
+
+// scheduleExpr turns a schedule into a cron expression. daily runs at
+// NOTIFICATION_TIME, and weekly (Mondays) and monthly (the 1st) at the same
+// time of day. Anything else is HH:MM or a cron expression.
+func scheduleExpr(when, notificationTime string) (string, error) {
+	daily := toCronExpr(notificationTime)
+	fields := strings.Fields(daily)
+	n := len(fields)
+
+	var expr string
+	switch when {
+	case "hourly":
+		expr = "0 * * * *"
+	case "daily":
+		expr = daily
+	case "weekly", "monthly":
+		if n < 5 {
+			return "", fmt.Errorf("NOTIFICATION_TIME %q has no time of day to run %s at", notificationTime, when)
+		}
+		if when == "weekly" {
+			fields[n-3], fields[n-2], fields[n-1] = "*", "*", "1"
+		} else {
+			fields[n-3], fields[n-2], fields[n-1] = "1", "*", "*"
+		}
+		expr = strings.Join(fields, " ")
+	default:
+		expr = toCronExpr(when)
+	}
+
+	if _, err := cron.ParseStandard(expr); err != nil {
+		return "", fmt.Errorf("%q is not hourly, daily, weekly, monthly, HH:MM or a cron expression: %v", when, err)
+	}
+	return expr, nil
+}
+
+// validateReportSchedules checks every REPORT_SCHEDULE_<NAME> with its
+// format and destinations, and the formats and destinations of schedules
+// that don't exist.
+func validateReportSchedules(problems *configProblems) {
+	schedules := make(map[string]bool)
+	var names []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if schedule, found := strings.CutPrefix(name, reportSchedulePrefix); found && schedule != "" {
+			schedules[schedule] = true
+		}
+		if strings.HasPrefix(name, reportSchedulePrefix) || strings.HasPrefix(name, reportFormatPrefix) || strings.HasPrefix(name, reportDestinationsPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	notificationTime := getEnvDefault("NOTIFICATION_TIME", "09:00")
+	for _, name := range names {
+		value := strings.TrimSpace(os.Getenv(name))
+		switch {
+		case strings.HasPrefix(name, reportSchedulePrefix):
+			if _, err := scheduleExpr(value, notificationTime); err != nil {
+				problems.add(name, "%v", err)
+			}
+		case !schedules[scheduleName(name)]:
+			problems.add(name, "there is no %s%s to apply it to", reportSchedulePrefix, scheduleName(name))
+		case strings.HasPrefix(name, reportFormatPrefix):
+			if value != "" && !contains(reportFormats, value) {
+				problems.add(name, "%q must be one of %s", value, strings.Join(reportFormats, ", "))
+			}
+		default:
+			validateDestinationList(problems, name)
+		}
+	}
+}
+
+// scheduleName returns the <NAME> of a REPORT_FORMAT_<NAME> or
+// REPORT_DESTINATIONS_<NAME> variable.
+func scheduleName(variable string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(variable, reportFormatPrefix), reportDestinationsPrefix)
+}
+
+// periodKey identifies the period of a destination: the schedule and
+// timezone group that runs it.
+func periodKey(destination Destination) string {
+	return destination.Schedule + "/" + destination.Location.String()
+}
+
+// startSchedulePeriod makes the counts of the group's previous run the
+// baseline of the run that is starting.
+func startSchedulePeriod(group string) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	period := delivered.Periods[group]
+	if period == nil || period.NextCounts == nil {
+		return
+	}
+	period.Start, period.Counts = period.NextStart, period.NextCounts
+	period.NextStart, period.NextCounts = time.Time{}, nil
+}
+
+// finishSchedulePeriod records the latest counts for the group's next run.
+func finishSchedulePeriod(group string) {
+	counts := make(map[string]int)
+	for _, botID := range config.TargetBotIDs {
+		if sample, known := latestSampleOf(botID); known {
+			counts[botID] = sample.ServerCount
+		}
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if delivered.Periods == nil {
+		delivered.Periods = make(map[string]*schedulePeriod)
+	}
+	if period := delivered.Periods[group]; period != nil {
+		period.NextStart, period.NextCounts = time.Now(), counts
+	} else {
+		// The first run has nothing to compare with, so its counts are the
+		// baseline straight away
+		delivered.Periods[group] = &schedulePeriod{Start: time.Now(), Counts: counts}
+	}
+
+	if config.StateFile != "" && !dryRun("discord") {
+		if err := saveState(); err != nil {
+			log.Printf("Error saving STATE_FILE: %v", err)
+		}
+	}
+}
+
+func periodOf(destination Destination) (schedulePeriod, bool) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	period, exists := delivered.Periods[periodKey(destination)]
+	if !exists {
+		return schedulePeriod{}, false
+	}
+	return *period, true
+}
+
+// reportBaseline returns the counts the next report to the destinations
+// compares against: those of the schedule's previous run, also when the
+// run hasn't started yet (a preview, or one published early). The report
+// at NOTIFICATION_TIME has no baseline of its own and keeps the change
+// since the previous fetch.
+func reportBaseline(destinations []Destination) map[string]int {
+	if len(destinations) == 0 || destinations[0].Schedule == "" {
+		return nil
+	}
+	period, known := periodOf(destinations[0])
+	switch {
+	case !known:
+		// The schedule's first run shows no changes
+		return map[string]int{}
+	case period.NextCounts != nil:
+		return period.NextCounts
+	default:
+		return period.Counts
+	}
+}
+
+// applyBaseline replaces each bot's change with its change since the
+// baseline counts. A nil baseline keeps the change since the previous fetch.
+func applyBaseline(allStats []BotStats, baseline map[string]int) {
+	if baseline == nil {
+		return
+	}
+	for i := range allStats {
+		stats := &allStats[i]
+		previous, known := baseline[stats.BotID]
+		if stats.Error != nil || stats.Pending || !known {
+			stats.Change, stats.HasChange = 0, false
+			continue
+		}
+		stats.Change, stats.HasChange = stats.ServerCount-previous, true
+	}
+}
+
+// buildCompactMessage renders every bot on a single line with its change
+// since the previous run, for frequent reports in busy channels.
+func buildCompactMessage(allStats []BotStats, destination Destination) string {
+	parts := []string{"⏰" + time.Now().In(destination.Location).Format("15:04")}
+	total := 0
+	for _, stats := range visibleStats(allStats, destination) {
+		name := stats.BotName
+		if name == "Unknown" || name == "" {
+			name = stats.BotID
+		}
+
+		switch {
+		case stats.Pending:
+			parts = append(parts, name+" ⏳")
+		case stats.Error != nil:
+			parts = append(parts, name+" ❌")
+		default:
+			part := fmt.Sprintf("%s **%d**", name, stats.ServerCount)
+			if stats.HasChange && stats.Change != 0 {
+				part += fmt.Sprintf(" (%+d)", stats.Change)
+			}
+			parts = append(parts, part)
+			if !config.PrivateBots[stats.BotID] {
+				total += stats.ServerCount
+			}
+		}
+	}
+	if aggregates := aggregateValues(allStats, destination); len(aggregates) > 0 {
+		for _, aggregate := range aggregates {
+			parts = append(parts, aggregate.String())
+		}
+	} else {
+		parts = append(parts, fmt.Sprintf("Σ **%d**", total))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// buildSummaryMessage renders how each bot changed since the schedule's
+// previous run, largest growth first.
+func buildSummaryMessage(allStats []BotStats, destination Destination) string {
+	period, known := periodOf(destination)
+	var message string
+	if known {
+		message = translate(destination.Language, "summary", period.Start.In(destination.Location).Format("2006-01-02 15:04"))
+	} else {
+		message = translate(destination.Language, "summary_first")
+	}
+
+	type botChange struct {
+		stats   BotStats
+		change  int
+		changed bool
+	}
+	var changes []botChange
+	for _, stats := range visibleStats(allStats, destination) {
+		entry := botChange{stats: stats}
+		if previous, exists := period.Counts[stats.BotID]; exists && stats.Error == nil && !stats.Pending {
+			entry.change, entry.changed = stats.ServerCount-previous, true
+		}
+		changes = append(changes, entry)
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].changed != changes[j].changed {
+			return changes[i].changed
+		}
+		return changes[i].change > changes[j].change
+	})
+
+	total, totalChange := 0, 0
+	for _, entry := range changes {
+		stats := entry.stats
+		name := stats.BotName
+		if name == "Unknown" || name == "" {
+			name = stats.BotID
+		}
+
+		var value string
+		switch {
+		case stats.Pending:
+			value = translate(destination.Language, "pending")
+		case stats.Error != nil:
+			value = describeError(destination.Language, stats.Error)
+		default:
+			value = fmt.Sprintf("**%d**", stats.ServerCount)
+			if entry.changed {
+				value += fmt.Sprintf(" (%+d", entry.change)
+				if previous := stats.ServerCount - entry.change; previous > 0 {
+					value += fmt.Sprintf(", %+.1f%%", float64(entry.change)*100/float64(previous))
+				}
+				value += ")"
+			}
+			if !config.PrivateBots[stats.BotID] {
+				total += stats.ServerCount
+				totalChange += entry.change
+			}
+		}
+		message += "\n" + name + " : " + value
+	}
+
+	// Configured aggregates replace the plain sum, which double-counts
+	// servers shared by several bots
+	if aggregates := aggregateValues(allStats, destination); len(aggregates) > 0 {
+		for _, aggregate := range aggregates {
+			message += "\n" + aggregate.String()
+		}
+		return message
+	}
+	message += "\n" + translate(destination.Language, "summary_total", total)
+	if known {
+		message += fmt.Sprintf(" (%+d)", totalChange)
+	}
+	return message
+}
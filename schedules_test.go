@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestApplyBaseline(t *testing.T) {
+	fetched := func() []BotStats {
+		return []BotStats{
+			{BotID: "1", ServerCount: 120, Change: 2, HasChange: true},
+			{BotID: "2", ServerCount: 50, Change: 1, HasChange: true},
+			{BotID: "3", Error: errors.New("down")},
+		}
+	}
+	tests := []struct {
+		name     string
+		baseline map[string]int
+		want     []int // Change of each bot, or -1 for none
+	}{
+		{"report at NOTIFICATION_TIME", nil, []int{2, 1, -1}},
+		{"schedule's first run", map[string]int{}, []int{-1, -1, -1}},
+		{"since the schedule's previous run", map[string]int{"1": 100, "3": 10}, []int{20, -1, -1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			allStats := fetched()
+			applyBaseline(allStats, test.baseline)
+			for i, stats := range allStats {
+				got := -1
+				if stats.HasChange {
+					got = stats.Change
+				}
+				if got != test.want[i] {
+					t.Errorf("bot %s: change %d, want %d", stats.BotID, got, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+// Schedules due at the same time share one fetch, and the stats each run
+// gets are its own.
+func TestCollectTickFetchesOnce(t *testing.T) {
+	fakeDiscord(t, 0)
+	var requests atomic.Int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"server_count": 100}`))
+	}))
+	t.Cleanup(webhook.Close)
+
+	config.TargetBotIDs = []string{"1", "2"}
+	config.CustomWebhooks = map[string]string{"1": webhook.URL, "2": webhook.URL}
+
+	tick := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	runs := make([][]BotStats, 3)
+	for i := range runs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			runs[i], _, _ = collectTick(tick)
+		}(i)
+	}
+	wg.Wait()
+
+	if got := requests.Load(); got != 2 {
+		t.Errorf("three runs at one tick made %d requests, want 2", got)
+	}
+	runs[0][0].ServerCount = 1
+	if runs[1][0].ServerCount != 100 {
+		t.Errorf("runs share their stats")
+	}
+
+	collectTick(tick.Add(time.Hour))
+	if got := requests.Load(); got != 4 {
+		t.Errorf("the next tick made %d requests in total, want 4", got)
+	}
+}
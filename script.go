@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// reportScriptTimeout bounds a report script run, so a script that never
+// returns can't hold up reports and late-result patches.
+var reportScriptTimeout = 10 * time.Second
+
+// applyReportScript runs the configured Lua script's transform(bots) function
+// over the fetched stats and returns the array it gives back, in the same
+// shape. Each bot is a table with:
+//
+//   - id, name, server_count, source, estimated, error and pending
+//   - change, when the previous count is known
+//   - day_change and week_change, when HISTORY_DB has a count from then
+//   - user_installs, patrons, stars and open_issues, when fetched
+//   - computed: the bot's computed metrics
+//   - sources: the count from every source that answered
+//
+// Returned tables may also carry a "fields" table whose entries are rendered
+// next to the server count.
+func applyReportScript(allStats []BotStats) ([]BotStats, error) {
+	L := lua.NewState()
+	defer L.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), reportScriptTimeout)
+	defer cancel()
+	L.SetContext(ctx)
+
+	if err := L.DoFile(config.ReportScript); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("report script did not finish loading within %v", reportScriptTimeout)
+		}
+		return nil, fmt.Errorf("failed to load report script: %v", err)
+	}
+
+	transform := L.GetGlobal("transform")
+	if transform.Type() != lua.LTFunction {
+		return nil, fmt.Errorf("report script must define a transform(bots) function")
+	}
+
+	input := L.NewTable()
+	fetchErrors := make(map[string]error)
+	for _, stats := range allStats {
+		input.Append(botStatsToLua(L, stats))
+		if stats.Error != nil {
+			fetchErrors[stats.BotID] = stats.Error
+		}
+	}
+
+	if err := L.CallByParam(lua.P{Fn: transform, NRet: 1, Protect: true}, input); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("report script did not finish within %v", reportScriptTimeout)
+		}
+		return nil, fmt.Errorf("report script failed: %v", err)
+	}
+
+	ret := L.Get(-1)
+	L.Pop(1)
+
+	output, ok := ret.(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("transform must return a table, got %s", ret.Type())
+	}
+
+	var result []BotStats
+	for i := 1; i <= output.Len(); i++ {
+		entry, ok := output.RawGetInt(i).(*lua.LTable)
+		if !ok {
+			return nil, fmt.Errorf("transform result entry %d is not a table", i)
+		}
+		stats := botStatsFromLua(entry)
+		// Keep the typed error unless the script changed it, so reports
+		// still explain the failure
+		if original, exists := fetchErrors[stats.BotID]; exists && stats.Error != nil && stats.Error.Error() == original.Error() {
+			stats.Error = original
+		}
+		result = append(result, stats)
+	}
+
+	return result, nil
+}
+
+func botStatsToLua(L *lua.LState, stats BotStats) *lua.LTable {
+	t := L.NewTable()
+	t.RawSetString("id", lua.LString(stats.BotID))
+	t.RawSetString("name", lua.LString(stats.BotName))
+	t.RawSetString("server_count", lua.LNumber(stats.ServerCount))
+	t.RawSetString("source", lua.LString(stats.Source))
+	t.RawSetString("estimated", lua.LBool(stats.Estimated))
+	t.RawSetString("pending", lua.LBool(stats.Pending))
+	if stats.Error != nil {
+		t.RawSetString("error", lua.LString(stats.Error.Error()))
+	}
+	if stats.HasChange {
+		t.RawSetString("change", lua.LNumber(stats.Change))
+	}
+	if stats.HasDayChange {
+		t.RawSetString("day_change", lua.LNumber(stats.DayChange))
+	}
+	if stats.HasWeekChange {
+		t.RawSetString("week_change", lua.LNumber(stats.WeekChange))
+	}
+	if stats.HasUserInstalls {
+		t.RawSetString("user_installs", lua.LNumber(stats.UserInstalls))
+	}
+	if stats.HasPatrons {
+		t.RawSetString("patrons", lua.LNumber(stats.Patrons))
+	}
+	if stats.Repo != nil {
+		t.RawSetString("stars", lua.LNumber(stats.Repo.Stars))
+		t.RawSetString("open_issues", lua.LNumber(stats.Repo.OpenIssues))
+	}
+	if len(stats.Computed) > 0 {
+		computed := L.NewTable()
+		for name, value := range stats.Computed {
+			computed.RawSetString(name, lua.LNumber(value))
+		}
+		t.RawSetString("computed", computed)
+	}
+	if len(stats.Sources) > 0 {
+		sources := L.NewTable()
+		for name, count := range stats.Sources {
+			sources.RawSetString(name, lua.LNumber(count))
+		}
+		t.RawSetString("sources", sources)
+	}
+	return t
+}
+
+func botStatsFromLua(t *lua.LTable) BotStats {
+	stats := BotStats{
+		BotID:   lua.LVAsString(t.RawGetString("id")),
+		BotName: lua.LVAsString(t.RawGetString("name")),
+		Source:  lua.LVAsString(t.RawGetString("source")),
+	}
+	stats.Estimated = lua.LVAsBool(t.RawGetString("estimated"))
+	stats.Pending = lua.LVAsBool(t.RawGetString("pending"))
+
+	if count, ok := t.RawGetString("server_count").(lua.LNumber); ok {
+		stats.ServerCount = int(count)
+	}
+
+	if change, ok := t.RawGetString("change").(lua.LNumber); ok {
+		stats.Change = int(change)
+		stats.HasChange = true
+	}
+
+	if change, ok := t.RawGetString("day_change").(lua.LNumber); ok {
+		stats.DayChange = int(change)
+		stats.HasDayChange = true
+	}
+
+	if change, ok := t.RawGetString("week_change").(lua.LNumber); ok {
+		stats.WeekChange = int(change)
+		stats.HasWeekChange = true
+	}
+
+	if installs, ok := t.RawGetString("user_installs").(lua.LNumber); ok {
+		stats.UserInstalls = int(installs)
+		stats.HasUserInstalls = true
+	}
+
+	if patrons, ok := t.RawGetString("patrons").(lua.LNumber); ok {
+		stats.Patrons = int(patrons)
+		stats.HasPatrons = true
+	}
+
+	if stars, ok := t.RawGetString("stars").(lua.LNumber); ok {
+		issues, _ := t.RawGetString("open_issues").(lua.LNumber)
+		stats.Repo = &GitHubRepo{Stars: int(stars), OpenIssues: int(issues)}
+	}
+
+	if errMsg := lua.LVAsString(t.RawGetString("error")); errMsg != "" {
+		stats.Error = errors.New(errMsg)
+	}
+
+	if computed, ok := t.RawGetString("computed").(*lua.LTable); ok {
+		stats.Computed = make(map[string]float64)
+		computed.ForEach(func(key, value lua.LValue) {
+			if number, ok := value.(lua.LNumber); ok {
+				stats.Computed[lua.LVAsString(key)] = float64(number)
+			}
+		})
+	}
+
+	if sources, ok := t.RawGetString("sources").(*lua.LTable); ok {
+		stats.Sources = make(map[string]int)
+		sources.ForEach(func(key, value lua.LValue) {
+			if number, ok := value.(lua.LNumber); ok {
+				stats.Sources[lua.LVAsString(key)] = int(number)
+			}
+		})
+	}
+
+	if fields, ok := t.RawGetString("fields").(*lua.LTable); ok {
+		stats.Fields = make(map[string]string)
+		fields.ForEach(func(key, value lua.LValue) {
+			stats.Fields[lua.LVAsString(key)] = lua.LVAsString(value)
+		})
+	}
+
+	return stats
+}
+
+// formatFields renders script-provided fields in a stable order.
+func formatFields(fields map[string]string) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var out string
+	for _, key := range keys {
+		out += fmt.Sprintf(" | %s: %s", key, fields[key])
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyReportScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		want    string // Bot names after the script, joined with commas
+		wantErr string
+	}{
+		{"renames", `function transform(bots) for _, bot in ipairs(bots) do bot.name = "★ " .. bot.name end return bots end`, "★ a,★ b", ""},
+		{"filters", `function transform(bots) return { bots[2] } end`, "b", ""},
+		{"missing transform", `x = 1`, "", "must define a transform(bots) function"},
+		{"runtime error", `function transform(bots) error("boom") end`, "", "boom"},
+		{"endless transform", `function transform(bots) while true do end end`, "", "did not finish within"},
+		{"endless load", `while true do end`, "", "did not finish loading within"},
+	}
+
+	previousConfig, previousTimeout := config, reportScriptTimeout
+	reportScriptTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { config, reportScriptTimeout = previousConfig, previousTimeout })
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.ReportScript = filepath.Join(t.TempDir(), "report.lua")
+			if err := os.WriteFile(config.ReportScript, []byte(test.script), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			result, err := applyReportScript([]BotStats{{BotID: "1", BotName: "a"}, {BotID: "2", BotName: "b"}})
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("took %v", elapsed)
+			}
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("err = %v, want one mentioning %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, stats := range result {
+				names = append(names, stats.BotName)
+			}
+			if got := strings.Join(names, ","); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// latestSample is the most recent successful count of a bot, served over
+// HTTP between runs.
+type latestSample struct {
+	BotName     string
+	ServerCount int
+	Estimated   bool
+	Computed    map[string]float64
+	Time        time.Time
+}
+
+var (
+	latestMu     sync.RWMutex
+	latestCounts = make(map[string]latestSample)
+)
+
+// updateLatest remembers the counts of the bots fetched successfully. Failed
+// and pending bots keep their previous sample.
+func updateLatest(allStats []BotStats) {
+	latestMu.Lock()
+	defer latestMu.Unlock()
+
+	for _, stats := range allStats {
+		if stats.Error != nil || stats.Pending {
+			continue
+		}
+		latestCounts[stats.BotID] = latestSample{
+			BotName:     stats.BotName,
+			ServerCount: stats.ServerCount,
+			Estimated:   stats.Estimated,
+			Computed:    stats.Computed,
+			Time:        time.Now(),
+		}
+	}
+}
+
+func latestSampleOf(botID string) (latestSample, bool) {
+	latestMu.RLock()
+	defer latestMu.RUnlock()
+	sample, known := latestCounts[botID]
+	return sample, known
+}
+
+func isTargetBot(botID string) bool {
+	for _, id := range config.TargetBotIDs {
+		if id == botID {
+			return true
+		}
+	}
+	return false
+}
+
+// startHTTPServer serves the HTTP endpoints on HTTP_ADDR in the background.
+func startHTTPServer() {
+	if config.HTTPAddr == "" || !moduleEnabled(ModuleHTTP) {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/badge/", handleBadge)
+	mux.HandleFunc("/api/counts", handlePublicCounts)
+	mux.HandleFunc("/api/counts/", handlePublicCounts)
+	mux.HandleFunc("/metrics", handleMetrics)
+	if config.ProxyToken != "" && config.TopGGToken != "" {
+		mux.HandleFunc("/proxy/topgg/", handleTopGGProxy)
+	}
+	if config.DebugToken != "" && config.CaptureDir != "" {
+		mux.HandleFunc("/api/debug", handleDebugCaptures)
+		mux.HandleFunc("/api/debug/", handleDebugCaptures)
+	}
+
+	server := &http.Server{
+		Addr:              config.HTTPAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Badges would read "unknown" until the first scheduled run otherwise
+	go func() {
+		allStats, late := fetchAllStats(config.TargetBotIDs)
+		computeMetrics(allStats)
+		updateLatest(allStats)
+		if late != nil {
+			for result := range late {
+				updateLatest([]BotStats{result.stats})
+			}
+		}
+	}()
+
+	go func() {
+		log.Printf("HTTP server listening on %s", config.HTTPAddr)
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("HTTP server stopped: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Rule names of the built-in alerts, used to silence them selectively.
+const (
+	RuleFetch       = "fetch"
+	RuleAnomaly     = "anomaly"
+	RuleBadge       = "badge"
+	RuleRemediation = "remediation"
+	RuleDuplicate   = "duplicate"
+	RuleAccount     = "account"
+	RuleScheduler   = "scheduler"
+)
+
+// silenceAll matches every bot, including alerts that aren't about one bot.
+const silenceAll = "all"
+
+// Silence mutes alerts for a bot (or every bot) until it expires, either
+// for every rule or for one.
+type Silence struct {
+	BotID string
+	Rule  string // Empty for every rule
+	Until time.Time
+	By    string
+}
+
+var (
+	silenceMu sync.Mutex
+	silences  []Silence
+)
+
+// isSilenced reports whether an active silence matches the alert. Expired
+// silences are dropped on the way.
+func isSilenced(alert Alert) bool {
+	silenceMu.Lock()
+	defer silenceMu.Unlock()
+
+	now := time.Now()
+	active := silences[:0]
+	matched := false
+	for _, silence := range silences {
+		if now.After(silence.Until) {
+			continue
+		}
+		active = append(active, silence)
+		if (silence.BotID == silenceAll || silence.BotID == alert.BotID) && (silence.Rule == "" || silence.Rule == alert.Rule) {
+			matched = true
+		}
+	}
+	silences = active
+	return matched
+}
+
+func addSilence(silence Silence) {
+	silenceMu.Lock()
+	defer silenceMu.Unlock()
+	silences = append(silences, silence)
+}
+
+func activeSilences() []Silence {
+	silenceMu.Lock()
+	defer silenceMu.Unlock()
+
+	var active []Silence
+	for _, silence := range silences {
+		if time.Now().Before(silence.Until) {
+			active = append(active, silence)
+		}
+	}
+	sort.Slice(active, func(a, b int) bool { return active[a].Until.Before(active[b].Until) })
+	return active
+}
+
+// parseSilenceDuration accepts Go durations plus whole days ("2d").
+func parseSilenceDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid duration %q (e.g. 30m, 2h, 1d)", value)
+	}
+	return duration, nil
+}
+
+// resolveBot finds a target bot by ID or by its last known name.
+func resolveBot(ref string) (string, bool) {
+	if isTargetBot(ref) {
+		return ref, true
+	}
+	for _, botID := range config.TargetBotIDs {
+		if name := botDisplayName(botID); name != "" && strings.EqualFold(name, ref) {
+			return botID, true
+		}
+	}
+	return "", false
+}
+
+func handleSilenceCommand(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	var botRef, durationValue, rule string
+	for _, option := range options {
+		switch option.Name {
+		case "bot":
+			botRef = option.StringValue()
+		case "duration":
+			durationValue = option.StringValue()
+		case "rule":
+			rule = option.StringValue()
+		}
+	}
+
+	botID := silenceAll
+	if !strings.EqualFold(botRef, silenceAll) {
+		resolved, ok := resolveBot(botRef)
+		if !ok {
+			respondEphemeral(s, i, fmt.Sprintf("bot %q は監視対象ではありません（IDまたはbot名、すべてのbotは all）", botRef))
+			return
+		}
+		botID = resolved
+	}
+
+	duration, err := parseSilenceDuration(durationValue)
+	if err != nil {
+		respondEphemeral(s, i, err.Error())
+		return
+	}
+
+	user := interactionUser(i)
+	silence := Silence{BotID: botID, Rule: rule, Until: time.Now().Add(duration)}
+	if user != nil {
+		silence.By = user.Username
+	}
+	addSilence(silence)
+	recordAudit(user, "silence", describeSilence(silence))
+
+	lines := []string{"🔕 " + describeSilence(silence) + " をミュートしました", "", "有効なミュート:"}
+	for _, active := range activeSilences() {
+		lines = append(lines, fmt.Sprintf("• %s（%s）", describeSilence(active), active.By))
+	}
+	respondEphemeral(s, i, strings.Join(lines, "\n"))
+}
+
+func describeSilence(silence Silence) string {
+	target := silence.BotID
+	if target == silenceAll {
+		target = "すべてのbot"
+	} else if sample, known := latestSampleOf(target); known && sample.BotName != "" {
+		target = sample.BotName
+	}
+
+	rule := "すべてのアラート"
+	if silence.Rule != "" {
+		rule = "ルール " + silence.Rule
+	}
+	return fmt.Sprintf("%s の%s（<t:%d:f>まで）", target, rule, silence.Until.Unix())
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxSmoothingWindow bounds the samples kept per bot and metric.
+const maxSmoothingWindow = 100
+
+// Smoothing is a rolling window applied to a metric before alerts are
+// evaluated, read from SMOOTHING=METRIC:N[:METHOD]. The method is median
+// (the default), mean, or a percentile such as p25.
+type Smoothing struct {
+	Window     int
+	Mean       bool    // Rolling average instead of a percentile
+	Percentile float64 // 50 for the median
+}
+
+// smoothedMetrics are the metrics that can be smoothed, and how to replace
+// the value in a bot's stats. change and change_percent follow server_count.
+var smoothedMetrics = map[string]func(stats *BotStats, value float64){
+	"server_count":  func(s *BotStats, v float64) { s.ServerCount = int(math.Round(v)) },
+	"user_installs": func(s *BotStats, v float64) { s.UserInstalls = int(math.Round(v)) },
+	"patrons":       func(s *BotStats, v float64) { s.Patrons = int(math.Round(v)) },
+	"stars":         func(s *BotStats, v float64) { s.Repo.Stars = int(math.Round(v)) },
+	"open_issues":   func(s *BotStats, v float64) { s.Repo.OpenIssues = int(math.Round(v)) },
+}
+
+var (
+	smoothingMu      sync.Mutex
+	smoothingSamples = make(map[string]map[string][]float64) // Bot ID -> Metric -> Recent raw values
+	smoothedCounts   = make(map[string]int)                  // Bot ID -> Previous smoothed server count
+)
+
+func loadSmoothing() map[string]Smoothing {
+	smoothing := make(map[string]Smoothing)
+	for metric, value := range parseBotPairs(os.Getenv("SMOOTHING")) {
+		parsed, err := parseSmoothing(value)
+		if err != nil {
+			log.Printf("Invalid smoothing for %s: %v", metric, err)
+			continue
+		}
+		smoothing[metric] = parsed
+	}
+	return smoothing
+}
+
+func parseSmoothing(value string) (Smoothing, error) {
+	window, method, _ := strings.Cut(value, ":")
+	n, err := strconv.Atoi(window)
+	if err != nil || n < 2 || n > maxSmoothingWindow {
+		return Smoothing{}, fmt.Errorf("window %q should be a number of samples from 2 to %d", window, maxSmoothingWindow)
+	}
+
+	smoothing := Smoothing{Window: n, Percentile: 50}
+	switch {
+	case method == "" || method == "median":
+	case method == "mean":
+		smoothing.Mean = true
+	case strings.HasPrefix(method, "p"):
+		percentile, err := strconv.ParseFloat(method[1:], 64)
+		if err != nil || percentile < 0 || percentile > 100 {
+			return Smoothing{}, fmt.Errorf("%q is not a percentile from p0 to p100", method)
+		}
+		smoothing.Percentile = percentile
+	default:
+		return Smoothing{}, fmt.Errorf("unknown method %q (expected median, mean or a percentile such as p25)", method)
+	}
+	return smoothing, nil
+}
+
+func validateSmoothing(problems *configProblems) {
+	for metric, value := range parseBotPairs(os.Getenv("SMOOTHING")) {
+		field := "SMOOTHING[" + metric + "]"
+		if _, ok := smoothedMetrics[metric]; !ok {
+			problems.add(field, "metric cannot be smoothed (expected server_count, user_installs, patrons, stars or open_issues)")
+		} else if _, err := parseSmoothing(value); err != nil {
+			problems.add(field, "%v", err)
+		}
+	}
+}
+
+// smoothStats returns a copy of the stats with the smoothed metrics replaced
+// by their rolling value, for alert evaluation. Reports keep the raw values.
+// The change of a smoothed server count is the change of the smoothed value,
+// so a single noisy sample does not look like a drop.
+func smoothStats(allStats []BotStats) []BotStats {
+	if len(config.Smoothing) == 0 {
+		return allStats
+	}
+
+	smoothingMu.Lock()
+	defer smoothingMu.Unlock()
+
+	smoothed := make([]BotStats, len(allStats))
+	copy(smoothed, allStats)
+	for i := range smoothed {
+		stats := &smoothed[i]
+		if stats.Error != nil || stats.Pending {
+			continue
+		}
+		if stats.Repo != nil {
+			repo := *stats.Repo
+			stats.Repo = &repo
+		}
+
+		samples := smoothingSamples[stats.BotID]
+		if samples == nil {
+			samples = make(map[string][]float64)
+			smoothingSamples[stats.BotID] = samples
+		}
+		for metric, smoothing := range config.Smoothing {
+			value, known := ruleMetrics[metric](*stats)
+			if !known {
+				continue
+			}
+			window := append(samples[metric], value)
+			if len(window) > smoothing.Window {
+				window = window[len(window)-smoothing.Window:]
+			}
+			samples[metric] = window
+			smoothedMetrics[metric](stats, smoothing.apply(window))
+		}
+
+		if _, smoothingCount := config.Smoothing["server_count"]; smoothingCount {
+			previous, known := smoothedCounts[stats.BotID]
+			stats.Change, stats.HasChange = 0, known
+			if known {
+				stats.Change = stats.ServerCount - previous
+			}
+			smoothedCounts[stats.BotID] = stats.ServerCount
+		}
+	}
+	return smoothed
+}
+
+func (s Smoothing) apply(window []float64) float64 {
+	if s.Mean {
+		var sum float64
+		for _, value := range window {
+			sum += value
+		}
+		return sum / float64(len(window))
+	}
+
+	sorted := append([]float64(nil), window...)
+	sort.Float64s(sorted)
+	rank := s.Percentile / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
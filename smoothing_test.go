@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseSmoothing(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Smoothing
+		wantErr bool
+	}{
+		{"5", Smoothing{Window: 5, Percentile: 50}, false},
+		{"5:median", Smoothing{Window: 5, Percentile: 50}, false},
+		{"10:mean", Smoothing{Window: 10, Mean: true, Percentile: 50}, false},
+		{"3:p25", Smoothing{Window: 3, Percentile: 25}, false},
+		{"3:p100", Smoothing{Window: 3, Percentile: 100}, false},
+		{"1", Smoothing{}, true},
+		{"101", Smoothing{}, true},
+		{"five", Smoothing{}, true},
+		{"5:p101", Smoothing{}, true},
+		{"5:px", Smoothing{}, true},
+		{"5:mode", Smoothing{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := parseSmoothing(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSmoothingApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		smoothing Smoothing
+		window    []float64
+		want      float64
+	}{
+		{"median of odd window", Smoothing{Percentile: 50}, []float64{5, 1, 100}, 5},
+		{"median of even window", Smoothing{Percentile: 50}, []float64{4, 1, 3, 2}, 2.5},
+		{"median ignores a spike", Smoothing{Percentile: 50}, []float64{100, 101, 0, 102, 99}, 100},
+		{"mean", Smoothing{Mean: true, Percentile: 50}, []float64{1, 2, 6}, 3},
+		{"p0", Smoothing{Percentile: 0}, []float64{3, 1, 2}, 1},
+		{"p100", Smoothing{Percentile: 100}, []float64{3, 1, 2}, 3},
+		{"p25 interpolates", Smoothing{Percentile: 25}, []float64{10, 20, 30}, 15},
+		{"single value", Smoothing{Percentile: 90}, []float64{7}, 7},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			window := append([]float64(nil), test.window...)
+			if got := test.smoothing.apply(window); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+			for i := range window {
+				if window[i] != test.window[i] {
+					t.Fatalf("apply reordered the window: %v", window)
+				}
+			}
+		})
+	}
+}
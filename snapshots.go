@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// snapshotMetrics are the built-in metrics kept in each snapshot. Changes
+// are left out since they can be derived from two snapshots.
+var snapshotMetrics = []string{"server_count", "user_installs", "patrons", "stars", "open_issues"}
+
+// Snapshot is one bot's metrics at one run, as written to SNAPSHOT_FILE.
+type Snapshot struct {
+	Time      time.Time          `json:"time"`
+	BotID     string             `json:"bot_id"`
+	BotName   string             `json:"bot_name,omitempty"`
+	Source    string             `json:"source,omitempty"`
+	Estimated bool               `json:"estimated,omitempty"`
+	Metrics   map[string]float64 `json:"metrics"`           // Built-in and computed metrics
+	Sources   map[string]int     `json:"sources,omitempty"` // Source name -> Count of every source that answered
+}
+
+var snapshotMu sync.Mutex
+
+// recordSnapshots appends the bots fetched successfully to SNAPSHOT_FILE.
+func recordSnapshots(allStats []BotStats) {
+	if config.SnapshotFile == "" {
+		return
+	}
+
+	var snapshots []Snapshot
+	for _, stats := range allStats {
+		if stats.Error != nil || stats.Pending {
+			continue
+		}
+		snapshot := Snapshot{
+			Time:      time.Now(),
+			BotID:     stats.BotID,
+			BotName:   stats.BotName,
+			Source:    stats.Source,
+			Estimated: stats.Estimated,
+			Metrics:   make(map[string]float64),
+			Sources:   stats.Sources,
+		}
+		for _, metric := range snapshotMetrics {
+			if value, known := ruleMetrics[metric](stats); known {
+				snapshot.Metrics[metric] = value
+			}
+		}
+		for name, value := range stats.Computed {
+			snapshot.Metrics[name] = value
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if len(snapshots) == 0 {
+		return
+	}
+
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+
+	file, err := os.OpenFile(config.SnapshotFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Printf("Error opening SNAPSHOT_FILE: %v", err)
+		return
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, snapshot := range snapshots {
+		if err := encoder.Encode(snapshot); err != nil {
+			log.Printf("Error writing snapshot: %v", err)
+			return
+		}
+	}
+}
+
+// findSnapshots returns the bot's last snapshot on each of the dates
+// (YYYY-MM-DD in local time). A date without a snapshot is nil.
+func findSnapshots(botID string, dates []time.Time) ([]*Snapshot, error) {
+	file, err := os.Open(config.SnapshotFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return make([]*Snapshot, len(dates)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	found := make([]*Snapshot, len(dates))
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var snapshot Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &snapshot); err != nil || snapshot.BotID != botID {
+			continue
+		}
+		for i, date := range dates {
+			at := snapshot.Time.In(time.Local)
+			if at.Year() == date.Year() && at.YearDay() == date.YearDay() && (found[i] == nil || !at.Before(found[i].Time)) {
+				snapshot := snapshot
+				found[i] = &snapshot
+			}
+		}
+	}
+	return found, scanner.Err()
+}
+
+// compareSnapshots renders the difference of every metric and source count
+// of a bot between the two dates, as a title and an aligned table.
+func compareSnapshots(botID, from, to string) (string, string, error) {
+	if config.SnapshotFile == "" {
+		return "", "", errors.New("SNAPSHOT_FILE is not set, so no snapshots are stored")
+	}
+
+	var dates []time.Time
+	for _, value := range []string{from, to} {
+		date, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(value), time.Local)
+		if err != nil {
+			return "", "", fmt.Errorf("date %q should be YYYY-MM-DD", value)
+		}
+		dates = append(dates, date)
+	}
+
+	snapshots, err := findSnapshots(botID, dates)
+	if err != nil {
+		return "", "", fmt.Errorf("reading SNAPSHOT_FILE: %w", err)
+	}
+	for i, snapshot := range snapshots {
+		if snapshot == nil {
+			return "", "", fmt.Errorf("no snapshot of %s on %s", botLabel(botID), dates[i].Format("2006-01-02"))
+		}
+	}
+	before, after := snapshots[0], snapshots[1]
+
+	var rows strings.Builder
+	table := tabwriter.NewWriter(&rows, 0, 0, 2, ' ', 0)
+	for _, name := range unionKeys(before.Metrics, after.Metrics) {
+		old, hadOld := before.Metrics[name]
+		current, hasCurrent := after.Metrics[name]
+		fmt.Fprintf(table, "%s\t%s\n", name, diffValues(old, hadOld, current, hasCurrent))
+	}
+	if before.Source != after.Source {
+		fmt.Fprintf(table, "source\t%s → %s\t\n", before.Source, after.Source)
+	}
+	for _, name := range unionKeys(before.Sources, after.Sources) {
+		old, hadOld := before.Sources[name]
+		current, hasCurrent := after.Sources[name]
+		fmt.Fprintf(table, "%s\t%s\n", "source: "+name, diffValues(float64(old), hadOld, float64(current), hasCurrent))
+	}
+	table.Flush()
+
+	name := after.BotName
+	if name == "" {
+		name = botID
+	}
+	title := fmt.Sprintf("%s: %s → %s", name, before.Time.In(time.Local).Format("2006-01-02 15:04"), after.Time.In(time.Local).Format("2006-01-02 15:04"))
+	return title, rows.String(), nil
+}
+
+// diffValues renders "old → new  +diff (+percent)", with "-" for a value
+// that is missing on one side.
+func diffValues(old float64, hadOld bool, current float64, hasCurrent bool) string {
+	round := func(value float64) string { return formatRuleValue(math.Round(value*100) / 100) }
+	switch {
+	case !hadOld:
+		return "- → " + round(current) + "\t"
+	case !hasCurrent:
+		return round(old) + " → -\t"
+	}
+
+	diff := round(current - old)
+	if current >= old {
+		diff = "+" + diff
+	}
+	result := round(old) + " → " + round(current) + "\t" + diff
+	if old != 0 {
+		result += fmt.Sprintf(" (%+.1f%%)", (current-old)*100/math.Abs(old))
+	}
+	return result
+}
+
+// unionKeys returns the names present in either map.
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make(map[string]bool)
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+
+	// Built-in metrics first in their usual order, then the rest by name
+	var sorted []string
+	for _, metric := range snapshotMetrics {
+		if keys[metric] {
+			sorted = append(sorted, metric)
+			delete(keys, metric)
+		}
+	}
+	var rest []string
+	for key := range keys {
+		rest = append(rest, key)
+	}
+	sort.Strings(rest)
+	return append(sorted, rest...)
+}
+
+// handleCompareCommand answers /compare with the diff of two snapshots.
+func handleCompareCommand(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+	values := make(map[string]string)
+	for _, option := range options {
+		values[option.Name] = option.StringValue()
+	}
+
+	botID, ok := resolveBot(values["bot"])
+	if !ok {
+		respondEphemeral(s, i, fmt.Sprintf("bot %q は監視対象ではありません", values["bot"]))
+		return
+	}
+
+	title, table, err := compareSnapshots(botID, values["date1"], values["date2"])
+	if err != nil {
+		respondEphemeral(s, i, err.Error())
+		return
+	}
+	respondEphemeral(s, i, "📊 **"+title+"**\n```\n"+table+"```")
+}
+
+// runCompare prints the diff of two snapshots of a bot. Bots that are no
+// longer targets can be compared by ID.
+func runCompare(args []string) {
+	if len(args) != 3 {
+		log.Fatal("Usage: statbot compare BOT DATE1 DATE2")
+	}
+
+	botID, ok := resolveBot(args[0])
+	if !ok {
+		botID = args[0]
+	}
+	title, table, err := compareSnapshots(botID, args[1], args[2])
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(title)
+	fmt.Print(table)
+}
@@ -0,0 +1,6 @@
+//go:build mysql
+
+package main
+
+// Registers the "mysql" database/sql driver for mysql:// DSNs.
+import _ "github.com/go-sql-driver/mysql"
@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package main
+
+// Registers the pure Go "sqlite" database/sql driver for sqlite:// DSNs.
+import _ "modernc.org/sqlite"
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robfig/cron/v3"
+)
+
+// stateRetention is how long delivered runs are remembered.
+const stateRetention = 7 * 24 * time.Hour
+
+// deliveryState records which scheduled runs were delivered where, so a
+// run that is repeated after a restart skips destinations it already
+// reached. It also keeps the cached bot usernames (see usercache.go), the
+// baselines of named report schedules (see schedules.go), the outcomes of
+// the latest runs (see status.go) and how far interrupted guild counts got
+// (see guildpages.go).
+type deliveryState struct {
+	Runs       map[string]map[string][]string `json:"runs"`                  // Run ID -> Channel -> Message IDs
+	Users      map[string]CachedUser          `json:"users,omitempty"`       // Bot ID -> Cached Discord user
+	Periods    map[string]*schedulePeriod     `json:"periods,omitempty"`     // Schedule group -> Period of its reports
+	RecentRuns []RunRecord                    `json:"recent_runs,omitempty"` // Latest runs, oldest first
+
+	GuildCheckpoints map[string]GuildCheckpoint `json:"guild_checkpoints,omitempty"` // Bot ID -> Interrupted guild count
+}
+
+var (
+	stateMu   sync.Mutex
+	delivered = deliveryState{Runs: make(map[string]map[string][]string)}
+)
+
+// reportRunID identifies a scheduled run by its scheduled time and timezone
+// group, so the same run always gets the same ID.
+func reportRunID(scheduledAt time.Time, group string) string {
+	return scheduledAt.UTC().Format(time.RFC3339) + "/" + group
+}
+
+func loadState() {
+	if config.StateFile == "" {
+		return
+	}
+
+	data, err := os.ReadFile(config.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Error reading STATE_FILE: %v", err)
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if err := json.Unmarshal(data, &delivered); err != nil {
+		log.Fatalf("Error parsing STATE_FILE: %v", err)
+	}
+	if delivered.Runs == nil {
+		delivered.Runs = make(map[string]map[string][]string)
+	}
+}
+
+// isDelivered reports whether the run already reached the channel. Runs
+// without an ID are never deduplicated.
+func isDelivered(runID, channelID string) bool {
+	if runID == "" || config.StateFile == "" {
+		return false
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	_, exists := delivered.Runs[runID][channelID]
+	return exists
+}
+
+func recordDelivered(runID, channelID string, messages []*discordgo.Message) {
+	// Dry runs must not keep the real send from happening later
+	if runID == "" || config.StateFile == "" || dryRun("discord") {
+		return
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if delivered.Runs[runID] == nil {
+		delivered.Runs[runID] = make(map[string][]string)
+	}
+	var messageIDs []string
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.ID)
+	}
+	delivered.Runs[runID][channelID] = messageIDs
+
+	for id := range delivered.Runs {
+		scheduled, _, _ := strings.Cut(id, "/")
+		if at, err := time.Parse(time.RFC3339, scheduled); err == nil && time.Since(at) > stateRetention {
+			delete(delivered.Runs, id)
+		}
+	}
+
+	if err := saveState(); err != nil {
+		log.Printf("Error saving STATE_FILE: %v", err)
+	}
+}
+
+// saveState writes the state through a temporary file so a crash never
+// leaves it half-written. The caller must hold stateMu.
+func saveState() error {
+	data, err := json.MarshalIndent(delivered, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := config.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, config.StateFile)
+}
+
+// startedRun reports whether the run reached some but not all of the
+// destinations, which means it was interrupted.
+func startedRun(runID string, destinations []Destination) bool {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	reached := 0
+	for _, destination := range destinations {
+		if _, exists := delivered.Runs[runID][destination.ChannelID]; exists {
+			reached++
+		}
+	}
+	return reached > 0 && reached < len(destinations)
+}
+
+// resumeInterruptedRun finishes the group's most recent scheduled run in
+// the last day if a restart cut it short. Destinations it already reached
+// are skipped by publishReport.
+func resumeInterruptedRun(expr, group string, destinations []Destination) {
+	if config.StateFile == "" {
+		return
+	}
+
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	var last time.Time
+	for next := schedule.Next(now.Add(-24 * time.Hour)); !next.After(now); next = schedule.Next(next) {
+		last = next
+	}
+	if last.IsZero() {
+		return
+	}
+
+	runID := reportRunID(last, group)
+	if startedRun(runID, destinations) {
+		log.Printf("Run %s was interrupted, delivering to the remaining destinations", runID)
+		go notifyDestinations(runID, time.Time{}, destinations)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// recentRunLimit is how many runs are kept in STATE_FILE for the status.
+const recentRunLimit = 10
+
+// RunRecord is the outcome of one report run, kept in STATE_FILE so the
+// status survives restarts.
+type RunRecord struct {
+	ID       string        `json:"id,omitempty"` // Run ID of scheduled runs
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Bots     []BotOutcome  `json:"bots"`
+}
+
+// BotOutcome is how fetching one bot went in a run.
+type BotOutcome struct {
+	BotID   string `json:"bot_id"`
+	BotName string `json:"bot_name,omitempty"`
+	Count   int    `json:"count,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Pending bool   `json:"pending,omitempty"` // Still being fetched when the report was sent
+}
+
+// runOutcomes copies the outcome of every bot before the report goes out,
+// since late results keep updating the stats afterwards.
+func runOutcomes(allStats []BotStats) []BotOutcome {
+	outcomes := make([]BotOutcome, len(allStats))
+	for i, stats := range allStats {
+		outcome := BotOutcome{BotID: stats.BotID, BotName: stats.BotName, Pending: stats.Pending}
+		switch {
+		case stats.Error != nil:
+			outcome.Error = errorMessage(defaultLanguage, stats.Error)
+		case !stats.Pending:
+			outcome.Count, outcome.Source = stats.ServerCount, stats.Source
+		}
+		outcomes[i] = outcome
+	}
+	return outcomes
+}
+
+// recordRunResult keeps the run among the recent runs, saving them to
+// STATE_FILE when it is set.
+func recordRunResult(runID string, start time.Time, outcomes []BotOutcome) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	delivered.RecentRuns = append(delivered.RecentRuns, RunRecord{ID: runID, Start: start, Duration: time.Since(start), Bots: outcomes})
+	if len(delivered.RecentRuns) > recentRunLimit {
+		delivered.RecentRuns = delivered.RecentRuns[len(delivered.RecentRuns)-recentRunLimit:]
+	}
+
+	if config.StateFile != "" && !dryRun("discord") {
+		if err := saveState(); err != nil {
+			log.Printf("Error saving STATE_FILE: %v", err)
+		}
+	}
+}
+
+// statusSummary describes the schedules with their next run, the latest
+// run bot by bot and the recent runs. Unless detailed, the latest run only
+// lists the bots that failed, to fit in a Discord message.
+func statusSummary(detailed bool) string {
+	var b strings.Builder
+
+	switch {
+	case !moduleEnabled(ModuleSampling):
+		b.WriteString("スケジューラー: 停止中（samplingモジュールが無効）\n")
+	case config.ScheduleTolerance > 0:
+		fmt.Fprintf(&b, "スケジューラー: 有効（%v以上の遅れでアラート）\n", config.ScheduleTolerance)
+	default:
+		b.WriteString("スケジューラー: 有効（遅れの監視は無効）\n")
+	}
+
+	b.WriteString("次回の実行:\n")
+	now := time.Now()
+	for _, run := range reportGroups() {
+		next := "スケジュールを解析できません"
+		if schedule, err := cron.ParseStandard(run.Expr); err == nil {
+			next = schedule.Next(now).Format("2006-01-02 15:04 MST")
+		}
+		fmt.Fprintf(&b, "・%s: %s（%s, 送信先 %d件）\n", run.Group, next, run.Schedule.Format, len(run.Destinations))
+	}
+
+	stateMu.Lock()
+	runs := append([]RunRecord(nil), delivered.RecentRuns...)
+	stateMu.Unlock()
+
+	if len(runs) == 0 {
+		b.WriteString("最終実行: まだ実行されていません\n")
+		return b.String()
+	}
+
+	last := runs[len(runs)-1]
+	fmt.Fprintf(&b, "最終実行: %s（所要時間 %v）: %s\n",
+		last.Start.In(time.Local).Format("2006-01-02 15:04:05"), last.Duration.Round(time.Millisecond), countOutcomes(last.Bots))
+	for _, outcome := range last.Bots {
+		name := outcome.BotName
+		if name == "" || name == "Unknown" {
+			name = outcome.BotID
+		}
+		switch {
+		case outcome.Error != "":
+			fmt.Fprintf(&b, "　❌ %s: %s\n", name, outcome.Error)
+		case outcome.Pending:
+			fmt.Fprintf(&b, "　⏳ %s: 取得中\n", name)
+		case detailed:
+			fmt.Fprintf(&b, "　✅ %s: %d (%s)\n", name, outcome.Count, outcome.Source)
+		}
+	}
+
+	if len(runs) > 1 {
+		b.WriteString("最近の実行:\n")
+		for i := len(runs) - 1; i >= 0; i-- {
+			run := runs[i]
+			fmt.Fprintf(&b, "・%s（%v）: %s\n",
+				run.Start.In(time.Local).Format("2006-01-02 15:04"), run.Duration.Round(time.Millisecond), countOutcomes(run.Bots))
+		}
+	}
+	return b.String()
+}
+
+// countOutcomes summarizes a run as succeeded, failed and pending bots.
+func countOutcomes(outcomes []BotOutcome) string {
+	var succeeded, failed, pending int
+	for _, outcome := range outcomes {
+		switch {
+		case outcome.Error != "":
+			failed++
+		case outcome.Pending:
+			pending++
+		default:
+			succeeded++
+		}
+	}
+
+	summary := fmt.Sprintf("成功 %d / 失敗 %d", succeeded, failed)
+	if pending > 0 {
+		summary += fmt.Sprintf(" / 取得中 %d", pending)
+	}
+	return summary
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// getPatronCount returns the current patron count of a Patreon campaign,
+// using the campaign owner's creator access token.
+func getPatronCount(botID, campaignID string) (int, error) {
+	url := fmt.Sprintf("https://www.patreon.com/api/oauth2/v2/campaigns/%s?fields%%5Bcampaign%%5D=patron_count", campaignID)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+config.PatreonToken)
+	identifyRequest(req)
+	req = tagBot(req, botID)
+
+	waitForHost(url)
+	client := &http.Client{Timeout: fetchTimeout(botID, "patreon")}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("Patreon API returned status %d", resp.StatusCode)
+		if kind := statusError(resp.StatusCode); kind != nil {
+			err = wrapKind(kind, err)
+		}
+		captureResponse("patreon", botID, url, resp.StatusCode, body, err)
+		return 0, err
+	}
+
+	var campaign struct {
+		Data struct {
+			Attributes struct {
+				PatronCount *int `json:"patron_count"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &campaign); err != nil {
+		captureResponse("patreon", botID, url, resp.StatusCode, body, err)
+		return 0, err
+	}
+	if campaign.Data.Attributes.PatronCount == nil {
+		err := fmt.Errorf("could not find patron_count in Patreon response")
+		captureResponse("patreon", botID, url, resp.StatusCode, body, err)
+		return 0, err
+	}
+
+	return *campaign.Data.Attributes.PatronCount, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	badgeColorKnown   = "#007ec6"
+	badgeColorUnknown = "#9f9f9f"
+)
+
+// handleBadge serves /badge/{bot_id}/servers.svg, a shields.io-style badge
+// with the bot's latest server count. ?label= overrides the left-hand text.
+func handleBadge(w http.ResponseWriter, r *http.Request) {
+	botID, metric, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/badge/"), "/")
+	if !ok || metric != "servers.svg" || !isTargetBot(botID) || config.PrivateBots[botID] {
+		http.NotFound(w, r)
+		return
+	}
+
+	label := r.URL.Query().Get("label")
+	if label == "" {
+		label = "servers"
+	}
+
+	value, color := "unknown", badgeColorUnknown
+	if sample, known := latestSampleOf(botID); known {
+		value, color = compactCount(sample.ServerCount), badgeColorKnown
+		if sample.Estimated {
+			value = "~" + value
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml;charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	fmt.Fprint(w, renderBadge(label, value, color))
+}
+
+// renderBadge draws a flat two-part badge. Text widths are estimated from
+// the character count, which is close enough for the 11px Verdana shields
+// uses.
+func renderBadge(label, value, color string) string {
+	labelWidth := utf8.RuneCountInString(label)*7 + 10
+	valueWidth := utf8.RuneCountInString(value)*7 + 10
+	width := labelWidth + valueWidth
+	label, value = html.EscapeString(label), html.EscapeString(value)
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">`+
+		`<title>%[4]s: %[5]s</title>`+
+		`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`+
+		`<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[4]s</text><text x="%[7]d" y="14">%[4]s</text>`+
+		`<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[5]s</text><text x="%[8]d" y="14">%[5]s</text></g></svg>`,
+		width, labelWidth, valueWidth, label, value, color, labelWidth/2, labelWidth+valueWidth/2)
+}
+
+// compactCount renders counts the way shields does: 950, 12.3k, 1.2M.
+func compactCount(n int) string {
+	switch {
+	case n >= 1000000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1000000), ".0") + "M"
+	case n >= 1000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1000), ".0") + "k"
+	default:
+		return fmt.Sprint(n)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// teamsNotifier posts alerts to a Microsoft Teams incoming webhook as an
+// Adaptive Card.
+type teamsNotifier struct{}
+
+func (teamsNotifier) Name() string { return "teams" }
+
+func (teamsNotifier) Notify(alert Alert, text string) error {
+	if config.TeamsWebhookURL == "" {
+		return fmt.Errorf("Teams: TEAMS_WEBHOOK_URL is not set")
+	}
+
+	body, err := json.Marshal(teamsAlertCard(alert))
+	if err != nil {
+		return fmt.Errorf("Teams: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", config.TeamsWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Teams: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	identifyRequest(req)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Teams: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Workflow webhooks answer 202, legacy connectors 200
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Teams: webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func teamsAlertCard(alert Alert) map[string]any {
+	color := map[Severity]string{
+		SeverityInfo:     "Accent",
+		SeverityWarn:     "Warning",
+		SeverityCritical: "Attention",
+	}[alert.Severity]
+
+	subject := "statbot"
+	if alert.BotName != "" {
+		subject = alert.BotName
+	} else if alert.BotID != "" {
+		subject = alert.BotID
+	}
+
+	facts := []map[string]string{
+		{"title": "重要度", "value": alert.Severity.String()},
+		{"title": "対象", "value": subject},
+		{"title": "時刻", "value": alert.Time.Format("2006-01-02 15:04:05")},
+	}
+	if alert.BotID != "" {
+		facts = append(facts, map[string]string{"title": "Bot ID", "value": alert.BotID})
+	}
+
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body": []map[string]any{
+			{
+				"type":   "TextBlock",
+				"text":   alert.Severity.emoji() + " statbot " + alert.Severity.String() + " alert",
+				"size":   "Medium",
+				"weight": "Bolder",
+				"color":  color,
+			},
+			{"type": "TextBlock", "text": alert.Message, "wrap": true},
+			{"type": "FactSet", "facts": facts},
+		},
+	}
+
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{
+			{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+		},
+	}
+}
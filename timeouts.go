@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// parseTimeouts reads KEY:SECONDS pairs, where KEY is a bot ID or a source key.
+func parseTimeouts(envName string) map[string]time.Duration {
+	timeouts := make(map[string]time.Duration)
+	for key, value := range parseBotPairs(os.Getenv(envName)) {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			log.Printf("Invalid timeout in %s for %s: %s", envName, key, value)
+			continue
+		}
+		timeouts[key] = time.Duration(seconds) * time.Second
+	}
+	return timeouts
+}
+
+// fetchTimeout returns the HTTP timeout for fetching from source for a bot.
+// A per-bot timeout wins over a per-source one, which wins over FETCH_TIMEOUT.
+func fetchTimeout(botID, source string) time.Duration {
+	if timeout, exists := config.BotTimeouts[botID]; exists {
+		return timeout
+	}
+	if timeout, exists := config.SourceTimeouts[source]; exists {
+		return timeout
+	}
+	return config.FetchTimeout
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// CachedUser is the part of a bot's Discord user the watcher needs, kept
+// between runs so large fleets don't look up every bot on every run.
+type CachedUser struct {
+	Username    string              `json:"username"`
+	Bot         bool                `json:"bot"`
+	PublicFlags discordgo.UserFlags `json:"public_flags"`
+	Fetched     time.Time           `json:"fetched"`
+}
+
+// lookupBotUser returns the bot's Discord user, from the cache while it is
+// younger than USERNAME_REFRESH_HOURS. When a refresh fails for a reason
+// other than the account being gone, the cached user is used until the
+// next attempt. The cache is kept in STATE_FILE when one is configured.
+func lookupBotUser(botID string) (*discordgo.User, error) {
+	stateMu.Lock()
+	cached, known := delivered.Users[botID]
+	stateMu.Unlock()
+
+	if known && config.UsernameRefresh > 0 && time.Since(cached.Fetched) < config.UsernameRefresh {
+		return cached.user(botID), nil
+	}
+
+	user, err := session.User(botID)
+	if err != nil {
+		if known && !isUnknownUser(err) {
+			log.Printf("Error refreshing the username of bot %s, using the cached one: %v", botID, err)
+			return cached.user(botID), nil
+		}
+		if known {
+			stateMu.Lock()
+			delete(delivered.Users, botID)
+			stateMu.Unlock()
+		}
+		return nil, err
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if delivered.Users == nil {
+		delivered.Users = make(map[string]CachedUser)
+	}
+	delivered.Users[botID] = CachedUser{
+		Username:    user.Username,
+		Bot:         user.Bot,
+		PublicFlags: user.PublicFlags,
+		Fetched:     time.Now(),
+	}
+	if config.StateFile != "" {
+		if err := saveState(); err != nil {
+			log.Printf("Error saving STATE_FILE: %v", err)
+		}
+	}
+	return user, nil
+}
+
+func (c CachedUser) user(botID string) *discordgo.User {
+	return &discordgo.User{ID: botID, Username: c.Username, Bot: c.Bot, PublicFlags: c.PublicFlags}
+}
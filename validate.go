@@ -0,0 +1,468 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	snowflakePattern    = regexp.MustCompile(`^[0-9]{17,20}$`)
+	discordTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+$`)
+	githubRepoPattern   = regexp.MustCompile(`^[A-Za-z0-9-]+/[A-Za-z0-9._-]+$`)
+)
+
+var sourceKeys = []string{"webhook", "command", "sql", "redis", "graphql", "discord", "topgg", "dbl", "direct"}
+
+// Settings checked by kind. Settings with their own format are validated
+// individually in validateEnv.
+var (
+	channelSettings = []string{"CHANNEL_ID", "FEED_CHANNEL_ID", "LISTING_CHANNEL_ID", "PREVIEW_CHANNEL_ID", "APPROVAL_CHANNEL_ID"}
+	guildSettings   = []string{"CHANNEL_GUILD_ID", "COMMAND_GUILD_ID"}
+	urlSettings     = []string{"MASTODON_URL", "NTFY_URL", "TEAMS_WEBHOOK_URL"}
+	boolSettings    = []string{
+		"NETWORK_STATS", "BADGE_TRACKING", "LISTING_TRACKING", "REPORT_IMAGE", "SOURCE_RACING",
+		"PARTIAL_REPORTS", "USER_INSTALL_TRACKING", "ANOMALY_DETECTION", "CROSS_CHECK",
+		"REMEDIATION_DRY_RUN", "REPORT_TOTAL", "VOTE_TRACKING",
+	}
+	intSettings = []string{
+		"PREVIEW_MINUTES", "DEBUG_CAPTURE_DAYS", "APPROVAL_MINUTES", "FETCH_CONCURRENCY", "HOST_RATE_LIMIT",
+		"FETCH_DEADLINE", "FETCH_TIMEOUT", "ANOMALY_THRESHOLD", "ANOMALY_MIN_SAMPLES", "CROSS_CHECK_TOLERANCE",
+		"FEED_MILESTONE_STEP", "FEED_MIN_CHANGE", "PROXY_TTL", "ESCALATION_REPEAT",
+		"REMEDIATION_AFTER", "REMEDIATION_COOLDOWN", "REMEDIATION_LIMIT", "USERNAME_REFRESH_HOURS",
+		"SCHEDULE_TOLERANCE",
+	}
+	enumSettings = map[string][]string{
+		"REPORT_SORT":             {"config", "name", "count", "growth"},
+		"REPORT_FIELD_LAYOUT":     {"inline", "block"},
+		"REPORT_TOTALS_POSITION":  {"top", "bottom"},
+		"APPROVAL_TIMEOUT_ACTION": {"internal", "skip"},
+		"BOT_ID_CHECK":            {"fail", "warn", "off"},
+	}
+)
+
+// configProblems collects validation errors with the setting they belong to.
+type configProblems []string
+
+func (p *configProblems) add(field, format string, args ...any) {
+	*p = append(*p, field+": "+fmt.Sprintf(format, args...))
+}
+
+// validateEnv checks the raw settings before they are parsed, so every
+// mistake is reported at startup in one go instead of one at a time or when
+// the setting is first used.
+func validateEnv() {
+	var problems configProblems
+
+	if os.Getenv("DISCORD_TOKEN") == "" {
+		problems.add("DISCORD_TOKEN", "required")
+	} else if !validDiscordToken(os.Getenv("DISCORD_TOKEN")) {
+		problems.add("DISCORD_TOKEN", "does not look like a bot token (expected three dot-separated parts)")
+	}
+	if os.Getenv("CHANNEL_ID") == "" {
+		problems.add("CHANNEL_ID", "required")
+	}
+
+	targetsName := "TARGET_BOT_IDS"
+	targets := os.Getenv(targetsName)
+	if targets == "" {
+		targetsName = "TARGET_BOT_ID"
+		targets = os.Getenv(targetsName)
+	}
+	targetIDs := make(map[string]bool)
+	for i, id := range strings.Split(targets, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if !snowflakePattern.MatchString(id) {
+			problems.add(fmt.Sprintf("%s[%d]", targetsName, i), "%q is not a Discord ID", id)
+		} else if targetIDs[id] {
+			problems.add(fmt.Sprintf("%s[%d]", targetsName, i), "%s is listed more than once", id)
+		}
+		targetIDs[id] = true
+	}
+	if len(targetIDs) == 0 {
+		problems.add("TARGET_BOT_IDS", "required")
+	}
+
+	for _, name := range channelSettings {
+		if value := os.Getenv(name); value != "" {
+			validateChannelRef(&problems, name, value)
+		}
+	}
+	for _, name := range guildSettings {
+		if value := os.Getenv(name); value != "" && !snowflakePattern.MatchString(value) {
+			problems.add(name, "%q is not a Discord ID", value)
+		}
+	}
+	for _, name := range urlSettings {
+		if value := os.Getenv(name); value != "" {
+			validateURL(&problems, name, value)
+		}
+	}
+	for _, name := range intSettings {
+		if value := os.Getenv(name); value != "" {
+			if n, err := strconv.Atoi(value); err != nil || n < 0 {
+				problems.add(name, "%q is not a non-negative integer", value)
+			}
+		}
+	}
+	for _, name := range boolSettings {
+		if value := os.Getenv(name); value != "" && value != "true" && value != "false" {
+			problems.add(name, "%q must be true or false", value)
+		}
+	}
+	enumNames := make([]string, 0, len(enumSettings))
+	for name := range enumSettings {
+		enumNames = append(enumNames, name)
+	}
+	sort.Strings(enumNames)
+	for _, name := range enumNames {
+		allowed := enumSettings[name]
+		if value := os.Getenv(name); value != "" && !contains(allowed, value) {
+			problems.add(name, "%q must be one of %s", value, strings.Join(allowed, ", "))
+		}
+	}
+
+	if value := getEnvDefault("NOTIFICATION_TIME", "09:00"); value != "" {
+		if _, err := cron.ParseStandard(toCronExpr(value)); err != nil {
+			problems.add("NOTIFICATION_TIME", "%q is neither HH:MM nor a cron expression: %v", value, err)
+		}
+	}
+
+	validateBotPairs(&problems, "BOT_TOKENS", targetIDs, func(value string) string {
+		if !validDiscordToken(value) {
+			return "does not look like a bot token"
+		}
+		return ""
+	})
+	validateBotPairs(&problems, "CUSTOM_WEBHOOKS", targetIDs, func(value string) string {
+		return urlProblem(value)
+	})
+	validateBotPairs(&problems, "CANONICAL_SOURCES", targetIDs, func(value string) string {
+		if !contains(sourceKeys, value) {
+			return fmt.Sprintf("%q must be one of %s", value, strings.Join(sourceKeys, ", "))
+		}
+		return ""
+	})
+	validateBotPairs(&problems, "BOT_TIMEOUTS", targetIDs, positiveSeconds)
+	validateBotPairs(&problems, "PATREON_CAMPAIGNS", targetIDs, func(value string) string {
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Sprintf("%q is not a campaign ID", value)
+		}
+		return ""
+	})
+	validateBotPairs(&problems, "GITHUB_REPOS", targetIDs, func(value string) string {
+		if !githubRepoPattern.MatchString(value) {
+			return fmt.Sprintf("%q is not OWNER/REPO", value)
+		}
+		return ""
+	})
+
+	publicAPIBots := getEnvSet("PUBLIC_API_BOTS")
+	for i, botID := range getEnvList("PRIVATE_BOTS") {
+		field := fmt.Sprintf("PRIVATE_BOTS[%d]", i)
+		if !targetIDs[botID] {
+			problems.add(field, "%s is not in TARGET_BOT_IDS", botID)
+		}
+		if publicAPIBots[botID] {
+			problems.add(field, "%s is also in PUBLIC_API_BOTS", botID)
+		}
+	}
+
+	// Commands and notes are free text, so only the bot they belong to is
+	// checked
+	for _, prefix := range []string{countCommandPrefix, botNotePrefix} {
+		values := getEnvPerBot(prefix)
+		bots := make([]string, 0, len(values))
+		for botID := range values {
+			bots = append(bots, botID)
+		}
+		sort.Strings(bots)
+		for _, botID := range bots {
+			if !targetIDs[botID] {
+				problems.add(prefix+botID, "bot is not in TARGET_BOT_IDS")
+			}
+		}
+	}
+
+	for source, value := range parseBotPairs(os.Getenv("SOURCE_TIMEOUTS")) {
+		field := "SOURCE_TIMEOUTS[" + source + "]"
+		if !contains(sourceKeys, source) {
+			problems.add(field, "unknown source (expected %s)", strings.Join(sourceKeys, ", "))
+		} else if problem := positiveSeconds(value); problem != "" {
+			problems.add(field, "%s", problem)
+		}
+	}
+
+	validateDestinations(&problems)
+	validateAlertRoutes(&problems)
+	validateModules(&problems)
+	validateDryRun(&problems)
+	validateRemediation(&problems, targetIDs)
+	validateAggregates(&problems, targetIDs)
+	validateHistoryDB(&problems)
+	validateRetryPolicies(&problems)
+	validateSQLSources(&problems, targetIDs)
+	validateRedisSources(&problems, targetIDs)
+	validateGraphQLSources(&problems, targetIDs)
+	validateComputedMetrics(&problems)
+	validateReportSchedules(&problems)
+	validateSmoothing(&problems)
+	validateReportTotals(&problems)
+	if os.Getenv("DEBUG_TOKEN") != "" && os.Getenv("DEBUG_CAPTURE_DIR") == "" {
+		problems.add("DEBUG_TOKEN", "needs DEBUG_CAPTURE_DIR")
+	}
+
+	for _, event := range hookEvents {
+		name := "HOOK_" + strings.ToUpper(event)
+		if target := strings.TrimSpace(os.Getenv(name)); strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+			validateURL(&problems, name, target)
+		}
+	}
+
+	if len(problems) == 0 {
+		return
+	}
+	log.Printf("Found %d configuration problems:", len(problems))
+	for _, problem := range problems {
+		log.Printf("  %s", problem)
+	}
+	log.Fatal("Fix the configuration and restart")
+}
+
+// verifyTargetBots looks up every target bot on Discord and reports the IDs
+// that don't exist or belong to a user account rather than a bot. With
+// BOT_ID_CHECK=fail (the default) statbot exits; lookups that fail for
+// other reasons, such as a network error, only log a warning.
+func verifyTargetBots() {
+	if config.BotIDCheck == "off" {
+		return
+	}
+
+	var problems configProblems
+	for _, botID := range config.TargetBotIDs {
+		user, err := lookupBotUser(botID)
+		trackAccount(botID, user, err)
+		switch {
+		case isUnknownUser(err):
+			problems.add("TARGET_BOT_IDS["+botID+"]", "no Discord user has this ID")
+		case err != nil:
+			log.Printf("Warning: could not look up bot %s on Discord: %v", botID, classifyError(err))
+		case !user.Bot:
+			problems.add("TARGET_BOT_IDS["+botID+"]", "%s is a user account, not a bot", user.Username)
+		}
+	}
+
+	if len(problems) == 0 {
+		return
+	}
+	log.Printf("Found %d invalid bot IDs:", len(problems))
+	for _, problem := range problems {
+		log.Printf("  %s", problem)
+	}
+	if config.BotIDCheck == "fail" {
+		log.Fatal("Fix TARGET_BOT_IDS (or set BOT_ID_CHECK=warn) and restart")
+	}
+}
+
+// validateBotPairs checks a BOT_ID:VALUE list: every ID must be one of the
+// target bots, and check (if any) describes what is wrong with a value.
+func validateBotPairs(problems *configProblems, name string, targetIDs map[string]bool, check func(string) string) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+
+	for i, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, value, found := strings.Cut(pair, ":")
+		id, value = strings.TrimSpace(id), strings.TrimSpace(value)
+		if !found || id == "" || value == "" {
+			problems.add(fmt.Sprintf("%s[%d]", name, i), "expected BOT_ID:VALUE")
+			continue
+		}
+
+		field := name + "[" + id + "]"
+		if !targetIDs[id] {
+			problems.add(field, "bot is not in TARGET_BOT_IDS")
+		}
+		if check != nil {
+			if problem := check(value); problem != "" {
+				problems.add(field, "%s", problem)
+			}
+		}
+	}
+}
+
+// validateDestinations checks REPORT_DESTINATIONS entries
+// (CHANNEL:LANGUAGE:TIMEZONE[:public]).
+func validateDestinations(problems *configProblems) {
+	validateDestinationList(problems, "REPORT_DESTINATIONS")
+}
+
+// validateDestinationList checks a variable in the REPORT_DESTINATIONS format.
+func validateDestinationList(problems *configProblems, name string) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+
+	for i, entry := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if parts[0] == "" {
+			continue
+		}
+		field := fmt.Sprintf("%s[%d]", name, i)
+
+		validateChannelRef(problems, field+".channel", parts[0])
+		if len(parts) > 1 && parts[1] != "" {
+			if _, ok := translations[strings.ToLower(parts[1])]; !ok {
+				problems.add(field+".language", "unsupported language %q", parts[1])
+			}
+		}
+		if len(parts) > 2 && parts[2] != "" {
+			if _, err := time.LoadLocation(parts[2]); err != nil {
+				problems.add(field+".timezone", "unknown timezone %q", parts[2])
+			}
+		}
+		for _, flag := range parts[min(len(parts), 3):] {
+			if flag != "public" && flag != "publish" {
+				problems.add(field, "unexpected %q (only \"public\" and \"publish\" may follow the timezone)", flag)
+			}
+		}
+	}
+}
+
+// validateAlertRoutes checks the destinations in each ALERT_ROUTES_<SEVERITY>.
+func validateAlertRoutes(problems *configProblems) {
+	for _, severity := range severities {
+		name := "ALERT_ROUTES_" + strings.ToUpper(severity.String())
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+
+		for i, destination := range strings.Split(value, ",") {
+			field := fmt.Sprintf("%s[%d]", name, i)
+			kind, id, _ := strings.Cut(strings.TrimSpace(destination), ":")
+			switch kind {
+			case "channel":
+				validateChannelRef(problems, field, id)
+			case "role":
+				if !snowflakePattern.MatchString(id) {
+					problems.add(field, "%q is not a role ID", id)
+				}
+			case "pagerduty":
+				if os.Getenv("PAGERDUTY_ROUTING_KEY") == "" {
+					problems.add(field, "needs PAGERDUTY_ROUTING_KEY")
+				}
+			case "email":
+				if os.Getenv("SMTP_HOST") == "" || os.Getenv("ALERT_EMAIL_FROM") == "" || os.Getenv("ALERT_EMAIL_TO") == "" {
+					problems.add(field, "needs SMTP_HOST, ALERT_EMAIL_FROM and ALERT_EMAIL_TO")
+				}
+			case "ntfy":
+				if os.Getenv("NTFY_URL") == "" {
+					problems.add(field, "needs NTFY_URL")
+				}
+			case "pushover":
+				if os.Getenv("PUSHOVER_TOKEN") == "" || os.Getenv("PUSHOVER_USER") == "" {
+					problems.add(field, "needs PUSHOVER_TOKEN and PUSHOVER_USER")
+				}
+			case "teams":
+				if os.Getenv("TEAMS_WEBHOOK_URL") == "" {
+					problems.add(field, "needs TEAMS_WEBHOOK_URL")
+				}
+			default:
+				problems.add(field, "unknown destination %q", destination)
+			}
+		}
+	}
+}
+
+// validateChannelRef accepts a channel ID, "#name" or "GUILD_ID/#name".
+func validateChannelRef(problems *configProblems, field, ref string) {
+	if !isChannelName(ref) {
+		if !snowflakePattern.MatchString(ref) {
+			problems.add(field, "%q is neither a channel ID nor a #name", ref)
+		}
+		return
+	}
+	if guildID, name, found := strings.Cut(ref, "/#"); found {
+		if !snowflakePattern.MatchString(guildID) {
+			problems.add(field, "%q is not a guild ID", guildID)
+		}
+		if name == "" {
+			problems.add(field, "missing channel name after #")
+		}
+	} else if !strings.HasPrefix(ref, "#") || len(ref) == 1 {
+		problems.add(field, "%q should be #name or GUILD_ID/#name", ref)
+	}
+}
+
+func validateURL(problems *configProblems, field, value string) {
+	if problem := urlProblem(value); problem != "" {
+		problems.add(field, "%s", problem)
+	}
+}
+
+func urlProblem(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Sprintf("%q is not an http(s) URL", value)
+	}
+	return ""
+}
+
+func positiveSeconds(value string) string {
+	if seconds, err := strconv.Atoi(value); err != nil || seconds <= 0 {
+		return fmt.Sprintf("%q is not a positive number of seconds", value)
+	}
+	return ""
+}
+
+// validDiscordToken checks the shape of a bot token: three base64url parts,
+// the first of which encodes the bot's user ID.
+func validDiscordToken(token string) bool {
+	if !discordTokenPattern.MatchString(token) {
+		return false
+	}
+	first, _, _ := strings.Cut(token, ".")
+	id, err := base64.RawURLEncoding.DecodeString(first)
+	return err == nil && snowflakePattern.Match(id)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validateReportTotals checks that REPORT_TOTALS_POSITION has something to
+// place: only the total, network stats, aggregates, computed totals and the
+// votes line go there.
+func validateReportTotals(problems *configProblems) {
+	if os.Getenv("REPORT_TOTALS_POSITION") == "" {
+		return
+	}
+	if os.Getenv("REPORT_TOTAL") != "true" && os.Getenv("NETWORK_STATS") != "true" && os.Getenv("VOTE_TRACKING") != "true" &&
+		len(getEnvPerBot(aggregatePrefix)) == 0 && len(getEnvPerBot(computedPrefix)) == 0 {
+		problems.add("REPORT_TOTALS_POSITION", "nothing goes in the totals without REPORT_TOTAL, NETWORK_STATS, VOTE_TRACKING, an %s<NAME> or a %s<NAME>", aggregatePrefix, computedPrefix)
+	}
+}
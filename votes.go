@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ListVotes is a bot's vote count on one bot list.
+type ListVotes struct {
+	List       string // top.gg or DBL
+	Total      int    // Votes of all time
+	Monthly    int    // Votes this month (top.gg only)
+	HasMonthly bool
+}
+
+// listVotes reads the vote counts from the listings fetched for the bot.
+// Either listing is nil when it couldn't be fetched, and DBL only counts
+// when its listing includes the votes.
+func listVotes(topgg *TopGGBot, dbl *DBLBot) []ListVotes {
+	var votes []ListVotes
+	if topgg != nil {
+		votes = append(votes, ListVotes{List: "top.gg", Total: topgg.Points, Monthly: topgg.MonthlyPoints, HasMonthly: true})
+	}
+	if dbl != nil && dbl.Upvotes != nil {
+		votes = append(votes, ListVotes{List: "DBL", Total: *dbl.Upvotes})
+	}
+	return votes
+}
+
+// combinedVotes sums the votes of the bots in the destination's report per
+// list, leaving out private bots like the server total does.
+func combinedVotes(allStats []BotStats, destination Destination) []ListVotes {
+	var combined []ListVotes
+	for _, stats := range visibleStats(allStats, destination) {
+		if stats.Pending || config.PrivateBots[stats.BotID] {
+			continue
+		}
+		for _, votes := range stats.Votes {
+			i := 0
+			for i < len(combined) && combined[i].List != votes.List {
+				i++
+			}
+			if i == len(combined) {
+				combined = append(combined, ListVotes{List: votes.List})
+			}
+			combined[i].Total += votes.Total
+			combined[i].Monthly += votes.Monthly
+			combined[i].HasMonthly = combined[i].HasMonthly || votes.HasMonthly
+		}
+	}
+	return combined
+}
+
+func totalVotes(votes []ListVotes) int {
+	total := 0
+	for _, list := range votes {
+		total += list.Total
+	}
+	return total
+}
+
+// formatVotes renders the per-list breakdown, e.g. "top.gg 1200, 今月 85 / DBL 40".
+func formatVotes(language string, votes []ListVotes) string {
+	parts := make([]string, 0, len(votes))
+	for _, list := range votes {
+		part := fmt.Sprintf("%s %d", list.List, list.Total)
+		if list.HasMonthly {
+			part += ", " + translate(language, "votes_monthly", list.Monthly)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " / ")
+}
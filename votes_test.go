@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListVotes(t *testing.T) {
+	upvotes := 40
+	tests := []struct {
+		name  string
+		topgg *TopGGBot
+		dbl   *DBLBot
+		want  string
+	}{
+		{"nothing fetched", nil, nil, ""},
+		{"top.gg", &TopGGBot{Points: 1200, MonthlyPoints: 85}, nil, "top.gg 1200, 今月 85"},
+		{"DBL without votes", nil, &DBLBot{ShortDescription: "音楽bot"}, ""},
+		{"both", &TopGGBot{Points: 1200, MonthlyPoints: 85}, &DBLBot{Upvotes: &upvotes}, "top.gg 1200, 今月 85 / DBL 40"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := formatVotes("ja", listVotes(test.topgg, test.dbl)); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReportVotes(t *testing.T) {
+	allStats := []BotStats{
+		{BotID: "1", BotName: "a", ServerCount: 100, Votes: []ListVotes{
+			{List: "top.gg", Total: 1200, Monthly: 85, HasMonthly: true},
+			{List: "DBL", Total: 40},
+		}},
+		{BotID: "2", BotName: "b", ServerCount: 50, Votes: []ListVotes{
+			{List: "top.gg", Total: 300, Monthly: 15, HasMonthly: true},
+		}},
+		{BotID: "3", BotName: "c", ServerCount: 10, Votes: []ListVotes{
+			{List: "top.gg", Total: 5000, Monthly: 500, HasMonthly: true},
+		}},
+	}
+
+	previousConfig := config
+	t.Cleanup(func() { config = previousConfig })
+	config.PrivateBots = map[string]bool{"3": true}
+	config.PublicMetrics = map[string]bool{"servers": true}
+
+	message := buildReportMessage(allStats, nil, Destination{Language: "ja", Location: time.UTC})
+	for _, want := range []string{
+		"a : **100** (投票: 1240 — top.gg 1200, 今月 85 / DBL 40)",
+		"b : **50** (投票: 300 — top.gg 300, 今月 15)",
+		"🗳 投票合計: **1540** (top.gg 1500, 今月 100 / DBL 40)",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("report has no %q:\n%s", want, message)
+		}
+	}
+
+	public := buildReportMessage(allStats, nil, Destination{Language: "ja", Location: time.UTC, Public: true})
+	if strings.Contains(public, "投票") {
+		t.Errorf("public report shows votes without PUBLIC_REPORT_METRICS=votes:\n%s", public)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// The cron scheduler runs reports from timers, so a stalled process, a
+// suspended host or a wall clock that jumps can make a report start late or
+// not at all without any error. The watchdog keeps its own copy of every
+// report schedule and alerts when a run doesn't start within
+// SCHEDULE_TOLERANCE of its time.
+
+// watchdogInterval is how often the watchdog checks the schedules.
+const watchdogInterval = 30 * time.Second
+
+type watchedSchedule struct {
+	schedule cron.Schedule
+	expected time.Time // When the next run should start
+	missed   time.Time // Run that was reported as not started
+}
+
+var (
+	watchdogMu       sync.Mutex
+	watchedSchedules = make(map[string]*watchedSchedule) // Run group -> Schedule
+)
+
+// watchSchedule registers a report's run group with the watchdog.
+func watchSchedule(expr, group string) {
+	if config.ScheduleTolerance <= 0 {
+		return
+	}
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return
+	}
+
+	watchdogMu.Lock()
+	defer watchdogMu.Unlock()
+	watchedSchedules[group] = &watchedSchedule{schedule: schedule, expected: schedule.Next(time.Now())}
+}
+
+// startWatchdog checks the registered schedules and the system clock in the
+// background.
+func startWatchdog() {
+	if config.ScheduleTolerance <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(watchdogInterval)
+		defer ticker.Stop()
+
+		last := time.Now()
+		for range ticker.C {
+			now := time.Now()
+			checkClock(last, now)
+			checkSchedules(now)
+			last = now
+		}
+	}()
+	log.Printf("Scheduler watchdog started (tolerance %v)", config.ScheduleTolerance)
+}
+
+// checkClock alerts when the wall clock moved differently from the
+// monotonic clock since the last check, which happens when the time is
+// changed or the host was suspended.
+func checkClock(last, now time.Time) {
+	// Round(0) strips the monotonic reading, leaving the wall clock
+	jump := now.Round(0).Sub(last.Round(0)) - now.Sub(last)
+	if jump < 0 {
+		jump = -jump
+	}
+	if jump <= config.ScheduleTolerance {
+		return
+	}
+
+	metrics.recordClockJump()
+	raiseAlert(Alert{
+		Severity: SeverityWarn,
+		Message:  fmt.Sprintf("システム時刻が%vずれました（時刻の変更またはホストの一時停止）。スケジュールされたレポートが遅れる可能性があります", jump.Round(time.Second)),
+		Rule:     RuleScheduler,
+	})
+}
+
+// checkSchedules alerts on runs that should have started more than the
+// tolerance ago.
+func checkSchedules(now time.Time) {
+	var alerts []Alert
+
+	watchdogMu.Lock()
+	groups := make([]string, 0, len(watchedSchedules))
+	for group := range watchedSchedules {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		watched := watchedSchedules[group]
+		if now.Sub(watched.expected) <= config.ScheduleTolerance {
+			continue
+		}
+
+		metrics.recordMissedRun()
+		alerts = append(alerts, Alert{
+			Severity: SeverityWarn,
+			Message:  fmt.Sprintf("レポート「%s」の%sの実行が%v以上たっても開始されていません", group, watched.expected.Format("2006-01-02 15:04"), config.ScheduleTolerance),
+			Rule:     RuleScheduler,
+		})
+		watched.missed = watched.expected
+		watched.expected = watched.schedule.Next(now)
+	}
+	watchdogMu.Unlock()
+
+	for _, alert := range alerts {
+		raiseAlert(alert)
+	}
+}
+
+// scheduledRunStarted records how late a scheduled run started, and alerts
+// when it started later than tolerated.
+func scheduledRunStarted(group string, scheduledAt time.Time) {
+	drift := time.Since(scheduledAt)
+	metrics.recordDrift(drift)
+
+	watchdogMu.Lock()
+	watched, exists := watchedSchedules[group]
+	var reported bool
+	if exists {
+		reported = watched.missed.Equal(scheduledAt)
+		if next := watched.schedule.Next(scheduledAt); next.After(watched.expected) {
+			watched.expected = next
+		}
+	}
+	watchdogMu.Unlock()
+
+	if !exists || drift <= config.ScheduleTolerance {
+		return
+	}
+	// A run already reported as not started only needs the all-clear
+	severity := SeverityInfo
+	if !reported {
+		metrics.recordLateRun()
+		severity = SeverityWarn
+	}
+	raiseAlert(Alert{
+		Severity: severity,
+		Message:  fmt.Sprintf("レポート「%s」の%sの実行が%v遅れて開始されました", group, scheduledAt.Format("2006-01-02 15:04"), drift.Round(time.Second)),
+		Rule:     RuleScheduler,
+	})
+}
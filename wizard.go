@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/joho/godotenv"
+	"github.com/robfig/cron/v3"
+)
+
+// runInitWizard interactively collects the required settings, validates
+// them against Discord and writes a .env file.
+func runInitWizard() {
+	reader := bufio.NewReader(os.Stdin)
+	envMap := make(map[string]string)
+
+	fmt.Println("statbot セットアップ")
+	fmt.Println("各項目を入力してください。入力した値はDiscordに接続して確認します。")
+	fmt.Println()
+
+	if _, err := os.Stat(".env"); err == nil {
+		if !promptYesNo(reader, ".envは既に存在します。上書きしますか？") {
+			fmt.Println("中止しました")
+			return
+		}
+	}
+
+	// Token: must authenticate as a bot
+	var wizardSession *discordgo.Session
+	for {
+		token := prompt(reader, "監視用botのトークン (DISCORD_TOKEN)", "", true)
+		s, err := discordgo.New("Bot " + token)
+		if err == nil {
+			var user *discordgo.User
+			user, err = s.User("@me")
+			if err == nil {
+				fmt.Printf("✅ %s としてログインできました\n", user.Username)
+				envMap["DISCORD_TOKEN"] = token
+				wizardSession = s
+				break
+			}
+		}
+		fmt.Printf("❌ トークンを確認できませんでした: %v\n", err)
+	}
+
+	// Channel: must be reachable with this token
+	for {
+		channelID := prompt(reader, "通知先チャンネルのID (CHANNEL_ID)", "", true)
+		channel, err := wizardSession.Channel(channelID)
+		if err == nil {
+			fmt.Printf("✅ チャンネル #%s を確認しました\n", channel.Name)
+			envMap["CHANNEL_ID"] = channelID
+			break
+		}
+		fmt.Printf("❌ チャンネルにアクセスできませんでした: %v\n", err)
+	}
+
+	// Target bots: every ID must resolve to a bot user
+	for {
+		value := prompt(reader, "監視対象のbot ID（カンマ区切り） (TARGET_BOT_IDS)", "", true)
+		var botIDs []string
+		valid := true
+		for _, id := range strings.Split(value, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" {
+				continue
+			}
+			user, err := wizardSession.User(id)
+			if err != nil {
+				fmt.Printf("❌ %s: ユーザーが見つかりません: %v\n", id, err)
+				valid = false
+				continue
+			}
+			if !user.Bot {
+				fmt.Printf("❌ %s: %s はbotではありません\n", id, user.Username)
+				valid = false
+				continue
+			}
+			fmt.Printf("✅ %s: %s\n", id, user.Username)
+			botIDs = append(botIDs, id)
+		}
+		if valid && len(botIDs) > 0 {
+			envMap["TARGET_BOT_IDS"] = strings.Join(botIDs, ",")
+			break
+		}
+	}
+
+	if token := prompt(reader, "top.gg APIトークン (TOPGG_TOKEN、省略可)", "", false); token != "" {
+		envMap["TOPGG_TOKEN"] = token
+	}
+
+	for {
+		notificationTime := prompt(reader, "通知時刻 (NOTIFICATION_TIME)", "09:00", true)
+		if _, err := cron.ParseStandard(toCronExpr(notificationTime)); err == nil {
+			envMap["NOTIFICATION_TIME"] = notificationTime
+			break
+		} else {
+			fmt.Printf("❌ 時刻の形式が正しくありません（HH:MM またはcron形式）: %v\n", err)
+		}
+	}
+
+	if err := godotenv.Write(envMap, ".env"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing .env: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	fmt.Println(".envを作成しました。`./statbot`で起動できます。")
+	fmt.Println("その他の設定項目は.env.exampleを参照してください。")
+}
+
+// prompt reads one line of input. Required prompts repeat until a value
+// (or the default) is available.
+func prompt(reader *bufio.Reader, label, defaultValue string, required bool) string {
+	for {
+		if defaultValue != "" {
+			fmt.Printf("%s [%s]: ", label, defaultValue)
+		} else {
+			fmt.Printf("%s: ", label)
+		}
+
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(os.Stderr, "\n入力が終了しました")
+			os.Exit(1)
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			line = defaultValue
+		}
+		if line != "" || !required {
+			return line
+		}
+	}
+}
+
+func promptYesNo(reader *bufio.Reader, label string) bool {
+	answer := strings.ToLower(prompt(reader, label+" (y/N)", "n", true))
+	return answer == "y" || answer == "yes"
+}